@@ -5,30 +5,170 @@ import (
 	"flag"
 	"fmt"
 	_ "github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/config"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/ratelimit"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/httpapi"
+	memratelimitrepo "github.com/mp-hl-2021/code-swamp/internal/interface/memory/ratelimitrepo"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/accountrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/apitokenrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/challengerepo"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/commentrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/emailtokenrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/identityrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/linteventrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/lintoptionsrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/loginattemptrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/oidcrequestrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/ratelimitrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/sessionrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/starrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/teamrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/postgres/webhookrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/formatter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/mailer"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passhash"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passwordpolicy"
+	"github.com/mp-hl-2021/code-swamp/internal/service/secretbox"
 	"github.com/mp-hl-2021/code-swamp/internal/service/token"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/team"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
 	"io/ioutil"
 	"net/http"
+	"os/exec"
 	"time"
 )
 
+func rateLimitRule(l *config.Limit) ratelimit.Rule {
+	if l == nil {
+		return ratelimit.Rule{}
+	}
+	return ratelimit.Rule{Requests: l.Requests, Per: l.Per.Duration, Burst: l.Burst}
+}
+
+func throttlePolicy(t config.Throttle) account.ThrottlePolicy {
+	return account.ThrottlePolicy{
+		FreeAttempts: t.FreeAttempts,
+		BaseLockout:  t.BaseLockout.Duration,
+		MaxLockout:   t.MaxLockout.Duration,
+		Window:       t.Window.Duration,
+	}
+}
+
+func passwordPolicy(p config.PasswordPolicy) (*passwordpolicy.Policy, error) {
+	policy := &passwordpolicy.Policy{
+		MinLength:  p.MinLength,
+		MaxLength:  p.MaxLength,
+		MinEntropy: p.MinEntropy,
+	}
+	for _, s := range p.AllowedClasses {
+		c, err := passwordpolicy.ParseClass(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, s)
+		}
+		policy.AllowedClasses = append(policy.AllowedClasses, c)
+	}
+	for _, s := range p.RequiredClasses {
+		c, err := passwordpolicy.ParseClass(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, s)
+		}
+		policy.RequiredClasses = append(policy.RequiredClasses, c)
+	}
+	if p.BreachedPasswordsFile != "" {
+		breached, err := passwordpolicy.LoadBreachedList(p.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+func mailSender(m config.Mail) mailer.Interface {
+	switch m.Backend {
+	case config.MailBackendSmtp:
+		return &mailer.Smtp{
+			Host:     m.Smtp.Host,
+			Port:     m.Smtp.Port,
+			Username: m.Smtp.Username,
+			Password: m.Smtp.Password,
+			From:     m.From,
+		}
+	case config.MailBackendMemory:
+		return mailer.NewMemory()
+	default:
+		return &mailer.File{Dir: m.Dir, From: m.From}
+	}
+}
+
+func oidcProviders(c config.Oidc) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(c.Providers))
+	for name, p := range c.Providers {
+		providers[name] = oidc.New(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientId:     p.ClientId,
+			ClientSecret: p.ClientSecret,
+			RedirectUrl:  p.RedirectUrl,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+	return providers
+}
+
 func main() {
+	configPath := flag.String("config", "", "JSON config file path")
 	privateKeyPath := flag.String("privateKey", "app.rsa", "file path")
 	publicKeyPath := flag.String("publicKey", "app.rsa.pub", "file path")
+	keyDir := flag.String("keyDir", "", "directory with <kid>.rsa/<kid>.rsa.pub signing keys, overrides privateKey and publicKey")
+	keyRotationPeriod := flag.Duration("keyRotationPeriod", 7*24*time.Hour, "signing key rotation period for keyDir")
+	accessTokenLifetime := flag.Duration("accessTokenLifetime", 15*time.Minute, "access token lifetime")
+	refreshTokenLifetime := flag.Duration("refreshTokenLifetime", 30*24*time.Hour, "refresh token lifetime")
 	flag.Parse()
 
-	privateKeyBytes, err := ioutil.ReadFile(*privateKeyPath)
-	publicKeyBytes, err := ioutil.ReadFile(*publicKeyPath)
-
-	a, err := token.NewJwt(privateKeyBytes, publicKeyBytes, 100*time.Minute)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		panic(err)
 	}
 
+	var keySet *token.KeySet
+	if *keyDir != "" {
+		d := token.KeyDirectory{
+			Path:               *keyDir,
+			RotationPeriod:     *keyRotationPeriod,
+			VerificationPeriod: *accessTokenLifetime,
+			WatchInterval:      time.Minute,
+		}
+		keySet, err = token.LoadKeySet(d)
+		if err != nil {
+			panic(err)
+		}
+		go keySet.Watch(d, nil, func(err error) {
+			fmt.Printf("Error rotating signing keys: %s\n", err)
+		})
+	} else {
+		privateKeyBytes, err := ioutil.ReadFile(*privateKeyPath)
+		if err != nil {
+			panic(err)
+		}
+		publicKeyBytes, err := ioutil.ReadFile(*publicKeyPath)
+		if err != nil {
+			panic(err)
+		}
+		key, err := token.KeyFromPEM("", privateKeyBytes, publicKeyBytes)
+		if err != nil {
+			panic(err)
+		}
+		keySet, err = token.NewKeySet(key)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	// TODO: pass arguments with config
 	connStr := "user=postgres password=12345 host=db dbname=postgres sslmode=disable"
 
@@ -44,36 +184,211 @@ func main() {
 		}
 	}(conn)
 
+	policy, err := passwordPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.PasswordHashing.Algorithm == passhash.Bcrypt {
+		policy.MaxBytes = passhash.BcryptMaxBytes
+	}
+
+	var totpSecrets *secretbox.Box
+	if cfg.TwoFactor.EncryptionKey != "" {
+		totpSecrets, err = secretbox.NewFromBase64(cfg.TwoFactor.EncryptionKey)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	sessionStorage := sessionrepo.New(conn)
+
+	a := token.NewJwt(keySet, *accessTokenLifetime, sessionStorage)
+
 	ch := make(chan codesnippet.CheckCodeRequest)
 
 	accountUseCases := &account.UseCases{
-		AccountStorage: accountrepo.New(conn),
+		AccountStorage:    accountrepo.New(conn),
+		SessionStorage:    sessionStorage,
+		ApiTokenStorage:   apitokenrepo.New(conn),
+		EmailTokenStorage: emailtokenrepo.New(conn),
+		Mailer:            mailSender(cfg.Mail),
+		Email: account.EmailSettings{
+			BaseUrl:                   cfg.Mail.BaseUrl,
+			VerificationTokenLifetime: cfg.Mail.VerificationTokenLifetime.Duration,
+			ResetTokenLifetime:        cfg.Mail.ResetTokenLifetime.Duration,
+		},
+		LoginThrottling: &account.LoginThrottling{
+			Storage:  loginattemptrepo.New(conn),
+			PerLogin: throttlePolicy(cfg.LoginThrottling.PerLogin),
+			PerIp:    throttlePolicy(cfg.LoginThrottling.PerIp),
+		},
 		Auth:           a,
+		PasswordPolicy: policy,
+		PasswordHasher: &passhash.Hasher{
+			Algorithm:  cfg.PasswordHashing.Algorithm,
+			BcryptCost: cfg.PasswordHashing.BcryptCost,
+			Argon2id: passhash.Argon2idParams{
+				Memory:      cfg.PasswordHashing.Argon2id.MemoryKiB,
+				Iterations:  cfg.PasswordHashing.Argon2id.Iterations,
+				Parallelism: cfg.PasswordHashing.Argon2id.Parallelism,
+				SaltLength:  cfg.PasswordHashing.Argon2id.SaltLength,
+				KeyLength:   cfg.PasswordHashing.Argon2id.KeyLength,
+			},
+		},
+		ChallengeStorage: challengerepo.New(conn),
+		TwoFactor: account.TwoFactorSettings{
+			Issuer:            cfg.TwoFactor.Issuer,
+			ChallengeLifetime: cfg.TwoFactor.ChallengeLifetime.Duration,
+			Secrets:           totpSecrets,
+		},
+		IdentityStorage:    identityrepo.New(conn),
+		OidcRequestStorage: oidcrequestrepo.New(conn),
+		Oidc: account.OidcSettings{
+			Providers:       oidcProviders(cfg.Oidc),
+			RequestLifetime: cfg.Oidc.RequestLifetime.Duration,
+		},
+		RefreshTokenLifetime: *refreshTokenLifetime,
 	}
 
+	webhookUseCases := &webhook.UseCases{
+		WebhookStorage:       webhookrepo.New(conn),
+		Client:               webhook.NewClient(cfg.Webhooks.AllowPrivateNetworks),
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		BaseBackoff:          cfg.Webhooks.BaseBackoff.Duration,
+		MaxBackoff:           cfg.Webhooks.MaxBackoff.Duration,
+	}
+
+	lintEvents, err := linteventrepo.New(conn, connStr)
+	if err != nil {
+		panic(err)
+	}
+	defer lintEvents.Close()
+
+	teamStorage := teamrepo.New(conn)
+	lintRunner := &linter.Runner{
+		Timeout:   cfg.Linters.Timeout.Duration,
+		MaxOutput: cfg.Linters.MaxOutput,
+	}
+	linters := append([]linter.Linter{&linter.GoAnalyzer{}}, linter.Validators()...)
+	if cfg.Linters.DuplPath != "" {
+		if _, err := exec.LookPath(cfg.Linters.DuplPath); err != nil {
+			fmt.Printf("Linting without dupl: %s\n", err)
+		} else {
+			linters = append(linters, &linter.Dupl{Path: cfg.Linters.DuplPath, Runner: lintRunner})
+		}
+	}
+	formatters := []formatter.Formatter{&formatter.Go{}, &formatter.JSON{}}
+	for _, f := range cfg.Formatters {
+		if _, err := exec.LookPath(f.Path); err != nil {
+			fmt.Printf("Formatting without %s: %s\n", f.Name, err)
+			continue
+		}
+		formatters = append(formatters, &formatter.Command{
+			Id:        f.Name,
+			Languages: f.Languages,
+			Path:      f.Path,
+			Args:      f.Args,
+			File:      f.File,
+			Runner:    lintRunner,
+		})
+	}
 	codeSnippetUseCases := &codesnippet.UseCases{
 		CodeSnippetStorage: codesnippetrepo.New(conn),
+		TeamStorage:        teamStorage,
+		CommentStorage:     commentrepo.New(conn),
+		StarStorage:        starrepo.New(conn),
+		Webhooks:           webhookUseCases,
+		LintEvents:         lintEvents,
+		Linters:            linter.NewRegistry(linters...),
+		Formatters:         formatter.NewRegistry(formatters...),
+		LintTimeout:        cfg.Linters.Timeout.Duration,
+		LintRequestTimeout: cfg.Linters.RequestTimeout.Duration,
+		LintOptionsStorage: lintoptionsrepo.New(conn),
 		CodeCheckChannel:   ch,
+		Admins:             cfg.Admins,
+		RelintInterval:     cfg.Linters.RelintInterval.Duration,
+	}
+	teamUseCases := &team.UseCases{
+		TeamStorage:        teamStorage,
+		InvitationLifetime: cfg.Teams.InvitationLifetime.Duration,
 	}
 
 	go func() {
 		for _ = range time.Tick(time.Second) {
 			c := <-ch
 			fmt.Printf("Checking code sid: %d, code: %s, lang: %s\n", c.Sid, c.Code, c.Lang)
-			err := codeSnippetUseCases.CheckCode(c.Sid, c.Code, c.Lang)
+			err := codeSnippetUseCases.CheckCode(c.Sid, c.Code, c.Lang, c.Options)
 			if err != nil {
 				fmt.Printf("Error checking code: %s\n", err)
 			}
 		}
 	}()
 
+	go func() {
+		for _ = range time.Tick(time.Minute) {
+			if err := codeSnippetUseCases.ExpireSnippets(); err != nil {
+				fmt.Printf("Error expiring snippets: %s\n", err)
+			}
+		}
+	}()
+	go func() {
+		for _ = range time.Tick(cfg.Webhooks.PollInterval.Duration) {
+			// keep going while there is a backlog
+			for {
+				n, err := webhookUseCases.DeliverDue()
+				if err != nil {
+					fmt.Printf("Error delivering webhooks: %s\n", err)
+				}
+				if err != nil || n == 0 {
+					break
+				}
+			}
+		}
+	}()
+
+	var limiter ratelimit.Interface
+	if cfg.RateLimits.Backend == config.RateLimitBackendPostgres {
+		limiter = ratelimitrepo.New(conn)
+	} else {
+		limiter = memratelimitrepo.NewMemory()
+	}
+	go func() {
+		for _ = range time.Tick(time.Minute) {
+			if err := limiter.DeleteIdleBuckets(time.Hour); err != nil {
+				fmt.Printf("Error deleting idle rate limit buckets: %s\n", err)
+			}
+		}
+	}()
+	routeLimits := make(map[string]httpapi.RouteLimit, len(cfg.RateLimits.Routes))
+	for route, l := range cfg.RateLimits.Routes {
+		routeLimits[route] = httpapi.RouteLimit{
+			PerIp:      rateLimitRule(l.PerIp),
+			PerAccount: rateLimitRule(l.PerAccount),
+		}
+	}
+
 	service := httpapi.NewApi(accountUseCases, codeSnippetUseCases)
+	service.RateLimits = &httpapi.RateLimits{
+		Limiter: limiter,
+		Routes:  routeLimits,
+	}
+	service.TrustForwardedFor = cfg.RateLimits.TrustForwardedFor
+	service.TrustedProxies, err = httpapi.ParseTrustedProxies(cfg.RateLimits.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	service.TeamUseCases = teamUseCases
+	service.WebhookUseCases = webhookUseCases
+
+	writeTimeout := config.WriteTimeout
+	service.EventStreamLifetime = writeTimeout - time.Second
 
 	addr := ":8080"
 	server := http.Server{
 		Addr:         addr,
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: writeTimeout,
 
 		Handler: service.Router(),
 	}
@@ -82,4 +397,4 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-}
\ No newline at end of file
+}
@@ -3,6 +3,7 @@ module github.com/mp-hl-2021/code-swamp
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/brianvoe/gofakeit/v6 v6.4.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gorilla/mux v1.8.0
@@ -10,4 +11,6 @@ require (
 	github.com/mibk/dupl v1.0.0 // indirect
 	github.com/prometheus/client_golang v1.10.0
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/text v0.3.6
+	gopkg.in/yaml.v3 v3.0.1
 )
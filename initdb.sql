@@ -1,24 +1,273 @@
 drop table if exists accounts cascade;
 create table accounts
+(
+    id             serial primary key,
+    login          varchar(255) not null,
+    canonicalLogin varchar(255) not null,
+    password       varchar(255) not null,
+    email          varchar(320),
+    emailVerified  bool not null default false,
+    totpSecret     varchar(255),
+    totpEnabled    bool not null default false,
+    totpLastStep   bigint not null default 0,
+    createdAt      timestamp without time zone default now(),
+    updatedAt      timestamp without time zone default now(),
+
+    unique (canonicalLogin)
+);
+
+-- an address may be entered by several accounts, but verified by only one
+create unique index accounts_verified_email on accounts (email) where emailVerified;
+
+drop table if exists teams cascade;
+create table teams
+(
+    id        serial primary key,
+    name      varchar(255) not null,
+    createdAt timestamp with time zone not null default now(),
+
+    unique (name)
+);
+
+drop table if exists team_members cascade;
+create table team_members
+(
+    tid      int not null references teams (id) on delete cascade,
+    uid      int not null references accounts (id) on delete cascade,
+    role     varchar(16) not null,
+    joinedAt timestamp with time zone not null default now(),
+
+    primary key (tid, uid)
+);
+
+drop table if exists team_invitations cascade;
+create table team_invitations
 (
     id        serial primary key,
-    login     varchar(255) not null,
-    password  varchar(255) not null,
-    createdAt timestamp without time zone default now(),
-    updatedAt timestamp without time zone default now(),
+    tid       int not null references teams (id) on delete cascade,
+    uid       int not null references accounts (id) on delete cascade,
+    role      varchar(16) not null,
+    invitedBy int not null,
+    createdAt timestamp with time zone not null default now(),
+    expiresAt timestamp with time zone not null,
 
-    unique (login)
+    unique (tid, uid)
+);
+
+drop table if exists account_lint_options cascade;
+create table account_lint_options
+(
+    uid     int primary key references accounts (id) on delete cascade,
+    options varchar not null
 );
 
 drop table if exists snippets cascade;
 create table snippets
+(
+    id             serial primary key,
+    code           varchar not null,
+    uid            int,
+    tid            int references teams (id) on delete cascade,
+    language       varchar(64),
+    lifetime       interval not null,
+    createdAt      timestamp without time zone default now(),
+    lintStatus     varchar(16) not null default 'pending'
+        check (lintStatus in ('pending', 'running', 'passed', 'findings', 'failed', 'unsupported', 'skipped')),
+    linter         varchar not null default '',
+    linterVersion  varchar not null default '',
+    lintStartedAt  timestamp with time zone,
+    lintFinishedAt timestamp with time zone,
+    lintError      varchar not null default '',
+    message        varchar not null,
+    lintOptions    varchar not null default '{}',
+    stars          int not null default 0
+);
+
+drop table if exists sessions cascade;
+create table sessions
+(
+    id               varchar(64) primary key,
+    uid              int not null references accounts (id) on delete cascade,
+    device           varchar(255) not null,
+    refreshTokenHash varchar(64) not null,
+    accessTokenId    varchar(64) not null,
+    accessExpiresAt  timestamp with time zone not null,
+    createdAt        timestamp with time zone not null default now(),
+    lastUsedAt       timestamp with time zone not null default now(),
+    expiresAt        timestamp with time zone not null,
+
+    unique (refreshTokenHash)
+);
+
+drop table if exists revoked_tokens cascade;
+create table revoked_tokens
+(
+    jti       varchar(64) primary key,
+    expiresAt timestamp with time zone not null
+);
+
+drop table if exists api_tokens cascade;
+create table api_tokens
+(
+    id         serial primary key,
+    uid        int not null references accounts (id) on delete cascade,
+    name       varchar(255) not null,
+    hash       varchar(64) not null,
+    scopes     varchar(255) not null,
+    createdAt  timestamp with time zone not null default now(),
+    lastUsedAt timestamp with time zone not null default now(),
+
+    unique (hash)
+);
+
+drop table if exists rate_limits cascade;
+create table rate_limits
+(
+    key       varchar(255) primary key,
+    tokens    double precision not null,
+    allowed   bool not null,
+    updatedAt timestamp with time zone not null
+);
+
+drop table if exists login_attempts cascade;
+create table login_attempts
+(
+    key         varchar(320) primary key,
+    failures    int not null,
+    lockedUntil timestamp with time zone not null,
+    updatedAt   timestamp with time zone not null
+);
+
+drop table if exists email_tokens cascade;
+create table email_tokens
+(
+    hash      varchar(64) primary key,
+    uid       int not null references accounts (id) on delete cascade,
+    purpose   varchar(32) not null,
+    email     varchar(320) not null,
+    expiresAt timestamp with time zone not null
+);
+
+drop table if exists signin_challenges cascade;
+create table signin_challenges
+(
+    hash      varchar(64) primary key,
+    uid       int not null references accounts (id) on delete cascade,
+    device    varchar(255) not null,
+    failures  int not null default 0,
+    expiresAt timestamp with time zone not null
+);
+
+drop table if exists recovery_codes cascade;
+create table recovery_codes
+(
+    uid  int not null references accounts (id) on delete cascade,
+    hash varchar(64) not null,
+
+    primary key (uid, hash)
+);
+
+drop table if exists identities cascade;
+create table identities
+(
+    provider  varchar(64) not null,
+    subject   varchar(255) not null,
+    uid       int not null references accounts (id) on delete cascade,
+    email     varchar(320) not null,
+    createdAt timestamp with time zone not null default now(),
+
+    primary key (provider, subject),
+    unique (uid, provider)
+);
+
+drop table if exists oidc_requests cascade;
+create table oidc_requests
+(
+    stateHash    varchar(64) primary key,
+    provider     varchar(64) not null,
+    nonce        varchar(64) not null,
+    codeVerifier varchar(128) not null,
+    device       varchar(255) not null,
+    link         bool not null,
+    linkUid      int not null,
+    expiresAt    timestamp with time zone not null
+);
+
+drop table if exists snippet_comments cascade;
+create table snippet_comments
 (
     id        serial primary key,
-    code      varchar not null,
-    uid       int,
-    language  varchar(64),
-    lifetime  interval not null,
-    createdAt timestamp without time zone default now(),
-    isChecked bool not null,
-    message   varchar not null
-);
\ No newline at end of file
+    sid       int not null references snippets (id) on delete cascade,
+    uid       int not null references accounts (id) on delete cascade,
+    parentId  int references snippet_comments (id) on delete cascade,
+    startLine int not null,
+    endLine   int not null,
+    body      varchar(4096) not null,
+    hidden    bool not null default false,
+    createdAt timestamp with time zone not null default now()
+);
+
+create index snippet_comments_sid on snippet_comments (sid);
+
+drop table if exists snippet_stars cascade;
+create table snippet_stars
+(
+    uid       int not null references accounts (id) on delete cascade,
+    sid       int not null references snippets (id) on delete cascade,
+    createdAt timestamp with time zone not null default now(),
+
+    primary key (uid, sid)
+);
+
+create index snippet_stars_sid on snippet_stars (sid);
+
+-- keeps snippets.stars in step, including stars removed by a cascade
+create or replace function count_snippet_stars() returns trigger as
+$$
+begin
+    if tg_op = 'INSERT' then
+        update snippets set stars = stars + 1 where id = new.sid;
+    else
+        update snippets set stars = stars - 1 where id = old.sid;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+
+create trigger snippet_stars_count
+    after insert or delete
+    on snippet_stars
+    for each row
+execute procedure count_snippet_stars();
+
+drop table if exists webhooks cascade;
+create table webhooks
+(
+    id        serial primary key,
+    uid       int not null references accounts (id) on delete cascade,
+    url       varchar(2048) not null,
+    secret    varchar(64) not null,
+    events    varchar(255) not null,
+    createdAt timestamp with time zone not null default now()
+);
+
+create index webhooks_uid on webhooks (uid);
+
+drop table if exists webhook_deliveries cascade;
+create table webhook_deliveries
+(
+    id            serial primary key,
+    webhookId     int not null references webhooks (id) on delete cascade,
+    event         varchar(64) not null,
+    payload       bytea not null,
+    state         varchar(16) not null,
+    attempts      int not null default 0,
+    statusCode    int not null default 0,
+    error         varchar(255) not null default '',
+    nextAttemptAt timestamp with time zone not null,
+    createdAt     timestamp with time zone not null default now(),
+    updatedAt     timestamp with time zone not null default now()
+);
+
+create index webhook_deliveries_webhook on webhook_deliveries (webhookId);
+create index webhook_deliveries_due on webhook_deliveries (nextAttemptAt) where state = 'pending';
@@ -0,0 +1,400 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+const (
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+
+	MailBackendSmtp   = "smtp"
+	MailBackendFile   = "file"
+	MailBackendMemory = "memory"
+
+	// WriteTimeout bounds writing a response, the server cuts off the
+	// requests taking longer.
+	WriteTimeout = 10 * time.Second
+)
+
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("duration should be a string like \"1m30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+type Limit struct {
+	Requests int      `json:"requests"`
+	Per      Duration `json:"per"`
+	Burst    int      `json:"burst,omitempty"`
+}
+
+type RouteLimit struct {
+	PerIp      *Limit `json:"per_ip,omitempty"`
+	PerAccount *Limit `json:"per_account,omitempty"`
+}
+
+type RateLimits struct {
+	Backend           string `json:"backend"`
+	TrustForwardedFor bool   `json:"trust_forwarded_for"`
+	// TrustedProxies are addresses or CIDR blocks of the proxies in front
+	// of the server, X-Forwarded-For is only taken from them.
+	TrustedProxies []string              `json:"trusted_proxies"`
+	Routes         map[string]RouteLimit `json:"routes"`
+}
+
+type Throttle struct {
+	FreeAttempts int      `json:"free_attempts"`
+	BaseLockout  Duration `json:"base_lockout"`
+	MaxLockout   Duration `json:"max_lockout"`
+	Window       Duration `json:"window"`
+}
+
+type LoginThrottling struct {
+	PerLogin Throttle `json:"per_login"`
+	PerIp    Throttle `json:"per_ip"`
+}
+
+type PasswordPolicy struct {
+	MinLength             int      `json:"min_length"`
+	MaxLength             int      `json:"max_length"`
+	AllowedClasses        []string `json:"allowed_classes"`
+	RequiredClasses       []string `json:"required_classes"`
+	MinEntropy            float64  `json:"min_entropy"`
+	BreachedPasswordsFile string   `json:"breached_passwords_file"`
+}
+
+type Argon2id struct {
+	MemoryKiB   uint32 `json:"memory_kib"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	SaltLength  uint32 `json:"salt_length"`
+	KeyLength   uint32 `json:"key_length"`
+}
+
+type PasswordHashing struct {
+	Algorithm  string   `json:"algorithm"`
+	BcryptCost int      `json:"bcrypt_cost"`
+	Argon2id   Argon2id `json:"argon2id"`
+}
+
+type Smtp struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type Mail struct {
+	Backend                   string   `json:"backend"`
+	From                      string   `json:"from"`
+	BaseUrl                   string   `json:"base_url"`
+	Smtp                      Smtp     `json:"smtp"`
+	Dir                       string   `json:"dir"`
+	VerificationTokenLifetime Duration `json:"verification_token_lifetime"`
+	ResetTokenLifetime        Duration `json:"reset_token_lifetime"`
+}
+
+type TwoFactor struct {
+	Issuer string `json:"issuer"`
+	// EncryptionKey is a base64 encoded 32 byte key for TOTP secrets at
+	// rest. Without it two-factor enrolment is unavailable.
+	EncryptionKey     string   `json:"encryption_key"`
+	ChallengeLifetime Duration `json:"challenge_lifetime"`
+}
+
+type OidcProvider struct {
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectUrl  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes,omitempty"`
+}
+
+type Oidc struct {
+	RequestLifetime Duration                `json:"request_lifetime"`
+	Providers       map[string]OidcProvider `json:"providers"`
+}
+
+type Teams struct {
+	InvitationLifetime Duration `json:"invitation_lifetime"`
+}
+
+type Webhooks struct {
+	// AllowPrivateNetworks lets webhooks point at loopback and private
+	// addresses. Keep it off unless every user is trusted.
+	AllowPrivateNetworks bool     `json:"allow_private_networks"`
+	MaxAttempts          int      `json:"max_attempts"`
+	BaseBackoff          Duration `json:"base_backoff"`
+	MaxBackoff           Duration `json:"max_backoff"`
+	PollInterval         Duration `json:"poll_interval"`
+}
+
+type Linters struct {
+	// DuplPath is the dupl binary, dupl is not run without it. Go code is
+	// analyzed in process either way.
+	DuplPath string   `json:"dupl_path"`
+	Timeout  Duration `json:"timeout"`
+	// RequestTimeout bounds linting and formatting done while a request
+	// waits, it should stay below WriteTimeout. Timeout bounds the rest.
+	RequestTimeout Duration `json:"lint_request_timeout"`
+	// MaxOutput caps what a linter process may write to stdout and stderr
+	// each, in bytes.
+	MaxOutput int `json:"max_output"`
+	// RelintInterval spaces out the snippets a bulk re-lint queues.
+	RelintInterval Duration `json:"relint_interval"`
+}
+
+// Formatter is an external formatter. It is given the code in File in its
+// working directory, Args refer to File by name, and prints the formatted
+// code. It runs with the limits of the linters.
+type Formatter struct {
+	Name      string   `json:"name"`
+	Languages []string `json:"languages"`
+	Path      string   `json:"path"`
+	Args      []string `json:"args"`
+	File      string   `json:"file"`
+}
+
+type Config struct {
+	RateLimits      RateLimits      `json:"rate_limits"`
+	LoginThrottling LoginThrottling `json:"login_throttling"`
+	PasswordPolicy  PasswordPolicy  `json:"password_policy"`
+	PasswordHashing PasswordHashing `json:"password_hashing"`
+	Mail            Mail            `json:"mail"`
+	TwoFactor       TwoFactor       `json:"two_factor"`
+	Oidc            Oidc            `json:"oidc"`
+	Teams           Teams           `json:"teams"`
+	Webhooks        Webhooks        `json:"webhooks"`
+	Linters         Linters         `json:"linters"`
+	// Formatters format languages other than Go and JSON, which are
+	// formatted in process.
+	Formatters []Formatter `json:"formatters"`
+	// Admins are the ids of the accounts allowed to run admin operations.
+	Admins []uint `json:"admins"`
+}
+
+func Default() Config {
+	return Config{
+		RateLimits: RateLimits{
+			Backend: RateLimitBackendMemory,
+			Routes: map[string]RouteLimit{
+				"signup": {
+					PerIp: &Limit{Requests: 5, Per: Duration{time.Minute}},
+				},
+				"signin": {
+					PerIp: &Limit{Requests: 20, Per: Duration{time.Minute}},
+				},
+				"refresh": {
+					PerIp: &Limit{Requests: 30, Per: Duration{time.Minute}},
+				},
+				"post_code": {
+					PerIp:      &Limit{Requests: 60, Per: Duration{time.Minute}, Burst: 20},
+					PerAccount: &Limit{Requests: 120, Per: Duration{time.Minute}, Burst: 30},
+				},
+				"email": {
+					PerIp:      &Limit{Requests: 10, Per: Duration{time.Hour}},
+					PerAccount: &Limit{Requests: 5, Per: Duration{time.Hour}},
+				},
+				"password_reset": {
+					PerIp: &Limit{Requests: 10, Per: Duration{time.Hour}},
+				},
+				"comment": {
+					PerAccount: &Limit{Requests: 30, Per: Duration{time.Minute}, Burst: 10},
+				},
+				"lint": {
+					PerIp:      &Limit{Requests: 20, Per: Duration{time.Minute}, Burst: 5},
+					PerAccount: &Limit{Requests: 60, Per: Duration{time.Minute}, Burst: 10},
+				},
+			},
+		},
+		LoginThrottling: LoginThrottling{
+			PerLogin: Throttle{
+				FreeAttempts: 5,
+				BaseLockout:  Duration{time.Second},
+				MaxLockout:   Duration{15 * time.Minute},
+				Window:       Duration{time.Hour},
+			},
+			PerIp: Throttle{
+				FreeAttempts: 50,
+				BaseLockout:  Duration{time.Second},
+				MaxLockout:   Duration{time.Hour},
+				Window:       Duration{time.Hour},
+			},
+		},
+		PasswordPolicy: PasswordPolicy{
+			MinLength:       6,
+			MaxLength:       40,
+			RequiredClasses: []string{"lower", "upper", "digit"},
+		},
+		PasswordHashing: PasswordHashing{
+			Algorithm:  "argon2id",
+			BcryptCost: 10,
+			Argon2id: Argon2id{
+				MemoryKiB:   64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+		},
+		Mail: Mail{
+			Backend:                   MailBackendFile,
+			From:                      "code-swamp <noreply@localhost>",
+			BaseUrl:                   "http://localhost:8080",
+			Smtp:                      Smtp{Port: 587},
+			Dir:                       "mail",
+			VerificationTokenLifetime: Duration{24 * time.Hour},
+			ResetTokenLifetime:        Duration{time.Hour},
+		},
+		TwoFactor: TwoFactor{
+			Issuer:            "code-swamp",
+			ChallengeLifetime: Duration{5 * time.Minute},
+		},
+		Oidc: Oidc{
+			RequestLifetime: Duration{10 * time.Minute},
+		},
+		Teams: Teams{
+			InvitationLifetime: Duration{7 * 24 * time.Hour},
+		},
+		Webhooks: Webhooks{
+			MaxAttempts:  8,
+			BaseBackoff:  Duration{30 * time.Second},
+			MaxBackoff:   Duration{time.Hour},
+			PollInterval: Duration{5 * time.Second},
+		},
+		Linters: Linters{
+			Timeout:        Duration{10 * time.Second},
+			RequestTimeout: Duration{5 * time.Second},
+			MaxOutput:      1024 * 1024,
+			RelintInterval: Duration{time.Second},
+		},
+	}
+}
+
+// Load reads a JSON config file on top of the defaults. An empty path
+// returns the defaults.
+func Load(path string) (Config, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Config{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c Config) validate() error {
+	switch c.RateLimits.Backend {
+	case RateLimitBackendMemory, RateLimitBackendPostgres:
+	default:
+		return fmt.Errorf("unknown rate limit backend %q", c.RateLimits.Backend)
+	}
+	if c.RateLimits.TrustForwardedFor && len(c.RateLimits.TrustedProxies) == 0 {
+		return errors.New("trust_forwarded_for needs trusted_proxies")
+	}
+	for _, p := range c.RateLimits.TrustedProxies {
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			return fmt.Errorf("trusted proxy %q should be an address or a CIDR block", p)
+		}
+	}
+	if c.PasswordPolicy.MaxLength > 0 && c.PasswordPolicy.MaxLength < c.PasswordPolicy.MinLength {
+		return errors.New("password max_length should not be less than min_length")
+	}
+	switch c.PasswordHashing.Algorithm {
+	case "bcrypt", "argon2id":
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q", c.PasswordHashing.Algorithm)
+	}
+	if c.PasswordHashing.BcryptCost < bcrypt.MinCost || c.PasswordHashing.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt_cost should be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	a := c.PasswordHashing.Argon2id
+	if a.MemoryKiB == 0 || a.Iterations == 0 || a.Parallelism == 0 || a.SaltLength < 8 || a.KeyLength < 16 {
+		return errors.New("argon2id parameters should be positive with salt_length >= 8 and key_length >= 16")
+	}
+	switch c.Mail.Backend {
+	case MailBackendSmtp:
+		if c.Mail.Smtp.Host == "" {
+			return errors.New("smtp mail backend needs a host")
+		}
+	case MailBackendFile:
+		if c.Mail.Dir == "" {
+			return errors.New("file mail backend needs a dir")
+		}
+	case MailBackendMemory:
+	default:
+		return fmt.Errorf("unknown mail backend %q", c.Mail.Backend)
+	}
+	if c.Mail.VerificationTokenLifetime.Duration <= 0 || c.Mail.ResetTokenLifetime.Duration <= 0 {
+		return errors.New("mail token lifetimes should be positive")
+	}
+	if c.TwoFactor.ChallengeLifetime.Duration <= 0 {
+		return errors.New("two-factor challenge lifetime should be positive")
+	}
+	if c.Oidc.RequestLifetime.Duration <= 0 {
+		return errors.New("oidc request lifetime should be positive")
+	}
+	if c.Teams.InvitationLifetime.Duration <= 0 {
+		return errors.New("team invitation lifetime should be positive")
+	}
+	if c.Webhooks.MaxAttempts <= 0 || c.Webhooks.BaseBackoff.Duration <= 0 || c.Webhooks.PollInterval.Duration <= 0 {
+		return errors.New("webhook max_attempts, base_backoff and poll_interval should be positive")
+	}
+	if c.Webhooks.MaxBackoff.Duration < c.Webhooks.BaseBackoff.Duration {
+		return errors.New("webhook max_backoff should not be less than base_backoff")
+	}
+	if c.Linters.Timeout.Duration <= 0 || c.Linters.MaxOutput <= 0 || c.Linters.RelintInterval.Duration <= 0 {
+		return errors.New("linter timeout, max_output and relint_interval should be positive")
+	}
+	if c.Linters.RequestTimeout.Duration <= 0 || c.Linters.RequestTimeout.Duration >= WriteTimeout {
+		return fmt.Errorf("lint_request_timeout should be positive and below the write timeout of %v", WriteTimeout)
+	}
+	for _, f := range c.Formatters {
+		if f.Name == "" || f.Path == "" || f.File == "" || len(f.Languages) == 0 {
+			return errors.New("formatters need a name, path, file and languages")
+		}
+	}
+	for name, p := range c.Oidc.Providers {
+		if p.Issuer == "" || p.ClientId == "" || p.RedirectUrl == "" {
+			return fmt.Errorf("oidc provider %s needs an issuer, client_id and redirect_url", name)
+		}
+	}
+	for route, l := range c.RateLimits.Routes {
+		for _, limit := range []*Limit{l.PerIp, l.PerAccount} {
+			if limit != nil && (limit.Requests <= 0 || limit.Per.Duration <= 0) {
+				return fmt.Errorf("rate limit for %s should have positive requests and period", route)
+			}
+		}
+	}
+	return nil
+}
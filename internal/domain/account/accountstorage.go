@@ -5,20 +5,49 @@ import "errors"
 var (
 	ErrNotFound = errors.New("not found")
 	ErrAlreadyExist = errors.New("already exist")
+	ErrTotpStepUsed = errors.New("totp code was already used")
+	ErrSoleTeamOwner = errors.New("account is the only owner of a team")
 )
 
 type Account struct {
 	Id uint
 	Credentials
+	Email         string
+	EmailVerified bool
+	// TotpSecret is encrypted, the account repos never see it in clear.
+	TotpSecret   string
+	TotpEnabled  bool
+	TotpLastStep int64
 }
 
 type Credentials struct {
-	Login    string
-	Password string
+	Login          string
+	CanonicalLogin string
+	Password       string
 }
 
+type SnippetPolicy int
+
+const (
+	DeleteSnippets SnippetPolicy = iota
+	AnonymiseSnippets
+)
+
 type Interface interface {
 	CreateAccount(cred Credentials) (Account, error)
 	GetAccountById(id uint) (Account, error)
-	GetAccountByLogin(login string) (Account, error)
-}
\ No newline at end of file
+	GetAccountByLogin(canonicalLogin string) (Account, error)
+	UpdatePassword(id uint, password string) error
+	SetEmail(id uint, email string) error
+	VerifyEmail(id uint, email string) error
+	GetAccountByVerifiedEmail(email string) (Account, error)
+	SetTotp(id uint, secret string, enabled bool) error
+	// UseTotpStep records the time step of an accepted code and returns
+	// ErrTotpStepUsed unless it is later than the last recorded one.
+	UseTotpStep(id uint, step int64) error
+	SetRecoveryCodes(id uint, hashes []string) error
+	UseRecoveryCode(id uint, hash string) error
+	// DeleteAccount fails with ErrSoleTeamOwner while a team would be left
+	// without an owner.
+	DeleteAccount(id uint, snippets SnippetPolicy) error
+}
@@ -0,0 +1,29 @@
+package apitoken
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("api token not found")
+)
+
+type ApiToken struct {
+	Id         uint
+	Uid        uint
+	Name       string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+type Interface interface {
+	CreateApiToken(t ApiToken) (ApiToken, error)
+	GetApiTokenByHash(hash string) (ApiToken, error)
+	GetApiTokensByUser(uid uint) ([]ApiToken, error)
+	TouchApiToken(id uint, lastUsedAt time.Time) error
+	DeleteApiToken(uid, id uint) error
+	DeleteApiTokensByUser(uid uint) error
+}
@@ -0,0 +1,28 @@
+package challenge
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("challenge not found")
+)
+
+// Challenge is a sign in that passed the password check and waits for the
+// second factor.
+type Challenge struct {
+	Hash      string
+	Uid       uint
+	Device    string
+	Failures  int
+	ExpiresAt time.Time
+}
+
+type Interface interface {
+	CreateChallenge(c Challenge) error
+	GetChallenge(hash string) (Challenge, error)
+	RecordFailure(hash string) (int, error)
+	DeleteChallenge(hash string) error
+	DeleteExpiredChallenges() error
+}
@@ -4,19 +4,78 @@ import (
 	"time"
 )
 
+// LintStatus is how far linting of a snippet got.
+type LintStatus string
+
+const (
+	LintPending     LintStatus = "pending"
+	LintRunning     LintStatus = "running"
+	LintPassed      LintStatus = "passed"
+	LintFindings    LintStatus = "findings"
+	LintFailed      LintStatus = "failed"
+	LintUnsupported LintStatus = "unsupported"
+	LintSkipped     LintStatus = "skipped"
+)
+
+// Done tells whether linting ended, one way or another.
+func (s LintStatus) Done() bool {
+	return s != LintPending && s != LintRunning
+}
+
+// Lint is the state of linting a snippet. The timestamps are zero until
+// linting starts and ends, a pending lint has not started: it counts from
+// when the snippet was created when telling whether it is stuck.
+type Lint struct {
+	Status        LintStatus
+	Linter        string
+	LinterVersion string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+	Error         string
+}
+
 type CodeSnippet struct {
-	Code      string
-	Lang      string
-	IsChecked bool
-	Message   string
-	Lifetime  time.Duration
+	Code string
+	Lang string
+	Lint Lint
+	// Message lists the findings once linting is done.
+	Message string
+	// LintOptions are the linter options the snippet is linted with, keyed
+	// by linter name and then by option name.
+	LintOptions map[string]map[string]string
+	Lifetime    time.Duration
+	Uid         uint
+	HasUser     bool
+	Tid         uint
+	HasTeam     bool
+	// CreatedAt is set by the storage.
+	CreatedAt time.Time
+}
+
+// ExpiredSnippet tells whose snippet was removed for outliving its lifetime.
+type ExpiredSnippet struct {
+	Sid     uint
+	Uid     uint
+	HasUser bool
+	Tid     uint
+	HasTeam bool
 }
 
 type Interface interface {
 	CreateCodeSnippet(s CodeSnippet) (uint, error)
 	CreateCodeSnippetWithUser(s CodeSnippet, uid uint) (uint, error)
+	CreateCodeSnippetWithTeam(s CodeSnippet, uid, tid uint) (uint, error)
 	GetCodeSnippetById(sid uint) (CodeSnippet, error)
-	GetMyCodeSnippetIds(uid uint) ([]uint, error)
-	DeleteExpiredSnippets() error
-	SetCodeLinterMessage(sid uint, msg string) error
+	// GetMyCodeSnippetIds lists at most limit snippets of uid by id,
+	// skipping the first offset.
+	GetMyCodeSnippetIds(uid uint, limit, offset int) ([]uint, error)
+	GetTeamCodeSnippetIds(tid uint) ([]uint, error)
+	DeleteCodeSnippet(sid uint, uid uint) error
+	DeleteTeamCodeSnippet(sid uint, tid uint) error
+	DeleteExpiredSnippets() ([]ExpiredSnippet, error)
+	SetLint(sid uint, l Lint, msg string) error
+	// GetStaleLintSnippetIds returns the live snippets in the language, of
+	// any case, that are done linting with other linters or versions, or
+	// that are pending or running since before stuckBefore.
+	GetStaleLintSnippetIds(lang string, linter string, version string, stuckBefore time.Time) ([]uint, error)
 }
@@ -0,0 +1,34 @@
+package comment
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("comment not found")
+)
+
+// Comment is anchored to the lines StartLine through EndLine of a snippet.
+// Replies carry the anchor of the comment they answer.
+type Comment struct {
+	Id        uint
+	Sid       uint
+	Uid       uint
+	ParentId  uint
+	HasParent bool
+	StartLine int
+	EndLine   int
+	Body      string
+	Hidden    bool
+	CreatedAt time.Time
+}
+
+type Interface interface {
+	CreateComment(c Comment) (Comment, error)
+	GetCommentById(id uint) (Comment, error)
+	GetCommentsBySnippet(sid uint) ([]Comment, error)
+	SetCommentHidden(id uint, hidden bool) error
+	// DeleteComment removes the comment together with its replies.
+	DeleteComment(id uint) error
+}
@@ -0,0 +1,34 @@
+package emailtoken
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("email token not found")
+)
+
+type Purpose string
+
+const (
+	VerifyEmail   Purpose = "verify_email"
+	ResetPassword Purpose = "reset_password"
+)
+
+type Token struct {
+	Hash      string
+	Uid       uint
+	Purpose   Purpose
+	Email     string
+	ExpiresAt time.Time
+}
+
+type Interface interface {
+	CreateToken(t Token) error
+	// ConsumeToken deletes the token and returns it, so that it can be used
+	// only once. An expired token is reported as ErrNotFound.
+	ConsumeToken(hash string, purpose Purpose) (Token, error)
+	DeleteTokensByUser(uid uint, purpose Purpose) error
+	DeleteExpiredTokens() error
+}
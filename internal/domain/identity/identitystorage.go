@@ -0,0 +1,28 @@
+package identity
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("identity not found")
+	ErrAlreadyExist = errors.New("identity is already linked")
+)
+
+// Identity links an account to the subject of an external OpenID Connect
+// provider.
+type Identity struct {
+	Provider  string
+	Subject   string
+	Uid       uint
+	Email     string
+	CreatedAt time.Time
+}
+
+type Interface interface {
+	CreateIdentity(i Identity) error
+	GetIdentity(provider, subject string) (Identity, error)
+	GetIdentitiesByUser(uid uint) ([]Identity, error)
+	DeleteIdentity(uid uint, provider string) error
+}
@@ -0,0 +1,26 @@
+package lintevent
+
+type Status string
+
+const (
+	Queued  Status = "queued"
+	Running Status = "running"
+	Checked Status = "checked"
+)
+
+// Event tells that linting of a snippet reached a status. It carries no
+// result, which subscribers read from the snippet itself.
+type Event struct {
+	Sid    uint
+	Status Status
+}
+
+type Interface interface {
+	// Publish fans the event out to the subscribers of the snippet,
+	// wherever they are connected.
+	Publish(e Event) error
+	// Subscribe delivers the events of the snippet until cancel is called.
+	// A subscriber that falls behind misses events rather than blocks
+	// publishers.
+	Subscribe(sid uint) (events <-chan Event, cancel func())
+}
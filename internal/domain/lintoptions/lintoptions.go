@@ -0,0 +1,10 @@
+package lintoptions
+
+// Interface keeps the linter options each account lints with by default,
+// keyed by linter name and then by option name.
+type Interface interface {
+	// GetDefaultLintOptions returns no options for an account that has not
+	// set any.
+	GetDefaultLintOptions(uid uint) (map[string]map[string]string, error)
+	SetDefaultLintOptions(uid uint, opts map[string]map[string]string) error
+}
@@ -0,0 +1,22 @@
+package loginattempt
+
+import (
+	"time"
+)
+
+type Attempts struct {
+	Key         string
+	Failures    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+type Interface interface {
+	GetAttempts(key string) (Attempts, error)
+	// RecordFailure counts a failed attempt and returns the number of
+	// failures in a row. The count starts over once the previous failure is
+	// older than window.
+	RecordFailure(key string, window time.Duration) (int, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
@@ -0,0 +1,31 @@
+package oidcrequest
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("authorization request not found")
+)
+
+// Request is an authorization request sent to a provider and waiting for
+// its callback. LinkUid is set when a signed in user links an identity.
+type Request struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	Device       string
+	Link         bool
+	LinkUid      uint
+	ExpiresAt    time.Time
+}
+
+type Interface interface {
+	CreateRequest(r Request) error
+	// ConsumeRequest deletes the request and returns it. An expired request
+	// is reported as ErrNotFound.
+	ConsumeRequest(stateHash string) (Request, error)
+	DeleteExpiredRequests() error
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Rule is a token bucket refilled with Requests tokens every Per and holding
+// at most Burst tokens.
+type Rule struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+func (r Rule) RatePerSecond() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+func (r Rule) Capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Requests)
+}
+
+func (r Rule) IsZero() bool {
+	return r.Requests <= 0 || r.Per <= 0
+}
+
+// RetryAfter is how long it takes to refill the bucket up to one token.
+func (r Rule) RetryAfter(tokens float64) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / r.RatePerSecond() * float64(time.Second))
+}
+
+type Interface interface {
+	Take(key string, rule Rule) (bool, time.Duration, error)
+	// Peek tells what Take would, without taking a token.
+	Peek(key string, rule Rule) (bool, time.Duration, error)
+	DeleteIdleBuckets(idle time.Duration) error
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+)
+
+type Session struct {
+	Id               string
+	Uid              uint
+	Device           string
+	RefreshTokenHash string
+	AccessTokenId    string
+	AccessExpiresAt  time.Time
+	CreatedAt        time.Time
+	LastUsedAt       time.Time
+	ExpiresAt        time.Time
+}
+
+type Interface interface {
+	CreateSession(s Session) error
+	GetSessionById(id string) (Session, error)
+	GetSessionByRefreshToken(hash string) (Session, error)
+	GetSessionsByUser(uid uint) ([]Session, error)
+	RotateSession(oldHash string, s Session) error
+	DeleteSession(id string) error
+	DeleteExpiredSessions() error
+
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+}
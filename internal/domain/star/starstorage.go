@@ -0,0 +1,13 @@
+package star
+
+type Interface interface {
+	// Star and Unstar report whether the star was actually added or removed,
+	// and keep the star count of the snippet in step.
+	Star(uid, sid uint) (bool, error)
+	Unstar(uid, sid uint) (bool, error)
+	IsStarred(uid, sid uint) (bool, error)
+	GetStarCount(sid uint) (uint, error)
+	// GetStarredSnippetIds lists at most limit snippets starred by uid, the
+	// most recently starred first, skipping the first offset.
+	GetStarredSnippetIds(uid uint, limit, offset int) ([]uint, error)
+}
@@ -0,0 +1,85 @@
+package team
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound           = errors.New("team not found")
+	ErrAlreadyExist       = errors.New("team already exists")
+	ErrMemberNotFound     = errors.New("team member not found")
+	ErrMemberAlreadyExist = errors.New("already a team member")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrLastOwner          = errors.New("team would be left without an owner")
+)
+
+type Role string
+
+const (
+	Owner      Role = "owner"
+	Maintainer Role = "maintainer"
+	Member     Role = "member"
+)
+
+var roleRanks = map[Role]int{
+	Member:     1,
+	Maintainer: 2,
+	Owner:      3,
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+func (r Role) AtLeast(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+type Team struct {
+	Id        uint
+	Name      string
+	CreatedAt time.Time
+}
+
+type Membership struct {
+	Tid      uint
+	Uid      uint
+	Role     Role
+	JoinedAt time.Time
+}
+
+type Invitation struct {
+	Id        uint
+	Tid       uint
+	Uid       uint
+	Role      Role
+	InvitedBy uint
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Interface interface {
+	// CreateTeam creates the team with uid as its owner.
+	CreateTeam(name string, uid uint) (Team, error)
+	GetTeamById(tid uint) (Team, error)
+	GetTeamsByUser(uid uint) ([]Team, []Membership, error)
+	// DeleteTeam removes the team together with its members, invitations
+	// and snippets.
+	DeleteTeam(tid uint) error
+
+	GetMembership(tid, uid uint) (Membership, error)
+	GetMembers(tid uint) ([]Membership, error)
+	// SetMemberRole and DeleteMember fail with ErrLastOwner rather than
+	// leave the team without an owner.
+	SetMemberRole(tid, uid uint, role Role) error
+	DeleteMember(tid, uid uint) error
+
+	CreateInvitation(i Invitation) (Invitation, error)
+	GetInvitationById(id uint) (Invitation, error)
+	GetInvitationsByUser(uid uint) ([]Invitation, error)
+	// AcceptInvitation turns a pending invitation into a membership.
+	AcceptInvitation(id uint) (Membership, error)
+	DeleteInvitation(id uint) error
+}
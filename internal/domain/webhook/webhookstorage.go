@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type Event string
+
+const (
+	SnippetCreated Event = "snippet.created"
+	SnippetChecked Event = "snippet.checked"
+	SnippetExpired Event = "snippet.expired"
+	SnippetDeleted Event = "snippet.deleted"
+)
+
+var Events = []Event{SnippetCreated, SnippetChecked, SnippetExpired, SnippetDeleted}
+
+type Webhook struct {
+	Id        uint
+	Uid       uint
+	Url       string
+	Secret    string
+	Events    []Event
+	CreatedAt time.Time
+}
+
+func (w Webhook) Subscribes(e Event) bool {
+	for _, s := range w.Events {
+		if s == e {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryState string
+
+const (
+	Pending   DeliveryState = "pending"
+	Succeeded DeliveryState = "succeeded"
+	Failed    DeliveryState = "failed"
+)
+
+// Delivery is one event sent to one webhook, with the outcome of its last
+// attempt.
+type Delivery struct {
+	Id            uint
+	WebhookId     uint
+	Event         Event
+	Payload       []byte
+	State         DeliveryState
+	Attempts      int
+	StatusCode    int
+	Error         string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Interface interface {
+	CreateWebhook(w Webhook) (Webhook, error)
+	GetWebhookById(id uint) (Webhook, error)
+	GetWebhooksByUser(uid uint) ([]Webhook, error)
+	// DeleteWebhook removes the webhook together with its deliveries.
+	DeleteWebhook(uid, id uint) error
+
+	CreateDelivery(d Delivery) (Delivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now
+	// and postpones them until now+lease, so that concurrent workers do not
+	// send them twice.
+	ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]Delivery, error)
+	UpdateDelivery(d Delivery) error
+	// GetDeliveriesByWebhook lists the deliveries of a webhook, the newest
+	// first.
+	GetDeliveriesByWebhook(id uint, limit int) ([]Delivery, error)
+}
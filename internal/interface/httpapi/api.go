@@ -1,28 +1,59 @@
 package httpapi
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	repository "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	codesnippetrepository "github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	commentrepository "github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	teamrepository "github.com/mp-hl-2021/code-swamp/internal/domain/team"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/prom"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passwordpolicy"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/team"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 )
 
 const (
-	accountIdContextKey = "account_id"
-	snippetIdUrlPathKey = "snippet_id"
+	accountIdContextKey    = "account_id"
+	tokenContextKey        = "token"
+	snippetIdUrlPathKey    = "snippet_id"
+	sessionIdUrlPathKey    = "session_id"
+	apiTokenIdUrlPathKey   = "token_id"
+	providerUrlPathKey     = "provider"
+	teamIdUrlPathKey       = "team_id"
+	memberIdUrlPathKey     = "member_id"
+	invitationIdUrlPathKey = "invitation_id"
+	commentIdUrlPathKey    = "comment_id"
+	webhookIdUrlPathKey    = "webhook_id"
 )
 
 type Api struct {
 	AccountUseCases     account.Interface
 	CodeSnippetUseCases codesnippet.Interface
+	TeamUseCases        team.Interface
+	WebhookUseCases     webhook.Interface
+	RateLimits          *RateLimits
+	TrustForwardedFor   bool
+	// TrustedProxies are the only peers X-Forwarded-For is taken from.
+	TrustedProxies []*net.IPNet
+	// EventStreamLifetime bounds Server-Sent Event streams, it should stay
+	// below the server write timeout.
+	EventStreamLifetime time.Duration
 }
 
 func NewApi(a account.Interface, c codesnippet.Interface) *Api {
@@ -38,13 +69,76 @@ func (a *Api) Router() http.Handler {
 	router.Use(prom.Measurer())
 	router.Use(a.logger)
 
-	router.HandleFunc("/signup", a.postSignup).Methods(http.MethodPost)
-	router.HandleFunc("/signin", a.postSignin).Methods(http.MethodPost)
+	router.HandleFunc("/signup", a.rateLimit(signupRoute, a.postSignup)).Methods(http.MethodPost)
+	router.HandleFunc("/signin", a.rateLimit(signinRoute, a.postSignin)).Methods(http.MethodPost)
+	router.HandleFunc("/signin/2fa", a.rateLimit(signinRoute, a.postSigninSecondFactor)).Methods(http.MethodPost)
+	router.HandleFunc("/oidc/{"+providerUrlPathKey+"}/start", a.authenticateOrNot(a.postOidcStart)).Methods(http.MethodPost)
+	router.HandleFunc("/oidc/{"+providerUrlPathKey+"}/callback", a.rateLimit(signinRoute, a.getOidcCallback)).Methods(http.MethodGet)
+	router.HandleFunc("/refresh", a.rateLimit(refreshRoute, a.postRefresh)).Methods(http.MethodPost)
+	router.HandleFunc("/signout", a.authenticate(a.postSignout)).Methods(http.MethodPost)
 
-	router.HandleFunc("/myswamp", a.authenticate(a.postLinks)).Methods(http.MethodPost)
-	router.HandleFunc("/", a.authenticateOrNot(a.postCode)).Methods(http.MethodPost)
+	router.HandleFunc("/me/password", a.authenticate(a.postPassword)).Methods(http.MethodPost)
+	router.HandleFunc("/me", a.authenticate(a.getMe)).Methods(http.MethodGet)
+	router.HandleFunc("/me", a.authenticate(a.deleteMe)).Methods(http.MethodDelete)
+	router.HandleFunc("/me/email", a.authenticate(a.rateLimit(emailRoute, a.putEmail))).Methods(http.MethodPut)
+	router.HandleFunc("/me/totp", a.authenticate(a.postTotp)).Methods(http.MethodPost)
+	router.HandleFunc("/me/totp", a.authenticate(a.deleteTotp)).Methods(http.MethodDelete)
+	router.HandleFunc("/me/totp/confirm", a.authenticate(a.postTotpConfirm)).Methods(http.MethodPost)
+	router.HandleFunc("/me/totp/recovery-codes", a.authenticate(a.postRecoveryCodes)).Methods(http.MethodPost)
+	router.HandleFunc("/me/lint-options", a.authenticate(a.getLintOptions)).Methods(http.MethodGet)
+	router.HandleFunc("/me/lint-options", a.authenticate(a.putLintOptions)).Methods(http.MethodPut)
+	router.HandleFunc("/email/verify", a.rateLimit(emailRoute, a.postVerifyEmail)).Methods(http.MethodPost)
+	router.HandleFunc("/password/forgot", a.rateLimit(passwordRoute, a.postForgotPassword)).Methods(http.MethodPost)
+	router.HandleFunc("/password/reset", a.rateLimit(passwordRoute, a.postResetPassword)).Methods(http.MethodPost)
+
+	router.HandleFunc("/identities", a.authenticate(a.getIdentities)).Methods(http.MethodGet)
+	router.HandleFunc("/identities/{"+providerUrlPathKey+"}", a.authenticate(a.deleteIdentity)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/sessions", a.authenticate(a.getSessions)).Methods(http.MethodGet)
+	router.HandleFunc("/sessions/{"+sessionIdUrlPathKey+"}", a.authenticate(a.deleteSession)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/tokens", a.authenticate(a.postApiToken)).Methods(http.MethodPost)
+	router.HandleFunc("/tokens", a.authenticate(a.getApiTokens)).Methods(http.MethodGet)
+	router.HandleFunc("/tokens/{"+apiTokenIdUrlPathKey+"}", a.authenticate(a.deleteApiToken)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/teams", a.authenticate(a.postTeam)).Methods(http.MethodPost)
+	router.HandleFunc("/teams", a.authenticate(a.getTeams)).Methods(http.MethodGet)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}", a.authenticate(a.getTeam)).Methods(http.MethodGet)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}", a.authenticate(a.deleteTeam)).Methods(http.MethodDelete)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}/invitations", a.authenticate(a.postInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}/members/{"+memberIdUrlPathKey+"}", a.authenticate(a.putMember)).Methods(http.MethodPut)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}/members/{"+memberIdUrlPathKey+"}", a.authenticate(a.deleteMember)).Methods(http.MethodDelete)
+	router.HandleFunc("/teams/{"+teamIdUrlPathKey+"}/snippets", a.authenticate(a.getTeamLinks, account.ScopeSnippetsRead)).Methods(http.MethodGet)
+	router.HandleFunc("/invitations", a.authenticate(a.getInvitations)).Methods(http.MethodGet)
+	router.HandleFunc("/invitations/{"+invitationIdUrlPathKey+"}/accept", a.authenticate(a.postAcceptInvitation)).Methods(http.MethodPost)
+	router.HandleFunc("/invitations/{"+invitationIdUrlPathKey+"}", a.authenticate(a.deleteInvitation)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/webhooks", a.authenticate(a.postWebhook)).Methods(http.MethodPost)
+	router.HandleFunc("/webhooks", a.authenticate(a.getWebhooks)).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{"+webhookIdUrlPathKey+"}", a.authenticate(a.deleteWebhook)).Methods(http.MethodDelete)
+	router.HandleFunc("/webhooks/{"+webhookIdUrlPathKey+"}/deliveries", a.authenticate(a.getDeliveries)).Methods(http.MethodGet)
+
+	router.HandleFunc("/myswamp", a.authenticate(a.postLinks, account.ScopeSnippetsRead)).Methods(http.MethodPost)
+	router.HandleFunc("/", a.authenticateOrNot(a.rateLimit(postCodeRoute, a.postCode), account.ScopeSnippetsWrite)).Methods(http.MethodPost)
+	router.HandleFunc("/lint", a.authenticateOrNot(a.rateLimit(lintRoute, a.postLint), account.ScopeSnippetsWrite)).Methods(http.MethodPost)
 
 	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}", a.getCode).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}", a.authenticate(a.deleteCode, account.ScopeSnippetsDelete)).Methods(http.MethodDelete)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/events", a.getCodeEvents).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/canonical", a.getCanonicalCode).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/formatted", a.rateLimit(lintRoute, a.getFormattedCode)).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/relint", a.authenticate(a.rateLimit(lintRoute, a.postRelint), account.ScopeSnippetsWrite)).Methods(http.MethodPost)
+	router.HandleFunc("/admin/relint", a.authenticate(a.postRelintStale)).Methods(http.MethodPost)
+
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/star", a.authenticate(a.putStar, account.ScopeSnippetsWrite)).Methods(http.MethodPut)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/star", a.authenticate(a.deleteStar, account.ScopeSnippetsWrite)).Methods(http.MethodDelete)
+	router.HandleFunc("/stars", a.authenticate(a.getStars, account.ScopeSnippetsRead)).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/comments", a.authenticate(a.rateLimit(commentRoute, a.postComment), account.ScopeSnippetsWrite)).Methods(http.MethodPost)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/comments", a.authenticateOrNot(a.getComments, account.ScopeSnippetsRead)).Methods(http.MethodGet)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/comments/{"+commentIdUrlPathKey+"}/hidden", a.authenticate(a.putCommentHidden, account.ScopeSnippetsWrite)).Methods(http.MethodPut)
+	router.HandleFunc("/toad/{"+snippetIdUrlPathKey+"}/comments/{"+commentIdUrlPathKey+"}", a.authenticate(a.deleteComment, account.ScopeSnippetsDelete)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/.well-known/jwks.json", a.getJwks).Methods(http.MethodGet)
 
 	router.Handle("/metrics", promhttp.Handler())
 
@@ -56,6 +150,24 @@ type PostSignupRequestModel struct {
 	Password string `json:"password"`
 }
 
+type ValidationErrorResponseModel struct {
+	Errors []string `json:"errors"`
+}
+
+func writePasswordPolicyError(w http.ResponseWriter, e *passwordpolicy.ValidationError) {
+	m := ValidationErrorResponseModel{
+		Errors: make([]string, len(e.Violations)),
+	}
+	for i, v := range e.Violations {
+		m.Errors[i] = v.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		return
+	}
+}
+
 func (a *Api) postSignup(w http.ResponseWriter, r *http.Request) {
 	var m PostSignupRequestModel
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
@@ -65,17 +177,19 @@ func (a *Api) postSignup(w http.ResponseWriter, r *http.Request) {
 
 	_, err := a.AccountUseCases.CreateAccount(m.Login, m.Password)
 	if err != nil {
+		var policyErr *passwordpolicy.ValidationError
+		if errors.As(err, &policyErr) {
+			writePasswordPolicyError(w, policyErr)
+			return
+		}
 		var statusCode int
 		switch err {
 		case
 			account.ErrInvalidLoginString,
 			account.ErrInvalidLoginString2,
-			account.ErrInvalidPasswordString,
+			account.ErrConfusableLogin,
 			account.ErrTooShortString,
 			account.ErrTooLongString,
-			account.ErrNoDigits,
-			account.ErrNoUpperCaseLetters,
-			account.ErrNoLowerCaseLetters,
 			repository.ErrAlreadyExist:
 
 			statusCode = http.StatusBadRequest
@@ -90,21 +204,61 @@ func (a *Api) postSignup(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+type PostSigninRequestModel struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Device   string `json:"device,omitempty"`
+}
+
+type TokensResponseModel struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func writeTokens(w http.ResponseWriter, tokens account.Tokens) {
+	m := TokensResponseModel{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(tokens.ExpiresAt).Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
 func (a *Api) postSignin(w http.ResponseWriter, r *http.Request) {
-	var m PostSignupRequestModel
+	var m PostSigninRequestModel
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	device := m.Device
+	if device == "" {
+		device = r.UserAgent()
+	}
 
-	token, err := a.AccountUseCases.LoginToAccount(m.Login, m.Password)
+	tokens, err := a.AccountUseCases.LoginToAccount(m.Login, m.Password, device, a.clientIp(r))
 	if err != nil {
+		var lockedOut *account.LockedOutError
+		if errors.As(err, &lockedOut) {
+			writeLockedOut(w, lockedOut)
+			return
+		}
+		var challenge *account.ChallengeRequiredError
+		if errors.As(err, &challenge) {
+			writeChallenge(w, challenge)
+			return
+		}
 		var statusCode int
 		switch err {
 
 		case
-			account.ErrInvalidLogin,
-			account.ErrInvalidPassword:
+			account.ErrInvalidCredentials:
 
 			statusCode = http.StatusUnauthorized
 		default:
@@ -114,76 +268,302 @@ func (a *Api) postSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/jwt")
-	if _, err = w.Write([]byte(token)); err != nil {
+	writeTokens(w, tokens)
+}
+
+func writeLockedOut(w http.ResponseWriter, e *account.LockedOutError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+type ChallengeResponseModel struct {
+	Challenge string `json:"challenge"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+func writeChallenge(w http.ResponseWriter, c *account.ChallengeRequiredError) {
+	m := ChallengeResponseModel{
+		Challenge: c.Challenge,
+		ExpiresIn: int64(time.Until(c.ExpiresAt).Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(m); err != nil {
 		return
 	}
 }
 
-type PostLinksResponseModel struct {
-	Links []string `json:"links"`
+type PostSecondFactorRequestModel struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
 }
 
-func (a *Api) postLinks(w http.ResponseWriter, r *http.Request) {
-	aid, ok := r.Context().Value(accountIdContextKey).(uint)
-	if !ok {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) postSigninSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var m PostSecondFactorRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	acc, err := a.AccountUseCases.GetAccountById(aid)
+
+	tokens, err := a.AccountUseCases.CompleteSignin(m.Challenge, m.Code, a.clientIp(r))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		var lockedOut *account.LockedOutError
+		if errors.As(err, &lockedOut) {
+			writeLockedOut(w, lockedOut)
+			return
+		}
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidChallenge,
+			account.ErrInvalidTotpCode:
+
+			statusCode = http.StatusUnauthorized
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
 		return
 	}
 
-	ss, err := a.CodeSnippetUseCases.GetMySnippetIds(acc)
+	writeTokens(w, tokens)
+}
+
+type OidcStartResponseModel struct {
+	AuthorizationUrl string `json:"authorization_url"`
+}
+
+const oidcStateCookie = "oidc_state"
+
+// oidcStateBinding is what the state cookie holds, the state itself stays out
+// of the browser storage.
+func oidcStateBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func setOidcStateCookie(w http.ResponseWriter, provider, value string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/oidc/" + provider + "/callback",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		// Lax, the provider redirects back with a top level navigation
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
+
+func (a *Api) postOidcStart(w http.ResponseWriter, r *http.Request) {
+	var linkAid *uint
+	if aid, ok := r.Context().Value(accountIdContextKey).(uint); ok {
+		linkAid = &aid
+	}
+	provider := mux.Vars(r)[providerUrlPathKey]
+	start, err := a.AccountUseCases.StartOidcLogin(provider, linkAid, r.UserAgent())
 	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrUnknownProvider:
+
+			statusCode = http.StatusNotFound
+		case
+			account.ErrOidcUnavailable:
+
+			statusCode = http.StatusBadGateway
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	setOidcStateCookie(w, provider, oidcStateBinding(start.State), start.ExpiresAt)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(OidcStartResponseModel{AuthorizationUrl: start.AuthorizationUrl}); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	mm := PostLinksResponseModel{
-		Links: make([]string, len(ss)),
+}
+
+func (a *Api) getOidcCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	provider := mux.Vars(r)[providerUrlPathKey]
+	// the callback is only good for the browser that started the sign in
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(oidcStateBinding(q.Get("state")))) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	for i := range ss {
-		mm.Links[i] = fmt.Sprintf("/toad/%d", ss[i])
+	setOidcStateCookie(w, provider, "", time.Time{})
+	if q.Get("error") != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
-	if err := json.NewEncoder(w).Encode(mm); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	res, err := a.AccountUseCases.FinishOidcLogin(provider, q.Get("state"), q.Get("code"))
+	if err != nil {
+		var challenge *account.ChallengeRequiredError
+		if errors.As(err, &challenge) {
+			writeChallenge(w, challenge)
+			return
+		}
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidOidcState:
+
+			statusCode = http.StatusBadRequest
+		case
+			account.ErrUnknownProvider:
+
+			statusCode = http.StatusNotFound
+		case
+			account.ErrOidcFailed:
+
+			statusCode = http.StatusUnauthorized
+		case
+			account.ErrOidcUnavailable:
+
+			statusCode = http.StatusBadGateway
+		case
+			account.ErrIdentityTaken:
+
+			statusCode = http.StatusConflict
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
 		return
 	}
+	if res.Linked {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeTokens(w, res.Tokens)
 }
 
-type PostCodeRequestModel struct {
-	Code     string        `json:"code"`
-	Lang     string        `json:"lang"`
-	Lifetime time.Duration `json:"lifetime"`
+type PostRefreshRequestModel struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
-func (a *Api) postCode(w http.ResponseWriter, r *http.Request) {
-	var m PostCodeRequestModel
+func (a *Api) postRefresh(w http.ResponseWriter, r *http.Request) {
+	var m PostRefreshRequestModel
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	var acc *account.Account = nil
+
+	tokens, err := a.AccountUseCases.RefreshTokens(m.RefreshToken)
+	if err != nil {
+		var statusCode int
+		switch err {
+
+		case
+			account.ErrInvalidRefreshToken:
+
+			statusCode = http.StatusUnauthorized
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	writeTokens(w, tokens)
+}
+
+func (a *Api) postSignout(w http.ResponseWriter, r *http.Request) {
+	token, ok := r.Context().Value(tokenContextKey).(string)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := a.AccountUseCases.Logout(token); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Println(err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type PostPasswordRequestModel struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (a *Api) postPassword(w http.ResponseWriter, r *http.Request) {
 	aid, ok := r.Context().Value(accountIdContextKey).(uint)
-	if ok {
-		a, err := a.AccountUseCases.GetAccountById(aid)
-		acc = &a
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PostPasswordRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.ChangePassword(aid, m.CurrentPassword, m.NewPassword); err != nil {
+		var policyErr *passwordpolicy.ValidationError
+		if errors.As(err, &policyErr) {
+			writePasswordPolicyError(w, policyErr)
 			return
 		}
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidPassword:
+
+			statusCode = http.StatusUnauthorized
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
 	}
-	id, err := a.CodeSnippetUseCases.CreateSnippet(acc, m.Code, m.Lang, m.Lifetime)
-	if err != nil {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const (
+	deleteSnippetsQueryKey  = "snippets"
+	deleteSnippetsDelete    = "delete"
+	deleteSnippetsAnonymise = "anonymise"
+)
+
+func (a *Api) deleteMe(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var deleteSnippets bool
+	switch r.URL.Query().Get(deleteSnippetsQueryKey) {
+	case deleteSnippetsDelete:
+		deleteSnippets = true
+	case deleteSnippetsAnonymise:
+		deleteSnippets = false
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.DeleteAccount(aid, deleteSnippets); err != nil {
 		var statusCode int
 		switch err {
+		case
+			repository.ErrNotFound:
 
+			statusCode = http.StatusNotFound
 		case
-			account.ErrInvalidLanguage:
+			repository.ErrSoleTeamOwner:
 
-			statusCode = http.StatusBadRequest
+			statusCode = http.StatusConflict
 		default:
 			statusCode = http.StatusInternalServerError
 		}
@@ -191,45 +571,1441 @@ func (a *Api) postCode(w http.ResponseWriter, r *http.Request) {
 		fmt.Println(err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	location := fmt.Sprintf("/toad/%d", id)
-	w.Header().Set("Location", location)
-	w.WriteHeader(http.StatusCreated)
+type AccountResponseModel struct {
+	Id            uint   `json:"id"`
+	Login         string `json:"login"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	TotpEnabled   bool   `json:"totp_enabled"`
 }
 
-func (a *Api) getCode(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	s, ok := vars[snippetIdUrlPathKey]
+func (a *Api) getMe(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
 	if !ok {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	sid, err := strconv.ParseUint(s, 10, 64)
+	acc, err := a.AccountUseCases.GetAccountById(aid)
 	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m := AccountResponseModel{
+		Id:            acc.Id,
+		Login:         acc.Login,
+		Email:         acc.Email,
+		EmailVerified: acc.EmailVerified,
+		TotpEnabled:   acc.TotpEnabled,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+type PutEmailRequestModel struct {
+	Email string `json:"email"`
+}
+
+func (a *Api) putEmail(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PutEmailRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	ss, err := a.CodeSnippetUseCases.GetSnippetById(uint(sid))
-	if err != nil {
+	if err := a.AccountUseCases.SetEmail(aid, m.Email); err != nil {
 		var statusCode int
 		switch err {
+		case
+			account.ErrInvalidEmail:
 
+			statusCode = http.StatusBadRequest
 		case
-			codesnippetrepo.ErrInvalidSnippedId:
+			account.ErrMailerUnavailable:
+
+			statusCode = http.StatusServiceUnavailable
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type PostEmailTokenRequestModel struct {
+	Token string `json:"token"`
+}
+
+func (a *Api) postVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var m PostEmailTokenRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.VerifyEmail(m.Token); err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidEmailToken:
 
 			statusCode = http.StatusBadRequest
+		case
+			account.ErrEmailTaken:
+
+			statusCode = http.StatusConflict
 		default:
 			statusCode = http.StatusInternalServerError
 		}
 		w.WriteHeader(statusCode)
 		return
 	}
-	w.Header().Set("Content-Type", "text/plain")
-	status := ""
-	if !ss.IsChecked {
-		status = "Not checked yet"
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) postForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var m PutEmailRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.RequestPasswordReset(m.Email); err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidEmail:
+
+			statusCode = http.StatusBadRequest
+		case
+			account.ErrMailerUnavailable:
+
+			statusCode = http.StatusServiceUnavailable
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type PostResetPasswordRequestModel struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (a *Api) postResetPassword(w http.ResponseWriter, r *http.Request) {
+	var m PostResetPasswordRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.ResetPassword(m.Token, m.NewPassword); err != nil {
+		var policyErr *passwordpolicy.ValidationError
+		if errors.As(err, &policyErr) {
+			writePasswordPolicyError(w, policyErr)
+			return
+		}
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidEmailToken:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type TotpEnrollmentResponseModel struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+}
+
+func totpStatusCode(err error) int {
+	switch err {
+	case
+		account.ErrTotpAlreadyEnabled,
+		account.ErrTotpNotEnrolled:
+
+		return http.StatusConflict
+	case
+		account.ErrInvalidTotpCode:
+
+		return http.StatusUnauthorized
+	case
+		account.ErrTotpUnavailable:
+
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeTotpError tells how long a throttled account has to wait, like a
+// throttled sign in does.
+func writeTotpError(w http.ResponseWriter, err error) {
+	var lockedOut *account.LockedOutError
+	if errors.As(err, &lockedOut) {
+		writeLockedOut(w, lockedOut)
+		return
+	}
+	w.WriteHeader(totpStatusCode(err))
+}
+
+func (a *Api) postTotp(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	e, err := a.AccountUseCases.EnrollTotp(aid)
+	if err != nil {
+		w.WriteHeader(totpStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	m := TotpEnrollmentResponseModel{
+		Secret:          e.Secret,
+		ProvisioningUri: e.ProvisioningUri,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		return
+	}
+}
+
+type PostTotpCodeRequestModel struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponseModel struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (a *Api) writeRecoveryCodes(w http.ResponseWriter, r *http.Request, generate func(aid uint, code, ip string) ([]string, error)) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PostTotpCodeRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	codes, err := generate(aid, m.Code, a.clientIp(r))
+	if err != nil {
+		writeTotpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(RecoveryCodesResponseModel{RecoveryCodes: codes}); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) postTotpConfirm(w http.ResponseWriter, r *http.Request) {
+	a.writeRecoveryCodes(w, r, a.AccountUseCases.ConfirmTotp)
+}
+
+func (a *Api) postRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	a.writeRecoveryCodes(w, r, a.AccountUseCases.RegenerateRecoveryCodes)
+}
+
+// DeleteTotpRequestModel takes a two-factor or a recovery code.
+type DeleteTotpRequestModel struct {
+	Code string `json:"code"`
+}
+
+func (a *Api) deleteTotp(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m DeleteTotpRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.DisableTotp(aid, m.Code, a.clientIp(r)); err != nil {
+		writeTotpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type IdentityResponseModel struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GetIdentitiesResponseModel struct {
+	Identities []IdentityResponseModel `json:"identities"`
+}
+
+func (a *Api) getIdentities(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ii, err := a.AccountUseCases.GetIdentities(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetIdentitiesResponseModel{
+		Identities: make([]IdentityResponseModel, len(ii)),
+	}
+	for n, i := range ii {
+		mm.Identities[n] = IdentityResponseModel{
+			Provider:  i.Provider,
+			Email:     i.Email,
+			CreatedAt: i.CreatedAt,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteIdentity(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := a.AccountUseCases.UnlinkIdentity(aid, mux.Vars(r)[providerUrlPathKey]); err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrIdentityNotFound:
+
+			statusCode = http.StatusNotFound
+		case
+			account.ErrLastCredential:
+
+			statusCode = http.StatusConflict
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SessionResponseModel struct {
+	Id         string    `json:"id"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type GetSessionsResponseModel struct {
+	Sessions []SessionResponseModel `json:"sessions"`
+}
+
+func (a *Api) getSessions(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ss, err := a.AccountUseCases.GetSessions(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetSessionsResponseModel{
+		Sessions: make([]SessionResponseModel, len(ss)),
+	}
+	for i, s := range ss {
+		mm.Sessions[i] = SessionResponseModel{
+			Id:         s.Id,
+			Device:     s.Device,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteSession(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := mux.Vars(r)[sessionIdUrlPathKey]
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := a.AccountUseCases.RevokeSession(aid, sid); err != nil {
+		var statusCode int
+		switch err {
+
+		case
+			account.ErrSessionNotFound:
+
+			statusCode = http.StatusNotFound
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) getJwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(a.AccountUseCases.GetJwks()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+type PostApiTokenRequestModel struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type ApiTokenResponseModel struct {
+	Id         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Token      string    `json:"token,omitempty"`
+}
+
+func toApiTokenResponseModel(t account.ApiToken) ApiTokenResponseModel {
+	return ApiTokenResponseModel{
+		Id:         t.Id,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func (a *Api) postApiToken(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PostApiTokenRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	plain, t, err := a.AccountUseCases.CreateApiToken(aid, m.Name, m.Scopes)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrInvalidApiTokenName,
+			account.ErrInvalidScope,
+			account.ErrNoScopes:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	mm := toApiTokenResponseModel(t)
+	mm.Token = plain
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/tokens/%d", t.Id))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		return
+	}
+}
+
+type GetApiTokensResponseModel struct {
+	Tokens []ApiTokenResponseModel `json:"tokens"`
+}
+
+func (a *Api) getApiTokens(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ts, err := a.AccountUseCases.GetApiTokens(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetApiTokensResponseModel{
+		Tokens: make([]ApiTokenResponseModel, len(ts)),
+	}
+	for i, t := range ts {
+		mm.Tokens[i] = toApiTokenResponseModel(t)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteApiToken(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, err := strconv.ParseUint(mux.Vars(r)[apiTokenIdUrlPathKey], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.AccountUseCases.RevokeApiToken(aid, uint(id)); err != nil {
+		var statusCode int
+		switch err {
+		case
+			account.ErrApiTokenNotFound:
+
+			statusCode = http.StatusNotFound
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func teamStatusCode(err error) int {
+	switch err {
+	case
+		teamrepository.ErrNotFound,
+		teamrepository.ErrMemberNotFound,
+		teamrepository.ErrInvitationNotFound,
+		repository.ErrNotFound:
+
+		return http.StatusNotFound
+	case
+		team.ErrForbidden:
+
+		return http.StatusForbidden
+	case
+		team.ErrInvalidTeamName,
+		team.ErrInvalidRole:
+
+		return http.StatusBadRequest
+	case
+		teamrepository.ErrAlreadyExist,
+		teamrepository.ErrMemberAlreadyExist,
+		team.ErrLastOwner:
+
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func urlPathId(r *http.Request, key string) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[key], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+type PostTeamRequestModel struct {
+	Name string `json:"name"`
+}
+
+type TeamResponseModel struct {
+	Id        uint                  `json:"id"`
+	Name      string                `json:"name"`
+	Role      string                `json:"role"`
+	CreatedAt time.Time             `json:"created_at"`
+	Members   []MemberResponseModel `json:"members,omitempty"`
+}
+
+type MemberResponseModel struct {
+	Uid      uint      `json:"uid"`
+	Login    string    `json:"login"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func toTeamResponseModel(t team.Team) TeamResponseModel {
+	return TeamResponseModel{
+		Id:        t.Id,
+		Name:      t.Name,
+		Role:      string(t.Role),
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (a *Api) postTeam(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PostTeamRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, err := a.TeamUseCases.CreateTeam(aid, m.Name)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/teams/%d", t.Id))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toTeamResponseModel(t)); err != nil {
+		return
+	}
+}
+
+type GetTeamsResponseModel struct {
+	Teams []TeamResponseModel `json:"teams"`
+}
+
+func (a *Api) getTeams(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tt, err := a.TeamUseCases.GetMyTeams(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetTeamsResponseModel{
+		Teams: make([]TeamResponseModel, len(tt)),
+	}
+	for i, t := range tt {
+		mm.Teams[i] = toTeamResponseModel(t)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) getTeam(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, members, err := a.TeamUseCases.GetTeam(aid, tid)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	mm := toTeamResponseModel(t)
+	mm.Members = make([]MemberResponseModel, len(members))
+	for i, m := range members {
+		acc, err := a.AccountUseCases.GetAccountById(m.Uid)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mm.Members[i] = MemberResponseModel{
+			Uid:      m.Uid,
+			Login:    acc.Login,
+			Role:     string(m.Role),
+			JoinedAt: m.JoinedAt,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteTeam(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.TeamUseCases.DeleteTeam(aid, tid); err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type PostInvitationRequestModel struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type InvitationResponseModel struct {
+	Id        uint      `json:"id"`
+	TeamId    uint      `json:"team_id"`
+	TeamName  string    `json:"team_name"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func toInvitationResponseModel(i team.Invitation) InvitationResponseModel {
+	return InvitationResponseModel{
+		Id:        i.Id,
+		TeamId:    i.Tid,
+		TeamName:  i.TeamName,
+		Role:      string(i.Role),
+		ExpiresAt: i.ExpiresAt,
+	}
+}
+
+func (a *Api) postInvitation(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var m PostInvitationRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	role := teamrepository.Role(m.Role)
+	if m.Role == "" {
+		role = teamrepository.Member
+	}
+	invitee, err := a.AccountUseCases.GetAccountByLogin(m.Login)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	i, err := a.TeamUseCases.InviteMember(aid, tid, invitee.Id, role)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toInvitationResponseModel(i)); err != nil {
+		return
+	}
+}
+
+type GetInvitationsResponseModel struct {
+	Invitations []InvitationResponseModel `json:"invitations"`
+}
+
+func (a *Api) getInvitations(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ii, err := a.TeamUseCases.GetMyInvitations(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetInvitationsResponseModel{
+		Invitations: make([]InvitationResponseModel, len(ii)),
+	}
+	for n, i := range ii {
+		mm.Invitations[n] = toInvitationResponseModel(i)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) postAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, ok := urlPathId(r, invitationIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, err := a.TeamUseCases.AcceptInvitation(aid, id)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toTeamResponseModel(t)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteInvitation(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, ok := urlPathId(r, invitationIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.TeamUseCases.DeclineInvitation(aid, id); err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type PutMemberRequestModel struct {
+	Role string `json:"role"`
+}
+
+func (a *Api) putMember(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	uid, ok := urlPathId(r, memberIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var m PutMemberRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.TeamUseCases.SetMemberRole(aid, tid, uid, teamrepository.Role(m.Role)); err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteMember(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	uid, ok := urlPathId(r, memberIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.TeamUseCases.RemoveMember(aid, tid, uid); err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) getTeamLinks(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tid, ok := urlPathId(r, teamIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ss, err := a.CodeSnippetUseCases.GetTeamSnippetIds(acc, tid)
+	if err != nil {
+		w.WriteHeader(teamStatusCode(err))
+		return
+	}
+	writeLinks(w, ss)
+}
+
+type PostLinksResponseModel struct {
+	Links []string `json:"links"`
+}
+
+// parsePage reads the optional limit and offset query parameters.
+func parsePage(r *http.Request) (codesnippet.Page, bool) {
+	var p codesnippet.Page
+	q := r.URL.Query()
+	for key, v := range map[string]*int{"limit": &p.Limit, "offset": &p.Offset} {
+		if q.Get(key) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(q.Get(key))
+		if err != nil {
+			return codesnippet.Page{}, false
+		}
+		*v = n
+	}
+	return p, true
+}
+
+func writeLinks(w http.ResponseWriter, ss []uint) {
+	mm := PostLinksResponseModel{
+		Links: make([]string, len(ss)),
+	}
+	for i := range ss {
+		mm.Links[i] = fmt.Sprintf("/toad/%d", ss[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) postLinks(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, ok := parsePage(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ss, err := a.CodeSnippetUseCases.GetMySnippetIds(acc, page)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippet.ErrInvalidPage:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	writeLinks(w, ss)
+}
+
+type PostCodeRequestModel struct {
+	Code        string           `json:"code"`
+	Lang        string           `json:"lang"`
+	Lifetime    time.Duration    `json:"lifetime"`
+	Team        *uint            `json:"team,omitempty"`
+	LintOptions LintOptionsModel `json:"lint_options,omitempty"`
+	// Format formats the code before it is stored.
+	Format bool `json:"format,omitempty"`
+}
+
+func postCodeStatusCode(err error) int {
+	switch err {
+	case
+		account.ErrInvalidLanguage,
+		codesnippet.ErrorUnsupportedLanguage,
+		linter.ErrInvalidOption:
+
+		return http.StatusBadRequest
+	case
+		teamrepository.ErrNotFound:
+
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (a *Api) postCode(w http.ResponseWriter, r *http.Request) {
+	var m PostCodeRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var acc *account.Account = nil
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if ok {
+		a, err := a.AccountUseCases.GetAccountById(aid)
+		acc = &a
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	opts, ok := m.LintOptions.toOptions()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if m.Team != nil && acc == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	code := m.Code
+	var formatErr error
+	if m.Format {
+		if err := a.CodeSnippetUseCases.CheckSnippet(acc, m.Team, m.Lang, opts); err != nil {
+			w.WriteHeader(postCodeStatusCode(err))
+			fmt.Println(err)
+			return
+		}
+		if formatted, err := a.CodeSnippetUseCases.FormatCode(m.Lang, m.Code); err == nil {
+			code = formatted
+		} else {
+			formatErr = err
+		}
+	}
+	var id uint
+	var err error
+	if m.Team != nil {
+		id, err = a.CodeSnippetUseCases.CreateTeamSnippet(*acc, *m.Team, code, m.Lang, m.Lifetime, opts)
+	} else {
+		id, err = a.CodeSnippetUseCases.CreateSnippet(acc, code, m.Lang, m.Lifetime, opts)
+	}
+	if err != nil {
+		w.WriteHeader(postCodeStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+
+	location := fmt.Sprintf("/toad/%d", id)
+	w.Header().Set("Location", location)
+	if !m.Format {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	resp := PostCodeResponseModel{Id: id, Formatted: formatErr == nil}
+	if formatErr != nil {
+		resp.FormatError = formatErrorMessage(formatErr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return
+	}
+}
+
+func (a *Api) getCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	s, ok := vars[snippetIdUrlPathKey]
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ss, err := a.CodeSnippetUseCases.GetSnippetById(uint(sid))
+	if err != nil {
+		var statusCode int
+		switch err {
+
+		case
+			codesnippetrepo.ErrInvalidSnippedId:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	switch acceptedMediaType(r, mediaTypeJson, mediaTypeHtml, "text/plain") {
+	case mediaTypeJson, mediaTypeHtml:
+		a.writeSnippet(w, r, uint(sid), ss)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	status := ""
+	if !ss.Lint.Status.Done() {
+		status = "Not checked yet"
 	} else {
 		status = ss.Message
 	}
-	w.Write([]byte("Status:" + status + ", Code: " + ss.Code))
+	lint := string(ss.Lint.Status)
+	if ss.Lint.Error != "" {
+		lint += " (" + ss.Lint.Error + ")"
+	}
+	w.Write([]byte("Status:" + status + ", Lint: " + lint + ", Code: " + ss.Code))
+}
+
+func (a *Api) writeSnippet(w http.ResponseWriter, r *http.Request, sid uint, s codesnippetrepository.CodeSnippet) {
+	cc, err := a.CodeSnippetUseCases.GetComments(nil, sid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	stars, err := a.CodeSnippetUseCases.GetStarCount(sid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m := toSnippetResponseModel(sid, s, cc)
+	m.Stars = stars
+	if acceptedMediaType(r, mediaTypeJson, mediaTypeHtml) == mediaTypeHtml {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := snippetTemplate.Execute(w, newSnippetView(m)); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteCode(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, err := strconv.ParseUint(mux.Vars(r)[snippetIdUrlPathKey], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.CodeSnippetUseCases.DeleteSnippet(acc, uint(sid)); err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippetrepo.ErrInvalidSnippedId:
+
+			statusCode = http.StatusNotFound
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func commentStatusCode(err error) int {
+	switch err {
+	case
+		codesnippetrepo.ErrInvalidSnippedId,
+		commentrepository.ErrNotFound:
+
+		return http.StatusNotFound
+	case
+		codesnippet.ErrInvalidComment,
+		codesnippet.ErrInvalidLineRange:
+
+		return http.StatusBadRequest
+	case
+		codesnippet.ErrNotModerator:
+
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type PostCommentRequestModel struct {
+	Body      string `json:"body"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	ParentId  *uint  `json:"parent_id,omitempty"`
+}
+
+func (a *Api) postComment(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var m PostCommentRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if m.EndLine == 0 {
+		m.EndLine = m.StartLine
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c, err := a.CodeSnippetUseCases.AddComment(acc, sid, m.ParentId, m.StartLine, m.EndLine, m.Body)
+	if err != nil {
+		w.WriteHeader(commentStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/toad/%d/comments/%d", sid, c.Id))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(toCommentResponseModel(c)); err != nil {
+		return
+	}
+}
+
+type GetCommentsResponseModel struct {
+	Comments []CommentResponseModel `json:"comments"`
+}
+
+func (a *Api) getComments(w http.ResponseWriter, r *http.Request) {
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var viewer *account.Account
+	if aid, ok := r.Context().Value(accountIdContextKey).(uint); ok {
+		acc, err := a.AccountUseCases.GetAccountById(aid)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		viewer = &acc
+	}
+	cc, err := a.CodeSnippetUseCases.GetComments(viewer, sid)
+	if err != nil {
+		w.WriteHeader(commentStatusCode(err))
+		return
+	}
+	mm := GetCommentsResponseModel{
+		Comments: make([]CommentResponseModel, len(cc)),
+	}
+	for i, c := range cc {
+		mm.Comments[i] = toCommentResponseModel(c)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+type PutCommentHiddenRequestModel struct {
+	Hidden bool `json:"hidden"`
+}
+
+func (a *Api) putCommentHidden(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cid, ok := urlPathId(r, commentIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var m PutCommentHiddenRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.CodeSnippetUseCases.SetCommentHidden(acc, sid, cid, m.Hidden); err != nil {
+		w.WriteHeader(commentStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) deleteComment(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cid, ok := urlPathId(r, commentIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.CodeSnippetUseCases.DeleteComment(acc, sid, cid); err != nil {
+		w.WriteHeader(commentStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) putStar(w http.ResponseWriter, r *http.Request) {
+	a.star(w, r, a.CodeSnippetUseCases.StarSnippet)
+}
+
+func (a *Api) deleteStar(w http.ResponseWriter, r *http.Request) {
+	a.star(w, r, a.CodeSnippetUseCases.UnstarSnippet)
+}
+
+func (a *Api) star(w http.ResponseWriter, r *http.Request, star func(a account.Account, sid uint) error) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := star(acc, sid); err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippetrepo.ErrInvalidSnippedId:
+
+			statusCode = http.StatusNotFound
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) getStars(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	page, ok := parsePage(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ss, err := a.CodeSnippetUseCases.GetStarredSnippetIds(acc, page)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippet.ErrInvalidPage:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	writeLinks(w, ss)
 }
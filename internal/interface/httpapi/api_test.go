@@ -5,70 +5,345 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	repository "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	codesnippetrepository "github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/ratelimit"
+	teamrepository "github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	webhookrepository "github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/ratelimitrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/formatter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passwordpolicy"
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/team"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
 
 type AccountFake struct{}
 type CodeSnippetFake struct{}
+type TeamFake struct{}
+type WebhookFake struct{}
 
 func (AccountFake) CreateAccount(login, password string) (account.Account, error) {
 	if login == "katyukha" {
 		return account.Account{Id: 1}, nil
 	}
 	if password == "  " {
-		return account.Account{}, account.ErrInvalidPasswordString
+		return account.Account{}, &passwordpolicy.ValidationError{Violations: []error{
+			passwordpolicy.ErrTooShort,
+			&passwordpolicy.ClassRequiredError{Class: passwordpolicy.Digit},
+		}}
 	}
 	return account.Account{}, errors.New("failed to create account")
 }
 
-func (AccountFake) LoginToAccount(login, password string) (string, error) {
+func (AccountFake) LoginToAccount(login, password, device, ip string) (account.Tokens, error) {
 	if login == "katyukha" && password == "kek1234" {
-		return "token", nil
+		return account.Tokens{AccessToken: "token", RefreshToken: "refresh"}, nil
 	}
 	if login == "masha" && password != "123" {
-		return "", account.ErrInvalidPassword
+		return account.Tokens{}, account.ErrInvalidCredentials
+	}
+	if login == "twofactor" {
+		return account.Tokens{}, &account.ChallengeRequiredError{Challenge: "challenge", ExpiresAt: time.Now().Add(time.Minute)}
+	}
+	if login == "brute" {
+		return account.Tokens{}, &account.LockedOutError{RetryAfter: 90 * time.Second}
 	}
 	if password == "  " {
-		return "", account.ErrInvalidPasswordString
+		return account.Tokens{}, account.ErrTooShortString
+	}
+	return account.Tokens{}, errors.New("failed to login to account")
+}
+
+func (AccountFake) RefreshTokens(refreshToken string) (account.Tokens, error) {
+	if refreshToken == "refresh" {
+		return account.Tokens{AccessToken: "token", RefreshToken: "refresh2"}, nil
+	}
+	if refreshToken == "internal" {
+		return account.Tokens{}, errors.New("failed to refresh tokens")
+	}
+	return account.Tokens{}, account.ErrInvalidRefreshToken
+}
+
+func (AccountFake) Logout(token string) error {
+	if token == "internal" {
+		return errors.New("failed to logout")
+	}
+	return nil
+}
+
+func (AccountFake) GetSessions(aid uint) ([]account.Session, error) {
+	if aid == 1 {
+		return []account.Session{{Id: "s1", Device: "laptop"}}, nil
+	}
+	return nil, errors.New("failed to get sessions")
+}
+
+func (AccountFake) RevokeSession(aid uint, sid string) error {
+	if aid == 1 && sid == "s1" {
+		return nil
+	}
+	if aid == 1 {
+		return account.ErrSessionNotFound
 	}
-	return "", errors.New("failed to login to account")
+	return errors.New("failed to revoke session")
 }
 
-func (CodeSnippetFake) GetMySnippetIds(a account.Account) ([]uint, error) {
+func (CodeSnippetFake) GetMySnippetIds(a account.Account, p codesnippet.Page) ([]uint, error) {
+	if p.Limit < 0 {
+		return nil, codesnippet.ErrInvalidPage
+	}
 	if a.Id == 1 {
 		return []uint{1, 2, 3}, nil
 	}
 	return []uint{}, errors.New("failed to get links")
 }
 
-func (CodeSnippetFake) CheckCode(uint, string, string) error {
+func (CodeSnippetFake) CheckCode(uint, string, string, linter.Options) error {
 	return nil
 }
 
-func (CodeSnippetFake) CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration) (uint, error) {
+func (CodeSnippetFake) CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error) {
 	if lang == "petooh" {
 		return 0, account.ErrInvalidLanguage
 	}
+	if opts["dupl"]["threshold"] == "0" {
+		return 0, linter.ErrInvalidOption
+	}
 	if code == "internal" {
 		return 0, errors.New("failed ti create new snippet")
 	}
+	if code == "formatted" {
+		return 2, nil
+	}
 	return 1, nil
 }
 
-func (CodeSnippetFake) GetSnippetById(sid uint) (codesnippet.CodeSnippet, error) {
+func (CodeSnippetFake) GetTeamSnippetIds(a account.Account, tid uint) ([]uint, error) {
+	if tid != 1 {
+		return nil, teamrepository.ErrNotFound
+	}
+	return []uint{4, 5}, nil
+}
+
+func (CodeSnippetFake) CreateTeamSnippet(a account.Account, tid uint, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error) {
+	if tid != 1 {
+		return 0, teamrepository.ErrNotFound
+	}
+	return 4, nil
+}
+
+func (CodeSnippetFake) CheckSnippet(a *account.Account, tid *uint, lang string, opts linter.Options) error {
+	if tid != nil && (a == nil || *tid != 1) {
+		return teamrepository.ErrNotFound
+	}
+	if lang == "petooh" {
+		return account.ErrInvalidLanguage
+	}
+	if opts["dupl"]["threshold"] == "0" {
+		return linter.ErrInvalidOption
+	}
+	return nil
+}
+
+func (CodeSnippetFake) AddComment(a account.Account, sid uint, parentId *uint, startLine, endLine int, body string) (comment.Comment, error) {
+	if sid == 1 {
+		return comment.Comment{}, codesnippetrepo.ErrInvalidSnippedId
+	}
+	if body == "" {
+		return comment.Comment{}, codesnippet.ErrInvalidComment
+	}
+	if startLine < 1 {
+		return comment.Comment{}, codesnippet.ErrInvalidLineRange
+	}
+	return comment.Comment{Id: 7, Sid: sid, Uid: a.Id, StartLine: startLine, EndLine: endLine, Body: body}, nil
+}
+
+func (CodeSnippetFake) GetComments(viewer *account.Account, sid uint) ([]comment.Comment, error) {
+	if sid == 1 {
+		return nil, codesnippetrepo.ErrInvalidSnippedId
+	}
+	return []comment.Comment{
+		{Id: 7, Sid: sid, Uid: 1, StartLine: 1, EndLine: 1, Body: "<b>kva</b>"},
+		{Id: 8, Sid: sid, Uid: 2, ParentId: 7, HasParent: true, StartLine: 1, EndLine: 1, Hidden: true},
+	}, nil
+}
+
+func (CodeSnippetFake) SetCommentHidden(a account.Account, sid, cid uint, hidden bool) error {
+	if a.Id != 1 {
+		return codesnippet.ErrNotModerator
+	}
+	return nil
+}
+
+func (CodeSnippetFake) DeleteComment(a account.Account, sid, cid uint) error {
+	if cid != 7 {
+		return comment.ErrNotFound
+	}
+	return nil
+}
+
+func (CodeSnippetFake) StarSnippet(a account.Account, sid uint) error {
+	if sid == 1 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	return nil
+}
+
+func (CodeSnippetFake) UnstarSnippet(a account.Account, sid uint) error {
+	return nil
+}
+
+func (CodeSnippetFake) GetStarredSnippetIds(a account.Account, p codesnippet.Page) ([]uint, error) {
+	if p.Limit > codesnippet.MaxPageLimit {
+		return nil, codesnippet.ErrInvalidPage
+	}
+	return []uint{5, 3}[:p.Limit], nil
+}
+
+func (CodeSnippetFake) GetStarCount(sid uint) (uint, error) {
+	return 2, nil
+}
+
+func (CodeSnippetFake) WatchLintStatus(sid uint) (<-chan codesnippet.LintStatus, func(), error) {
+	if sid == 1 {
+		return nil, nil, codesnippetrepo.ErrInvalidSnippedId
+	}
+	if sid == 2 {
+		return nil, nil, codesnippet.ErrLiveStatusUnavailable
+	}
+	if sid == 4 {
+		// never checked
+		return make(chan codesnippet.LintStatus), func() {}, nil
+	}
+	statuses := make(chan codesnippet.LintStatus, 3)
+	statuses <- codesnippet.LintStatus{Status: lintevent.Queued}
+	statuses <- codesnippet.LintStatus{Status: lintevent.Running}
+	statuses <- codesnippet.LintStatus{Status: lintevent.Checked, Lint: codesnippetrepository.Lint{Status: codesnippetrepository.LintPassed}}
+	close(statuses)
+	return statuses, func() {}, nil
+}
+
+func (CodeSnippetFake) Lint(a *account.Account, code string, lang string, opts linter.Options) ([]linter.Report, error) {
+	if lang != "Go" {
+		return nil, codesnippet.ErrorUnsupportedLanguage
+	}
+	if opts["dupl"]["threshold"] == "0" {
+		return nil, linter.ErrInvalidOption
+	}
+	if len(code) > 100 {
+		return nil, codesnippet.ErrCodeTooLong
+	}
+	return []linter.Report{
+		{Linter: "dupl", Status: linter.StatusSucceeded, Findings: []linter.Finding{{Line: 3, EndLine: 5, Message: "duplicate of lines 7-9"}}},
+		{Linter: "slow", Status: linter.StatusTimedOut, Err: linter.ErrTimeout},
+	}, nil
+}
+
+func (CodeSnippetFake) GetDefaultLintOptions(a *account.Account) (linter.Options, error) {
+	return linter.Options{"dupl": {"threshold": "30"}}, nil
+}
+
+func (CodeSnippetFake) SetDefaultLintOptions(a account.Account, opts linter.Options) error {
+	if opts["dupl"]["threshold"] == "0" {
+		return linter.ErrInvalidOption
+	}
+	return nil
+}
+
+func (CodeSnippetFake) Relint(a account.Account, sid uint) error {
+	if sid == 1 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	if sid == 4 {
+		return codesnippet.ErrLintInProgress
+	}
+	if a.Id != 1 {
+		return codesnippet.ErrNotSnippetOwner
+	}
+	return nil
+}
+
+func (CodeSnippetFake) RelintStale(a account.Account) (int, error) {
+	if a.Id != 1 {
+		return 0, codesnippet.ErrNotAdmin
+	}
+	return 3, nil
+}
+
+func (CodeSnippetFake) GetCanonicalCode(sid uint) (string, error) {
+	switch sid {
+	case 1:
+		return "", codesnippetrepo.ErrInvalidSnippedId
+	case 2:
+		return "", linter.ErrNoCanonicalForm
+	case 3:
+		return "", linter.ErrInvalidCode
+	}
+	return "{\n  \"a\": 1\n}\n", nil
+}
+
+func (CodeSnippetFake) FormatCode(lang string, code string) (string, error) {
+	switch code {
+	case "unformatted":
+		return "formatted", nil
+	case "broken":
+		return "", &formatter.Error{Formatter: "gofmt", Err: errors.New("1:1: expected 'package', found broken")}
+	case "slow":
+		return "", fmt.Errorf("%w: gofmt: linter timed out", formatter.ErrFailed)
+	}
+	return "", formatter.ErrUnsupportedLanguage
+}
+
+func (CodeSnippetFake) GetFormattedCode(sid uint) (string, error) {
+	switch sid {
+	case 1:
+		return "", codesnippetrepo.ErrInvalidSnippedId
+	case 2:
+		return "", formatter.ErrUnsupportedLanguage
+	case 3:
+		return "", &formatter.Error{Formatter: "gofmt", Err: errors.New("1:1: expected 'package', found broken")}
+	case 5:
+		return "", fmt.Errorf("%w: gofmt: linter timed out", formatter.ErrFailed)
+	}
+	return "package main\n", nil
+}
+
+func (CodeSnippetFake) DeleteSnippet(a account.Account, sid uint) error {
+	if sid == 1 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	return nil
+}
+
+func (CodeSnippetFake) GetSnippetById(sid uint) (codesnippetrepository.CodeSnippet, error) {
 	if sid == 1 {
-		return codesnippet.CodeSnippet{}, codesnippetrepo.ErrInvalidSnippedId
+		return codesnippetrepository.CodeSnippet{}, codesnippetrepo.ErrInvalidSnippedId
 	}
 	if sid == 2 {
-		return codesnippet.CodeSnippet{}, errors.New("failed to get snippet")
+		return codesnippetrepository.CodeSnippet{}, errors.New("failed to get snippet")
 	}
-	return codesnippet.CodeSnippet{Code: "KoKoKoKoKoKoKoKoKoKo Kud-Kudah"}, nil
+	return codesnippetrepository.CodeSnippet{
+		Code: "KoKoKoKoKoKoKoKoKoKo Kud-Kudah",
+		Lint: codesnippetrepository.Lint{
+			Status:     codesnippetrepository.LintFailed,
+			Linter:     "dupl",
+			StartedAt:  time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+			FinishedAt: time.Date(2021, 5, 1, 12, 0, 1, 0, time.UTC),
+			Error:      "dupl timed_out: linter timed out",
+		},
+	}, nil
 }
 
 func (AccountFake) GetAccountByToken(token string) (account.Account, error) {
@@ -81,14 +356,192 @@ func (AccountFake) GetAccountByToken(token string) (account.Account, error) {
 	return account.Account{}, errors.New("invalid token claims")
 }
 
-func (AccountFake) Authenticate(token string) (uint, error) {
+func (AccountFake) Authenticate(token string) (account.Identity, error) {
 	if token == "correct" {
-		return 1, nil
+		return account.Identity{Id: 1}, nil
 	}
 	if token == "internal" {
-		return 100, nil
+		return account.Identity{Id: 100}, nil
+	}
+	if token == "csp_read" {
+		return account.Identity{Id: 1, Scopes: []string{account.ScopeSnippetsRead}}, nil
+	}
+	if token == "csp_all" {
+		return account.Identity{Id: 1, Scopes: account.Scopes}, nil
+	}
+	return account.Identity{}, errors.New("invalid token claims")
+}
+
+func (AccountFake) CreateApiToken(aid uint, name string, scopes []string) (string, account.ApiToken, error) {
+	if name == "" {
+		return "", account.ApiToken{}, account.ErrInvalidApiTokenName
+	}
+	if aid != 1 {
+		return "", account.ApiToken{}, errors.New("failed to create api token")
+	}
+	return "csp_all", account.ApiToken{Id: 1, Name: name, Scopes: scopes}, nil
+}
+
+func (AccountFake) GetApiTokens(aid uint) ([]account.ApiToken, error) {
+	if aid == 1 {
+		return []account.ApiToken{{Id: 1, Name: "ci", Scopes: account.Scopes}}, nil
+	}
+	return nil, errors.New("failed to get api tokens")
+}
+
+func (AccountFake) RevokeApiToken(aid, id uint) error {
+	if aid == 1 && id == 1 {
+		return nil
+	}
+	return account.ErrApiTokenNotFound
+}
+
+func (AccountFake) ChangePassword(aid uint, currentPassword, newPassword string) error {
+	if currentPassword != "kek1234" {
+		return account.ErrInvalidPassword
+	}
+	if newPassword == "  " {
+		return &passwordpolicy.ValidationError{Violations: []error{passwordpolicy.ErrBreached}}
+	}
+	if aid != 1 {
+		return errors.New("failed to change password")
+	}
+	return nil
+}
+
+func (AccountFake) DeleteAccount(aid uint, deleteSnippets bool) error {
+	if aid == 1 && deleteSnippets {
+		return repository.ErrSoleTeamOwner
 	}
-	return 0, errors.New("invalid token claims")
+	if aid == 1 {
+		return nil
+	}
+	return errors.New("failed to delete account")
+}
+
+func (AccountFake) SetEmail(aid uint, email string) error {
+	if email == "toad" {
+		return account.ErrInvalidEmail
+	}
+	if aid != 1 {
+		return errors.New("failed to set email")
+	}
+	return nil
+}
+
+func (AccountFake) VerifyEmail(token string) error {
+	if token == "verify" {
+		return nil
+	}
+	if token == "taken" {
+		return account.ErrEmailTaken
+	}
+	return account.ErrInvalidEmailToken
+}
+
+func (AccountFake) RequestPasswordReset(email string) error {
+	if email == "toad" {
+		return account.ErrInvalidEmail
+	}
+	return nil
+}
+
+func (AccountFake) ResetPassword(token, newPassword string) error {
+	if newPassword == "  " {
+		return &passwordpolicy.ValidationError{Violations: []error{passwordpolicy.ErrTooShort}}
+	}
+	if token != "reset" {
+		return account.ErrInvalidEmailToken
+	}
+	return nil
+}
+
+func (AccountFake) CompleteSignin(challenge, code, ip string) (account.Tokens, error) {
+	if challenge == "locked" {
+		return account.Tokens{}, &account.LockedOutError{RetryAfter: 90 * time.Second}
+	}
+	if challenge != "challenge" {
+		return account.Tokens{}, account.ErrInvalidChallenge
+	}
+	if code != "123456" {
+		return account.Tokens{}, account.ErrInvalidTotpCode
+	}
+	return account.Tokens{AccessToken: "token", RefreshToken: "refresh"}, nil
+}
+
+func (AccountFake) EnrollTotp(aid uint) (account.TotpEnrollment, error) {
+	if aid != 1 {
+		return account.TotpEnrollment{}, account.ErrTotpAlreadyEnabled
+	}
+	return account.TotpEnrollment{Secret: "JBSWY3DPEHPK3PXP", ProvisioningUri: "otpauth://totp/code-swamp:katyukha"}, nil
+}
+
+func (AccountFake) ConfirmTotp(aid uint, code, ip string) ([]string, error) {
+	if code != "123456" {
+		return nil, account.ErrInvalidTotpCode
+	}
+	return []string{"AAAA-BBBB-CCCC-DDDD"}, nil
+}
+
+func (AccountFake) RegenerateRecoveryCodes(aid uint, code, ip string) ([]string, error) {
+	if aid != 1 {
+		return nil, account.ErrTotpNotEnrolled
+	}
+	return []string{"AAAA-BBBB-CCCC-DDDD"}, nil
+}
+
+func (AccountFake) DisableTotp(aid uint, code, ip string) error {
+	if code != "123456" {
+		return account.ErrInvalidTotpCode
+	}
+	return nil
+}
+
+func (AccountFake) StartOidcLogin(provider string, linkAid *uint, device string) (account.OidcStart, error) {
+	switch provider {
+	case "corp":
+		return account.OidcStart{AuthorizationUrl: "https://idp.example.com/authorize", State: "state", ExpiresAt: time.Now().Add(time.Minute)}, nil
+	case "down":
+		return account.OidcStart{}, account.ErrOidcUnavailable
+	case "broken":
+		return account.OidcStart{}, errors.New("storage failure")
+	}
+	return account.OidcStart{}, account.ErrUnknownProvider
+}
+
+func (AccountFake) FinishOidcLogin(provider, state, code string) (account.OidcResult, error) {
+	if state != "state" {
+		return account.OidcResult{}, account.ErrInvalidOidcState
+	}
+	return account.OidcResult{Tokens: account.Tokens{AccessToken: "token", RefreshToken: "refresh"}}, nil
+}
+
+func (AccountFake) GetIdentities(aid uint) ([]account.LinkedIdentity, error) {
+	return []account.LinkedIdentity{{Provider: "corp"}}, nil
+}
+
+func (AccountFake) UnlinkIdentity(aid uint, provider string) error {
+	if provider == "last" {
+		return account.ErrLastCredential
+	}
+	if provider != "corp" {
+		return account.ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (AccountFake) GetJwks() token.Jwks {
+	return token.Jwks{Keys: []token.Jwk{{Kty: "RSA", Kid: "k1", N: "AQAB", E: "AQAB"}}}
+}
+
+func (AccountFake) GetAccountByLogin(login string) (account.Account, error) {
+	if login == "katyukha" {
+		return account.Account{Id: 1, Login: login}, nil
+	}
+	if login == "jabajaba" {
+		return account.Account{Id: 2, Login: login}, nil
+	}
+	return account.Account{}, repository.ErrNotFound
 }
 
 func (AccountFake) GetAccountById(id uint) (account.Account, error) {
@@ -98,6 +551,127 @@ func (AccountFake) GetAccountById(id uint) (account.Account, error) {
 	return account.Account{}, errors.New("invalid token claims")
 }
 
+func (TeamFake) CreateTeam(uid uint, name string) (team.Team, error) {
+	if name == "swamp" {
+		return team.Team{}, teamrepository.ErrAlreadyExist
+	}
+	if name == "" {
+		return team.Team{}, team.ErrInvalidTeamName
+	}
+	return team.Team{Id: 1, Name: name, Role: teamrepository.Owner}, nil
+}
+
+func (TeamFake) GetMyTeams(uid uint) ([]team.Team, error) {
+	return []team.Team{{Id: 1, Name: "toads", Role: teamrepository.Owner}}, nil
+}
+
+func (TeamFake) GetTeam(uid, tid uint) (team.Team, []team.Member, error) {
+	if tid != 1 {
+		return team.Team{}, nil, teamrepository.ErrNotFound
+	}
+	return team.Team{Id: 1, Name: "toads", Role: teamrepository.Owner}, []team.Member{{Uid: 1, Role: teamrepository.Owner}}, nil
+}
+
+func (TeamFake) DeleteTeam(uid, tid uint) error {
+	if tid == 2 {
+		return team.ErrForbidden
+	}
+	if tid != 1 {
+		return teamrepository.ErrNotFound
+	}
+	return nil
+}
+
+func (TeamFake) InviteMember(uid, tid, invitee uint, role teamrepository.Role) (team.Invitation, error) {
+	if !role.IsValid() {
+		return team.Invitation{}, team.ErrInvalidRole
+	}
+	if invitee == 1 {
+		return team.Invitation{}, teamrepository.ErrMemberAlreadyExist
+	}
+	return team.Invitation{Id: 1, Tid: tid, TeamName: "toads", Uid: invitee, Role: role}, nil
+}
+
+func (TeamFake) GetMyInvitations(uid uint) ([]team.Invitation, error) {
+	return []team.Invitation{{Id: 1, Tid: 1, TeamName: "toads", Uid: uid, Role: teamrepository.Member}}, nil
+}
+
+func (TeamFake) AcceptInvitation(uid, id uint) (team.Team, error) {
+	if id != 1 {
+		return team.Team{}, teamrepository.ErrInvitationNotFound
+	}
+	return team.Team{Id: 1, Name: "toads", Role: teamrepository.Member}, nil
+}
+
+func (TeamFake) DeclineInvitation(uid, id uint) error {
+	if id != 1 {
+		return teamrepository.ErrInvitationNotFound
+	}
+	return nil
+}
+
+func (TeamFake) SetMemberRole(uid, tid, member uint, role teamrepository.Role) error {
+	if member == uid {
+		return team.ErrLastOwner
+	}
+	return nil
+}
+
+func (TeamFake) RemoveMember(uid, tid, member uint) error {
+	if member == 3 {
+		return teamrepository.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (WebhookFake) CreateWebhook(uid uint, rawUrl string, events []webhookrepository.Event) (webhook.Webhook, string, error) {
+	if rawUrl == "http://localhost/hook" {
+		return webhook.Webhook{}, "", webhook.ErrPrivateAddress
+	}
+	if !strings.HasPrefix(rawUrl, "https://") {
+		return webhook.Webhook{}, "", webhook.ErrInvalidUrl
+	}
+	for _, e := range events {
+		if e != webhookrepository.SnippetChecked {
+			return webhook.Webhook{}, "", webhook.ErrInvalidEvent
+		}
+	}
+	return webhook.Webhook{Id: 1, Url: rawUrl, Events: events}, "secret", nil
+}
+
+func (WebhookFake) GetWebhooks(uid uint) ([]webhook.Webhook, error) {
+	return []webhook.Webhook{{Id: 1, Url: "https://example.com/hook", Events: webhookrepository.Events}}, nil
+}
+
+func (WebhookFake) DeleteWebhook(uid, id uint) error {
+	if id != 1 {
+		return webhookrepository.ErrNotFound
+	}
+	return nil
+}
+
+func (WebhookFake) GetDeliveries(uid, id uint, limit int) ([]webhookrepository.Delivery, error) {
+	if id != 1 {
+		return nil, webhookrepository.ErrNotFound
+	}
+	return []webhookrepository.Delivery{{
+		Id:        1,
+		WebhookId: 1,
+		Event:     webhookrepository.SnippetChecked,
+		Payload:   []byte(`{"event":"snippet.checked","snippet_id":3}`),
+		State:     webhookrepository.Failed,
+		Attempts:  8,
+	}}, nil
+}
+
+func (WebhookFake) Publish(e webhook.SnippetEvent) error {
+	return nil
+}
+
+func (WebhookFake) DeliverDue() (int, error) {
+	return 0, nil
+}
+
 func assertStatusCode(t *testing.T, expectedCode, actualCode int) {
 	if expectedCode != actualCode {
 		t.Errorf("Server MUST return %d (%s) status code, but %d (%s) given",
@@ -144,6 +718,27 @@ func makeSigninRequest(t *testing.T, router http.Handler, login, password string
 	return resp
 }
 
+func makeRefreshRequest(t *testing.T, router http.Handler, refreshToken string) *httptest.ResponseRecorder {
+	b, err := json.Marshal(PostRefreshRequestModel{RefreshToken: refreshToken})
+	if err != nil {
+		t.Fatal("failed to marshal struct")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(b))
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func makeAuthorizedRequest(router http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
 func makeGetLinksRequest(t *testing.T, router http.Handler, token string) *httptest.ResponseRecorder {
 	req := httptest.NewRequest(http.MethodPost, "/myswamp", nil)
 	req.Header.Add("Authorization", "Bearer " + token)
@@ -204,6 +799,13 @@ func Test_postSignup(t *testing.T) {
 	t.Run("failure on invalid password string", func(t *testing.T) {
 		resp := makeSignupRequest(t, router, "jaba", "  ")
 		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+		var m ValidationErrorResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Errors) != 2 {
+			t.Errorf("Server MUST list every violated rule, but %v given", m.Errors)
+		}
 	})
 }
 
@@ -227,9 +829,650 @@ func Test_postSignin(t *testing.T) {
 		resp := makeSigninRequest(t, router, "jaba", "  ")
 		assertStatusCode(t, http.StatusBadRequest, resp.Code)
 	})
+	t.Run("failed login on locked out account", func(t *testing.T) {
+		resp := makeSigninRequest(t, router, "brute", "kek1234")
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+		if resp.Header().Get("Retry-After") != "90" {
+			t.Errorf("Server MUST set Retry-After to 90, but %q given", resp.Header().Get("Retry-After"))
+		}
+	})
 }
 
-func Test_postLinks(t *testing.T) {
+func Test_postRefresh(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on invalid json", func(t *testing.T) {
+		resp := invalidJsonTest(router, "/refresh")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on invalid refresh token", func(t *testing.T) {
+		resp := makeRefreshRequest(t, router, "stale")
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failed to refresh tokens", func(t *testing.T) {
+		resp := makeRefreshRequest(t, router, "internal")
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("successful refresh", func(t *testing.T) {
+		resp := makeRefreshRequest(t, router, "refresh")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m TokensResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m.RefreshToken != "refresh2" {
+			t.Errorf("Server MUST return rotated refresh token, but %q given", m.RefreshToken)
+		}
+	})
+}
+
+func Test_postSignout(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failed to sign out with incorrect token", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/signout", "incorrect")
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failed to sign out because of internal error", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/signout", "internal")
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("successful sign out", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/signout", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func makePasswordRequest(t *testing.T, router http.Handler, token, currentPassword, newPassword string) *httptest.ResponseRecorder {
+	b, err := json.Marshal(PostPasswordRequestModel{CurrentPassword: currentPassword, NewPassword: newPassword})
+	if err != nil {
+		t.Fatal("failed to marshal struct")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/me/password", bytes.NewReader(b))
+	req.Header.Add("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func Test_postPassword(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on wrong current password", func(t *testing.T) {
+		resp := makePasswordRequest(t, router, "correct", "wrong", "Kek12345")
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failure on invalid new password", func(t *testing.T) {
+		resp := makePasswordRequest(t, router, "correct", "kek1234", "  ")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failed to change password", func(t *testing.T) {
+		resp := makePasswordRequest(t, router, "internal", "kek1234", "Kek12345")
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("successful password change", func(t *testing.T) {
+		resp := makePasswordRequest(t, router, "correct", "kek1234", "Kek12345")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_deleteMe(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on missing snippets option", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/me", "correct")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failed to delete account", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/me?snippets=delete", "internal")
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("failure on sole team owner", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/me?snippets=delete", "correct")
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful account deletion", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/me?snippets=anonymise", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func makeJsonRequest(t *testing.T, router http.Handler, method, path, token string, m interface{}) *httptest.ResponseRecorder {
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal("failed to marshal struct")
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func Test_email(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful obtainment of account", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/me", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failure on invalid email", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/email", "correct", PutEmailRequestModel{Email: "toad"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failed to set email", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/email", "internal", PutEmailRequestModel{Email: "toad@swamp.org"})
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("successful email change", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/email", "correct", PutEmailRequestModel{Email: "toad@swamp.org"})
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+	})
+	t.Run("failure on invalid verification token", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/email/verify", "", PostEmailTokenRequestModel{Token: "wrong"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on email verified by another account", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/email/verify", "", PostEmailTokenRequestModel{Token: "taken"})
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful email verification", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/email/verify", "", PostEmailTokenRequestModel{Token: "verify"})
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_twoFactor(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("signin returns challenge", func(t *testing.T) {
+		resp := makeSigninRequest(t, router, "twofactor", "kek1234")
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+		var m ChallengeResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil || m.Challenge != "challenge" {
+			t.Errorf("Server MUST return the challenge, but %+v (%v) given", m, err)
+		}
+	})
+	t.Run("failure on invalid challenge", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/signin/2fa", "", PostSecondFactorRequestModel{Challenge: "other", Code: "123456"})
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failure on invalid code", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/signin/2fa", "", PostSecondFactorRequestModel{Challenge: "challenge", Code: "000000"})
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failure on locked out login", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/signin/2fa", "", PostSecondFactorRequestModel{Challenge: "locked", Code: "123456"})
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+		if resp.Header().Get("Retry-After") != "90" {
+			t.Errorf("Server MUST set Retry-After, but %q given", resp.Header().Get("Retry-After"))
+		}
+	})
+	t.Run("successful second factor", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/signin/2fa", "", PostSecondFactorRequestModel{Challenge: "challenge", Code: "123456"})
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failure on enrolling twice", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/me/totp", "internal")
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful enrolment", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/me/totp", "correct")
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+	})
+	t.Run("failure on confirming with invalid code", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/me/totp/confirm", "correct", PostTotpCodeRequestModel{Code: "000000"})
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("successful confirmation", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/me/totp/confirm", "correct", PostTotpCodeRequestModel{Code: "123456"})
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failure on disabling with invalid code", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodDelete, "/me/totp", "correct", DeleteTotpRequestModel{Code: "000000"})
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("successful disabling", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodDelete, "/me/totp", "correct", DeleteTotpRequestModel{Code: "123456"})
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_oidc(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on unknown provider", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/oidc/other/start", "", nil)
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on unavailable provider", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/oidc/down/start", "", nil)
+		assertStatusCode(t, http.StatusBadGateway, resp.Code)
+	})
+	t.Run("failure on internal error", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/oidc/broken/start", "", nil)
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	var stateCookie *http.Cookie
+	t.Run("successful start", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/oidc/corp/start", "", nil)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		for _, c := range resp.Result().Cookies() {
+			if c.Name == oidcStateCookie {
+				stateCookie = c
+			}
+		}
+		if stateCookie == nil || !stateCookie.HttpOnly || stateCookie.Value == "state" {
+			t.Fatalf("Server MUST bind the state to the browser, but %+v given", stateCookie)
+		}
+	})
+	callback := func(query string, c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oidc/corp/callback?"+query, nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	t.Run("failure on missing state cookie", func(t *testing.T) {
+		resp := callback("code=code&state=state", nil)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on state of another browser", func(t *testing.T) {
+		resp := callback("code=code&state=state", &http.Cookie{Name: oidcStateCookie, Value: oidcStateBinding("other")})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on provider error", func(t *testing.T) {
+		resp := callback("error=access_denied&state=state", stateCookie)
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("failure on invalid state", func(t *testing.T) {
+		resp := callback("code=code&state=other", &http.Cookie{Name: oidcStateCookie, Value: oidcStateBinding("other")})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful callback", func(t *testing.T) {
+		resp := callback("code=code&state=state", stateCookie)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("successful obtainment of identities", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/identities", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failure on unlinking last credential", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/identities/last", "correct")
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful unlinking", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/identities/corp", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_passwordReset(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on invalid json", func(t *testing.T) {
+		resp := invalidJsonTest(router, "/password/forgot")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful reset request", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/password/forgot", "", PutEmailRequestModel{Email: "toad@swamp.org"})
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+	})
+	t.Run("failure on invalid reset token", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/password/reset", "", PostResetPasswordRequestModel{Token: "wrong", NewPassword: "Kek12345"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on invalid new password", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/password/reset", "", PostResetPasswordRequestModel{Token: "reset", NewPassword: "  "})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful password reset", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/password/reset", "", PostResetPasswordRequestModel{Token: "reset", NewPassword: "Kek12345"})
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_sessions(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failed to get sessions", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/sessions", "internal")
+		assertStatusCode(t, http.StatusInternalServerError, resp.Code)
+	})
+	t.Run("successful obtainment of sessions", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/sessions", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failed to revoke unknown session", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/sessions/s2", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful session revocation", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/sessions/s1", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_getJwks(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful obtainment of key set", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var jwks token.Jwks
+		if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "k1" {
+			t.Errorf("Server MUST return published keys, but %v given", jwks.Keys)
+		}
+	})
+}
+
+func makeApiTokenRequest(t *testing.T, router http.Handler, token, name string) *httptest.ResponseRecorder {
+	b, err := json.Marshal(PostApiTokenRequestModel{Name: name, Scopes: []string{account.ScopeSnippetsRead}})
+	if err != nil {
+		t.Fatal("failed to marshal struct")
+	}
+	req := httptest.NewRequest(http.MethodPost, "/tokens", bytes.NewReader(b))
+	req.Header.Add("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func Test_apiTokens(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on invalid token name", func(t *testing.T) {
+		resp := makeApiTokenRequest(t, router, "correct", "")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("api tokens cannot create api tokens", func(t *testing.T) {
+		resp := makeApiTokenRequest(t, router, "csp_all", "ci")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("successful api token creation", func(t *testing.T) {
+		resp := makeApiTokenRequest(t, router, "correct", "ci")
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		var m ApiTokenResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m.Token == "" {
+			t.Error("Server MUST return the token value on creation")
+		}
+	})
+	t.Run("successful obtainment of api tokens", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/tokens", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failed to revoke unknown api token", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/tokens/2", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful api token revocation", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/tokens/1", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_apiTokenScopes(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("read scope allows listing snippets", func(t *testing.T) {
+		resp := makeGetLinksRequest(t, router, "csp_read")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("read scope does not allow posting snippets", func(t *testing.T) {
+		resp := makePostCodeRequest(t, router, "csp_read", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("read scope does not allow deleting snippets", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3", "csp_read")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("write scope allows posting snippets", func(t *testing.T) {
+		resp := makePostCodeRequest(t, router, "csp_all", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+	})
+	t.Run("api tokens cannot sign out", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/signout", "csp_all")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+}
+
+func Test_deleteCode(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failed to delete snippet with incorrect token", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3", "incorrect")
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("no such code snippet", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/1", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful snippet deletion", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3", "csp_all")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_teams(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.TeamUseCases = &TeamFake{}
+	router := service.Router()
+
+	t.Run("successful team creation", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams", "correct", PostTeamRequestModel{Name: "toads"})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		if resp.Header().Get("Location") != "/teams/1" {
+			t.Errorf("Server MUST set Location to /teams/1, but %q given", resp.Header().Get("Location"))
+		}
+	})
+	t.Run("failure on taken team name", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams", "correct", PostTeamRequestModel{Name: "swamp"})
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("failure on invalid team name", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams", "correct", PostTeamRequestModel{})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful obtainment of teams", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/teams", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("successful obtainment of team members", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/teams/1", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m TeamResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Members) != 1 {
+			t.Errorf("Server MUST list team members, but %v given", m.Members)
+		}
+	})
+	t.Run("unknown team", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/teams/2", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failed to delete team without owner role", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/teams/2", "correct")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("successful team deletion", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/teams/1", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("successful invitation", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams/1/invitations", "correct", PostInvitationRequestModel{Login: "jabajaba"})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+	})
+	t.Run("failed to invite unknown login", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams/1/invitations", "correct", PostInvitationRequestModel{Login: "nobody"})
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failed to invite with invalid role", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams/1/invitations", "correct", PostInvitationRequestModel{Login: "jabajaba", Role: "toad"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failed to invite a member", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/teams/1/invitations", "correct", PostInvitationRequestModel{Login: "katyukha"})
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful obtainment of invitations", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/invitations", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("successful invitation acceptance", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/invitations/1/accept", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("no such invitation", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/invitations/2", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failed to demote the last owner", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/teams/1/members/1", "correct", PutMemberRequestModel{Role: "member"})
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("successful member removal", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/teams/1/members/2", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("successful obtainment of team links", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/teams/1/snippets", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failed to post code to a foreign team", func(t *testing.T) {
+		tid := uint(2)
+		resp := makeJsonRequest(t, router, http.MethodPost, "/", "correct", PostCodeRequestModel{Code: "KoKoKo", Team: &tid})
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failed to post code to a team anonymously", func(t *testing.T) {
+		tid := uint(1)
+		resp := makeJsonRequest(t, router, http.MethodPost, "/", "", PostCodeRequestModel{Code: "KoKoKo", Team: &tid})
+		assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+	})
+	t.Run("successful team snippet creation", func(t *testing.T) {
+		tid := uint(1)
+		resp := makeJsonRequest(t, router, http.MethodPost, "/", "correct", PostCodeRequestModel{Code: "KoKoKo", Team: &tid})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		if resp.Header().Get("Location") != "/toad/4" {
+			t.Errorf("Server MUST set Location to /toad/4, but %q given", resp.Header().Get("Location"))
+		}
+	})
+}
+
+func Test_rateLimit(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.RateLimits = &RateLimits{
+		Limiter: ratelimitrepo.NewMemory(),
+		Routes: map[string]RouteLimit{
+			signupRoute:   {PerIp: ratelimit.Rule{Requests: 1, Per: time.Hour}},
+			postCodeRoute: {PerAccount: ratelimit.Rule{Requests: 1, Per: time.Hour}},
+		},
+	}
+	router := service.Router()
+
+	t.Run("first signup is allowed", func(t *testing.T) {
+		resp := makeSignupRequest(t, router, "katyukha", "kek1234")
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+	})
+	t.Run("second signup from the same ip is rejected", func(t *testing.T) {
+		resp := makeSignupRequest(t, router, "katyukha", "kek1234")
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+		if resp.Header().Get("Retry-After") == "" {
+			t.Error("Server MUST set Retry-After header")
+		}
+	})
+	t.Run("anonymous snippets are not limited per account", func(t *testing.T) {
+		makePostCodeRequest(t, router, "", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		resp := makePostCodeRequest(t, router, "", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+	})
+	t.Run("second snippet from the same account is rejected", func(t *testing.T) {
+		makePostCodeRequest(t, router, "correct", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		resp := makePostCodeRequest(t, router, "correct", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+}
+
+func Test_rateLimitBoth(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.RateLimits = &RateLimits{
+		Limiter: ratelimitrepo.NewMemory(),
+		Routes: map[string]RouteLimit{
+			postCodeRoute: {
+				PerIp:      ratelimit.Rule{Requests: 2, Per: time.Hour},
+				PerAccount: ratelimit.Rule{Requests: 1, Per: time.Hour},
+			},
+		},
+	}
+	router := service.Router()
+
+	makePostCodeRequest(t, router, "correct", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+	resp := makePostCodeRequest(t, router, "correct", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+	assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	// the rejected request did not spend the second request of the ip
+	resp = makePostCodeRequest(t, router, "", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+	assertStatusCode(t, http.StatusCreated, resp.Code)
+}
+
+func Test_clientIp(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.TrustForwardedFor = true
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.TrustedProxies = proxies
+
+	for _, c := range []struct {
+		remote, forwarded, want string
+	}{
+		{"192.0.2.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"192.0.2.1:1234", "6.6.6.6, 198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"192.0.2.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remote
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if got := service.clientIp(r); got != c.want {
+			t.Errorf("clientIp from %s forwarded for %q MUST be %s, but %s given", c.remote, c.forwarded, c.want, got)
+		}
+	}
+}
+
+func Test_postLinks(t *testing.T) {
 	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
 	router := service.Router()
 
@@ -277,6 +1520,128 @@ func Test_postCode(t *testing.T) {
 	})
 }
 
+func Test_postCodeFormat(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	post := func(t *testing.T, code string) PostCodeResponseModel {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/", "", PostCodeRequestModel{Code: code, Lang: "go", Format: true})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		var m PostCodeResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		return m
+	}
+	t.Run("successful format on save", func(t *testing.T) {
+		if m := post(t, "unformatted"); m.Id != 2 || !m.Formatted || m.FormatError != "" {
+			t.Errorf("Server MUST store the formatted code, but %+v given", m)
+		}
+	})
+	t.Run("code stored as given on format failure", func(t *testing.T) {
+		if m := post(t, "broken"); m.Id != 1 || m.Formatted || !strings.Contains(m.FormatError, "expected 'package'") {
+			t.Errorf("Server MUST store the code as given and tell why, but %+v given", m)
+		}
+	})
+	t.Run("formatter failure not shown", func(t *testing.T) {
+		if m := post(t, "slow"); m.Id != 1 || m.Formatted || m.FormatError != formatter.ErrFailed.Error() {
+			t.Errorf("Server MUST store the code as given without the formatter output, but %+v given", m)
+		}
+	})
+	t.Run("code stored as given without formatter", func(t *testing.T) {
+		if m := post(t, "print(1)"); m.Id != 1 || m.Formatted || m.FormatError != formatter.ErrUnsupportedLanguage.Error() {
+			t.Errorf("Server MUST store the code as given and tell why, but %+v given", m)
+		}
+	})
+}
+
+// formatCounter counts the formatter runs behind the fake.
+type formatCounter struct {
+	CodeSnippetFake
+	calls int
+}
+
+func (f *formatCounter) FormatCode(lang string, code string) (string, error) {
+	f.calls++
+	return f.CodeSnippetFake.FormatCode(lang, code)
+}
+
+func Test_postCodeFormatRejected(t *testing.T) {
+	team := uint(2)
+	tests := []struct {
+		name       string
+		token      string
+		m          PostCodeRequestModel
+		statusCode int
+	}{
+		{"team snippet without account", "", PostCodeRequestModel{Code: "unformatted", Lang: "go", Team: &team}, http.StatusUnauthorized},
+		{"invalid language", "", PostCodeRequestModel{Code: "unformatted", Lang: "petooh"}, http.StatusBadRequest},
+		{"invalid lint options", "", PostCodeRequestModel{Code: "unformatted", Lang: "go", LintOptions: LintOptionsModel{"dupl": {"threshold": "0"}}}, http.StatusBadRequest},
+		{"not a team member", "correct", PostCodeRequestModel{Code: "unformatted", Lang: "go", Team: &team}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &formatCounter{}
+			router := NewApi(&AccountFake{}, f).Router()
+			tc.m.Format = true
+			resp := makeJsonRequest(t, router, http.MethodPost, "/", tc.token, tc.m)
+			assertStatusCode(t, tc.statusCode, resp.Code)
+			if f.calls != 0 {
+				t.Errorf("Server MUST NOT format a rejected snippet, but %d runs given", f.calls)
+			}
+		})
+	}
+}
+
+func Test_getFormattedCode(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful formatted code", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/4/formatted", "")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		if body := resp.Body.String(); body != "package main\n" {
+			t.Errorf("Server MUST return the formatted code, but %q given", body)
+		}
+	})
+	t.Run("failure on unknown snippet", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/1/formatted", "")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on language without formatter", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/2/formatted", "")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure reported on code that does not format", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/3/formatted", "")
+		assertStatusCode(t, http.StatusUnprocessableEntity, resp.Code)
+		var m ValidationErrorResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Errors) != 1 || !strings.HasPrefix(m.Errors[0], "gofmt: ") {
+			t.Errorf("Server MUST tell why formatting failed, but %v given", m.Errors)
+		}
+	})
+	t.Run("failure on formatter failing", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/5/formatted", "")
+		assertStatusCode(t, http.StatusServiceUnavailable, resp.Code)
+		if body := resp.Body.String(); strings.Contains(body, "timed out") {
+			t.Errorf("Server MUST NOT show the formatter output, but %q given", body)
+		}
+	})
+	t.Run("formatted code is rate limited", func(t *testing.T) {
+		service.RateLimits = &RateLimits{
+			Limiter: ratelimitrepo.NewMemory(),
+			Routes:  map[string]RouteLimit{lintRoute: {PerIp: ratelimit.Rule{Requests: 1, Per: time.Hour}}},
+		}
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/4/formatted", "")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		resp = makeAuthorizedRequest(router, http.MethodGet, "/toad/4/formatted", "")
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+}
+
 func Test_getCode(t *testing.T) {
 	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
 	router := service.Router()
@@ -297,4 +1662,459 @@ func Test_getCode(t *testing.T) {
 		resp := makeGetCodeRequest(router, 3)
 		assertStatusCode(t, http.StatusOK, resp.Code)
 	})
-}
\ No newline at end of file
+}
+func Test_comments(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failed to comment anonymously", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/3/comments", "", PostCommentRequestModel{Body: "kva", StartLine: 1})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failed to comment on unknown snippet", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/1/comments", "correct", PostCommentRequestModel{Body: "kva", StartLine: 1})
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on empty comment", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/3/comments", "correct", PostCommentRequestModel{StartLine: 1})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on invalid line range", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/3/comments", "correct", PostCommentRequestModel{Body: "kva"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful comment creation", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/3/comments", "correct", PostCommentRequestModel{Body: "kva", StartLine: 1})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		var m CommentResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m.EndLine != 1 {
+			t.Errorf("Server MUST anchor a single line comment to its start line, but %d given", m.EndLine)
+		}
+	})
+	t.Run("successful obtainment of comments", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodGet, "/toad/3/comments", "", nil)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failed to hide comment without moderation rights", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/toad/3/comments/7/hidden", "internal", PutCommentHiddenRequestModel{Hidden: true})
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("successful comment hiding", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/toad/3/comments/7/hidden", "correct", PutCommentHiddenRequestModel{Hidden: true})
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("no such comment", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3/comments/9", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful comment deletion", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3/comments/7", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func Test_getCodeViews(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/toad/3", nil)
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("json view lists comments", func(t *testing.T) {
+		resp := get("application/json")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m SnippetResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Comments) != 2 || m.Comments[1].ParentId == nil || *m.Comments[1].ParentId != 7 {
+			t.Errorf("Server MUST list comments with their parents, but %v given", m.Comments)
+		}
+		if m.Stars != 2 {
+			t.Errorf("Server MUST show the star count, but %d given", m.Stars)
+		}
+		if !m.Checked || m.Lint.Status != "failed" || m.Lint.Linter != "dupl" || m.Lint.FinishedAt == nil || m.Lint.Error == "" {
+			t.Errorf("Server MUST show the lint status, but %+v given", m.Lint)
+		}
+	})
+	t.Run("html view threads and escapes comments", func(t *testing.T) {
+		resp := get("text/html,application/xhtml+xml;q=0.9")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		body := resp.Body.String()
+		if !strings.Contains(body, "&lt;b&gt;kva&lt;/b&gt;") {
+			t.Error("Server MUST escape comment bodies")
+		}
+		if !strings.Contains(body, `id="comment-8"`) || !strings.Contains(body, "hidden by a moderator") {
+			t.Error("Server MUST render replies and mark hidden comments")
+		}
+		if !strings.Contains(body, "Lint: failed by dupl") {
+			t.Error("Server MUST show the lint status")
+		}
+	})
+	t.Run("plain text stays the default", func(t *testing.T) {
+		resp := get("")
+		if resp.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("Server MUST default to text/plain, but %q given", resp.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(resp.Body.String(), "Lint: failed (dupl timed_out: linter timed out)") {
+			t.Errorf("Server MUST show the lint status, but %q given", resp.Body.String())
+		}
+	})
+}
+
+func Test_stars(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failed to star unknown snippet", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPut, "/toad/1/star", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful star", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPut, "/toad/3/star", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("successful unstar", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/toad/3/star", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("successful obtainment of starred links", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/stars?limit=1", "csp_read")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m PostLinksResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Links) != 1 || m.Links[0] != "/toad/5" {
+			t.Errorf("Server MUST return one page of links, but %v given", m.Links)
+		}
+	})
+	t.Run("failure on invalid page", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/stars?limit=1000", "correct")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on malformed page", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/myswamp?offset=toad", "correct")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on negative page of own links", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/myswamp?limit=-1", "correct")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func Test_webhooks(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.WebhookUseCases = &WebhookFake{}
+	router := service.Router()
+
+	t.Run("successful webhook creation", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/webhooks", "correct", PostWebhookRequestModel{
+			Url:    "https://example.com/hook",
+			Events: []string{"snippet.checked"},
+		})
+		assertStatusCode(t, http.StatusCreated, resp.Code)
+		var m WebhookResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m.Secret != "secret" {
+			t.Errorf("Server MUST return the signing secret once, but %q given", m.Secret)
+		}
+	})
+	t.Run("failure on invalid url", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/webhooks", "correct", PostWebhookRequestModel{Url: "ftp://example.com"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on private address", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/webhooks", "correct", PostWebhookRequestModel{Url: "http://localhost/hook"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on unknown event", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/webhooks", "correct", PostWebhookRequestModel{
+			Url:    "https://example.com/hook",
+			Events: []string{"snippet.eaten"},
+		})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure without authorization", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/webhooks", "", PostWebhookRequestModel{Url: "https://example.com/hook"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful obtainment of webhooks", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/webhooks", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m GetWebhooksResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Webhooks) != 1 || m.Webhooks[0].Secret != "" {
+			t.Errorf("Server MUST list webhooks without secrets, but %v given", m.Webhooks)
+		}
+	})
+	t.Run("successful obtainment of deliveries", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/webhooks/1/deliveries?limit=10", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m GetDeliveriesResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Deliveries) != 1 || m.Deliveries[0].State != "failed" || m.Deliveries[0].NextAttemptAt != nil {
+			t.Errorf("Server MUST list the delivery log, but %v given", m.Deliveries)
+		}
+	})
+	t.Run("failure on malformed delivery limit", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/webhooks/1/deliveries?limit=toad", "correct")
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("deliveries of unknown webhook", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/webhooks/2/deliveries", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("successful webhook deletion", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/webhooks/1", "correct")
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("deletion of unknown webhook", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/webhooks/2", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+}
+
+func Test_getCodeEvents(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful lint status stream", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/toad/3/events", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		if ct := resp.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Server MUST respond with an event stream, but %q given", ct)
+		}
+		body := resp.Body.String()
+		for _, event := range []string{"event: queued\n", "event: running\n", "event: checked\ndata: {\"status\":\"checked\",\"checked\":true,\"lint\":{\"status\":\"passed\"}}\n\n"} {
+			if !strings.Contains(body, event) {
+				t.Errorf("Server MUST stream %q, but %q given", event, body)
+			}
+		}
+	})
+	t.Run("heartbeat within a short lifetime", func(t *testing.T) {
+		service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+		service.EventStreamLifetime = 60 * time.Millisecond
+		req := httptest.NewRequest(http.MethodGet, "/toad/4/events", nil)
+		resp := httptest.NewRecorder()
+		service.Router().ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		if body := resp.Body.String(); !strings.Contains(body, ": heartbeat\n") {
+			t.Errorf("Server MUST send a heartbeat before the stream ends, but %q given", body)
+		}
+	})
+	t.Run("unknown snippet", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/toad/1/events", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("live status unavailable", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/toad/2/events", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusServiceUnavailable, resp.Code)
+	})
+}
+
+func Test_relint(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful relint", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/toad/3/relint", "correct")
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+		if loc := resp.Header().Get("Location"); loc != "/toad/3" {
+			t.Errorf("Server MUST point at the snippet, but %q given", loc)
+		}
+	})
+	t.Run("failure on unknown snippet", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/toad/1/relint", "correct")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on snippet of another user", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/toad/3/relint", "internal")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+	t.Run("failure on unauthorized", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/toad/3/relint", "", nil)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on snippet being linted", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/toad/4/relint", "correct")
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("relint is rate limited", func(t *testing.T) {
+		service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+		service.RateLimits = &RateLimits{
+			Limiter: ratelimitrepo.NewMemory(),
+			Routes: map[string]RouteLimit{
+				lintRoute: {PerAccount: ratelimit.Rule{Requests: 1, Per: time.Hour}},
+			},
+		}
+		router := service.Router()
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/toad/3/relint", "correct")
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+		resp = makeAuthorizedRequest(router, http.MethodPost, "/toad/3/relint", "correct")
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+	t.Run("successful bulk relint", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/admin/relint", "correct")
+		assertStatusCode(t, http.StatusAccepted, resp.Code)
+		var m PostRelintStaleResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m.Queued != 3 {
+			t.Errorf("Server MUST tell how many snippets are queued, but %d given", m.Queued)
+		}
+	})
+	t.Run("failure on bulk relint by non-admin", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodPost, "/admin/relint", "internal")
+		assertStatusCode(t, http.StatusForbidden, resp.Code)
+	})
+}
+
+func Test_getCanonicalCode(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful canonical code", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/4/canonical", "")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		if body := resp.Body.String(); body != "{\n  \"a\": 1\n}\n" {
+			t.Errorf("Server MUST return the canonical code, but %q given", body)
+		}
+	})
+	t.Run("failure on unknown snippet", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/1/canonical", "")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on language without canonical form", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/2/canonical", "")
+		assertStatusCode(t, http.StatusNotFound, resp.Code)
+	})
+	t.Run("failure on code that does not parse", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/toad/3/canonical", "")
+		assertStatusCode(t, http.StatusUnprocessableEntity, resp.Code)
+	})
+}
+
+func Test_postLint(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	service.RateLimits = &RateLimits{
+		Limiter: ratelimitrepo.NewMemory(),
+		Routes: map[string]RouteLimit{
+			postCodeRoute: {PerIp: ratelimit.Rule{Requests: 1, Per: time.Hour}},
+			lintRoute:     {PerIp: ratelimit.Rule{Requests: 2, Per: time.Hour}},
+		},
+	}
+	router := service.Router()
+
+	t.Run("successful lint", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", PostLintRequestModel{Code: "package main", Lang: "Go"})
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m PostLintResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if len(m.Reports) != 2 || len(m.Reports[0].Findings) != 1 || m.Reports[0].Findings[0].Line != 3 {
+			t.Errorf("Server MUST return the findings, but %v given", m.Reports)
+		}
+		if m.Reports[1].Error != linter.ErrTimeout.Error() || m.Reports[1].Status != string(linter.StatusTimedOut) {
+			t.Errorf("Server MUST report the timed out linter, but %v given", m.Reports[1])
+		}
+	})
+	t.Run("lint is limited separately from snippet creation", func(t *testing.T) {
+		makePostCodeRequest(t, router, "", "KoKoKoKoKoKoKoKoKoKo Kud-Kudah", "")
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", PostLintRequestModel{Code: "package main", Lang: "Go"})
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		resp = makeJsonRequest(t, router, http.MethodPost, "/lint", "", PostLintRequestModel{Code: "package main", Lang: "Go"})
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+}
+
+func Test_postLintValidation(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("failure on unsupported language", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", PostLintRequestModel{Code: "print(1)", Lang: "Cobol"})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on too long code", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", PostLintRequestModel{Code: strings.Repeat("x", 101), Lang: "Go"})
+		assertStatusCode(t, http.StatusRequestEntityTooLarge, resp.Code)
+	})
+	t.Run("failure on malformed request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/lint", strings.NewReader("{"))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("successful lint with options", func(t *testing.T) {
+		m := PostLintRequestModel{Code: "package main", Lang: "Go", LintOptions: LintOptionsModel{"dupl": {"threshold": 20}}}
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", m)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+	})
+	t.Run("failure on invalid option", func(t *testing.T) {
+		m := PostLintRequestModel{Code: "package main", Lang: "Go", LintOptions: LintOptionsModel{"dupl": {"threshold": 0}}}
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", m)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on option of wrong type", func(t *testing.T) {
+		m := PostLintRequestModel{Code: "package main", Lang: "Go", LintOptions: LintOptionsModel{"dupl": {"threshold": []int{20}}}}
+		resp := makeJsonRequest(t, router, http.MethodPost, "/lint", "", m)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func Test_lintOptions(t *testing.T) {
+	service := NewApi(&AccountFake{}, &CodeSnippetFake{})
+	router := service.Router()
+
+	t.Run("successful get", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodGet, "/me/lint-options", "correct")
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m LintOptionsModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal("failed to decode response")
+		}
+		if m["dupl"]["threshold"] != "30" {
+			t.Errorf("Server MUST return the default options, but %v given", m)
+		}
+	})
+	t.Run("successful put", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/lint-options", "correct", LintOptionsModel{"dupl": {"threshold": "20"}})
+		assertStatusCode(t, http.StatusNoContent, resp.Code)
+	})
+	t.Run("failure on invalid option", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/lint-options", "correct", LintOptionsModel{"dupl": {"threshold": 0}})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on unauthorized", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPut, "/me/lint-options", "", LintOptionsModel{})
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on invalid option on snippet creation", func(t *testing.T) {
+		m := PostCodeRequestModel{Code: "KoKoKo", Lang: "Go", LintOptions: LintOptionsModel{"dupl": {"threshold": 0}}}
+		resp := makeJsonRequest(t, router, http.MethodPost, "/", "", m)
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultEventStreamLifetime = time.Minute
+	maxEventStreamHeartbeat    = 15 * time.Second
+	eventStreamRetry           = time.Second
+)
+
+type LintStatusResponseModel struct {
+	Status  string             `json:"status"`
+	Checked bool               `json:"checked"`
+	Lint    *LintResponseModel `json:"lint,omitempty"`
+	Message string             `json:"message,omitempty"`
+}
+
+func (a *Api) eventStreamLifetime() time.Duration {
+	if a.EventStreamLifetime == 0 {
+		return defaultEventStreamLifetime
+	}
+	return a.EventStreamLifetime
+}
+
+// eventStreamHeartbeat fires a few times within the stream lifetime, so that
+// proxies see the stream alive however short it is.
+func (a *Api) eventStreamHeartbeat() time.Duration {
+	d := a.eventStreamLifetime() / 3
+	if d > maxEventStreamHeartbeat {
+		d = maxEventStreamHeartbeat
+	}
+	return d
+}
+
+// getCodeEvents streams the lint status as Server-Sent Events. The stream
+// ends after the result, or after EventStreamLifetime so that it does not
+// outlive the server write timeout, in which case the client reconnects.
+func (a *Api) getCodeEvents(w http.ResponseWriter, r *http.Request) {
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	statuses, cancel, err := a.CodeSnippetUseCases.WatchLintStatus(sid)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippetrepo.ErrInvalidSnippedId:
+
+			statusCode = http.StatusNotFound
+		case
+			codesnippet.ErrLiveStatusUnavailable:
+
+			statusCode = http.StatusServiceUnavailable
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+	flusher.Flush()
+
+	lifetime := time.NewTimer(a.eventStreamLifetime())
+	defer lifetime.Stop()
+	heartbeat := time.NewTicker(a.eventStreamHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lifetime.C:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case ls, ok := <-statuses:
+			if !ok {
+				return
+			}
+			m := LintStatusResponseModel{
+				Status:  string(ls.Status),
+				Checked: ls.Status == lintevent.Checked,
+				Message: ls.Message,
+			}
+			if m.Checked {
+				l := toLintResponseModel(ls.Lint)
+				m.Lint = &l
+			}
+			data, err := json.Marshal(m)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ls.Status, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/formatter"
+	"net/http"
+)
+
+// PostCodeResponseModel is only sent when formatting on save was asked for.
+// The code is stored as given when it could not be formatted.
+type PostCodeResponseModel struct {
+	Id          uint   `json:"id"`
+	Formatted   bool   `json:"formatted"`
+	FormatError string `json:"format_error,omitempty"`
+}
+
+func writeFormatError(w http.ResponseWriter, e *formatter.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(ValidationErrorResponseModel{Errors: []string{e.Error()}}); err != nil {
+		return
+	}
+}
+
+// formatErrorMessage keeps what a failing formatter process printed out of
+// the response.
+func formatErrorMessage(err error) string {
+	if errors.Is(err, formatter.ErrFailed) {
+		return formatter.ErrFailed.Error()
+	}
+	return err.Error()
+}
+
+func (a *Api) getFormattedCode(w http.ResponseWriter, r *http.Request) {
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code, err := a.CodeSnippetUseCases.GetFormattedCode(sid)
+	if err != nil {
+		var formatErr *formatter.Error
+		if errors.As(err, &formatErr) {
+			writeFormatError(w, formatErr)
+			return
+		}
+		var statusCode int
+		switch err {
+		case
+			codesnippetrepo.ErrInvalidSnippedId,
+			formatter.ErrUnsupportedLanguage:
+
+			statusCode = http.StatusNotFound
+		default:
+			statusCode = http.StatusInternalServerError
+			// not the code's fault, so not a 422 either
+			if errors.Is(err, formatter.ErrFailed) {
+				statusCode = http.StatusServiceUnavailable
+			}
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(code))
+}
@@ -0,0 +1,255 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	codesnippetrepository "github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"net/http"
+	"time"
+)
+
+// LintOptionsModel maps a linter name to its options. Values may be given as
+// strings or numbers.
+type LintOptionsModel map[string]map[string]interface{}
+
+func (m LintOptionsModel) toOptions() (linter.Options, bool) {
+	opts := make(linter.Options, len(m))
+	for name, values := range m {
+		opts[name] = make(map[string]string, len(values))
+		for option, v := range values {
+			switch v.(type) {
+			case string, float64:
+				opts[name][option] = fmt.Sprint(v)
+			default:
+				return nil, false
+			}
+		}
+	}
+	return opts, true
+}
+
+func toLintOptionsModel(opts linter.Options) LintOptionsModel {
+	m := make(LintOptionsModel, len(opts))
+	for name, values := range opts {
+		m[name] = make(map[string]interface{}, len(values))
+		for option, v := range values {
+			m[name][option] = v
+		}
+	}
+	return m
+}
+
+type PostLintRequestModel struct {
+	Code        string           `json:"code"`
+	Lang        string           `json:"lang"`
+	LintOptions LintOptionsModel `json:"lint_options,omitempty"`
+}
+
+type FindingResponseModel struct {
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type LintReportResponseModel struct {
+	Linter   string                 `json:"linter"`
+	Status   string                 `json:"status"`
+	Findings []FindingResponseModel `json:"findings"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// LintResponseModel is the state of linting a stored snippet.
+type LintResponseModel struct {
+	Status        string     `json:"status"`
+	Linter        string     `json:"linter,omitempty"`
+	LinterVersion string     `json:"linter_version,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+func toLintResponseModel(l codesnippetrepository.Lint) LintResponseModel {
+	m := LintResponseModel{
+		Status:        string(l.Status),
+		Linter:        l.Linter,
+		LinterVersion: l.LinterVersion,
+		Error:         l.Error,
+	}
+	if !l.StartedAt.IsZero() {
+		startedAt := l.StartedAt
+		m.StartedAt = &startedAt
+	}
+	if !l.FinishedAt.IsZero() {
+		finishedAt := l.FinishedAt
+		m.FinishedAt = &finishedAt
+	}
+	return m
+}
+
+type PostLintResponseModel struct {
+	Reports []LintReportResponseModel `json:"reports"`
+}
+
+func toLintReportResponseModel(r linter.Report) LintReportResponseModel {
+	m := LintReportResponseModel{
+		Linter:   r.Linter,
+		Status:   string(r.Status),
+		Findings: make([]FindingResponseModel, len(r.Findings)),
+	}
+	for i, f := range r.Findings {
+		m.Findings[i] = FindingResponseModel{Line: f.Line, EndLine: f.EndLine, Column: f.Column, Message: f.Message}
+	}
+	if r.Err != nil {
+		m.Error = r.Err.Error()
+	}
+	return m
+}
+
+func (a *Api) postLint(w http.ResponseWriter, r *http.Request) {
+	var m PostLintRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	opts, ok := m.LintOptions.toOptions()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var acc *account.Account = nil
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if ok {
+		a, err := a.AccountUseCases.GetAccountById(aid)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		acc = &a
+	}
+	reports, err := a.CodeSnippetUseCases.Lint(acc, m.Code, m.Lang, opts)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippet.ErrorUnsupportedLanguage,
+			linter.ErrInvalidOption:
+
+			statusCode = http.StatusBadRequest
+		case
+			codesnippet.ErrCodeTooLong:
+
+			statusCode = http.StatusRequestEntityTooLarge
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	mm := PostLintResponseModel{
+		Reports: make([]LintReportResponseModel, len(reports)),
+	}
+	for i, report := range reports {
+		mm.Reports[i] = toLintReportResponseModel(report)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) getLintOptions(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	opts, err := a.CodeSnippetUseCases.GetDefaultLintOptions(&acc)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toLintOptionsModel(opts)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) putLintOptions(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m LintOptionsModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	opts, ok := m.toOptions()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := a.CodeSnippetUseCases.SetDefaultLintOptions(acc, opts); err != nil {
+		var statusCode int
+		switch err {
+		case
+			linter.ErrInvalidOption:
+
+			statusCode = http.StatusBadRequest
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) getCanonicalCode(w http.ResponseWriter, r *http.Request) {
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code, err := a.CodeSnippetUseCases.GetCanonicalCode(sid)
+	if err != nil {
+		var statusCode int
+		switch err {
+		case
+			codesnippetrepo.ErrInvalidSnippedId,
+			linter.ErrNoCanonicalForm:
+
+			statusCode = http.StatusNotFound
+		case
+			linter.ErrInvalidCode:
+
+			statusCode = http.StatusUnprocessableEntity
+		default:
+			statusCode = http.StatusInternalServerError
+		}
+		w.WriteHeader(statusCode)
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(code))
+}
@@ -3,12 +3,25 @@ package httpapi
 import (
 	"context"
 	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
 	"net/http"
 	"strings"
 	"time"
 )
 
-func (a *Api) authenticate(handler http.HandlerFunc) http.HandlerFunc {
+func hasScopes(identity account.Identity, scopes []string) bool {
+	if len(scopes) == 0 {
+		return identity.IsSession()
+	}
+	for _, s := range scopes {
+		if !identity.HasScope(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Api) authenticate(handler http.HandlerFunc, scopes ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bearHeader := r.Header.Get("Authorization")
 		strArr := strings.Split(bearHeader, " ")
@@ -17,12 +30,17 @@ func (a *Api) authenticate(handler http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 		token := strArr[1]
-		id, err := a.AccountUseCases.Authenticate(token)
+		identity, err := a.AccountUseCases.Authenticate(token)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		ctx := context.WithValue(r.Context(), accountIdContextKey, id)
+		if !hasScopes(identity, scopes) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		ctx := context.WithValue(r.Context(), accountIdContextKey, identity.Id)
+		ctx = context.WithValue(ctx, tokenContextKey, token)
 		handler(w, r.WithContext(ctx))
 	}
 }
@@ -42,6 +60,13 @@ func (o *responseWriterObserver) WriteHeader(code int) {
 	o.status = code
 }
 
+// Flush keeps event streams working behind the observer.
+func (o *responseWriterObserver) Flush() {
+	if f, ok := o.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (o *responseWriterObserver) StatusCode() int {
 	if !o.wroteHeader {
 		return http.StatusOK
@@ -59,7 +84,7 @@ func (a *Api) logger(next http.Handler) http.Handler {
 	})
 }
 
-func (a *Api) authenticateOrNot(handler http.HandlerFunc) http.HandlerFunc {
+func (a *Api) authenticateOrNot(handler http.HandlerFunc, scopes ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		bearHeader := r.Header.Get("Authorization")
 		strArr := strings.Split(bearHeader, " ")
@@ -67,12 +92,17 @@ func (a *Api) authenticateOrNot(handler http.HandlerFunc) http.HandlerFunc {
 			handler(w, r)
 		} else {
 			token := strArr[1]
-			id, err := a.AccountUseCases.Authenticate(token)
+			identity, err := a.AccountUseCases.Authenticate(token)
 			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			ctx := context.WithValue(r.Context(), accountIdContextKey, id)
+			if !hasScopes(identity, scopes) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), accountIdContextKey, identity.Id)
+			ctx = context.WithValue(ctx, tokenContextKey, token)
 			handler(w, r.WithContext(ctx))
 		}
 	}
@@ -0,0 +1,119 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/accountrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/apitokenrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/identityrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/oidcrequestrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/sessionrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc/oidctest"
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Test_oidcLogin runs the authorization code flow against the stand-in
+// provider with the real account use cases on memory repos.
+func Test_oidcLogin(t *testing.T) {
+	provider, err := oidctest.NewServer("code-swamp", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := token.NewKeySet(token.Key{Id: "test", PrivateKey: k, PublicKey: &k.PublicKey, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := sessionrepo.NewMemory()
+	accounts := accountrepo.NewMemory(nil, nil)
+	u := &account.UseCases{
+		Auth:               token.NewJwt(keys, time.Minute, sessions),
+		AccountStorage:     accounts,
+		SessionStorage:     sessions,
+		ApiTokenStorage:    apitokenrepo.NewMemory(),
+		IdentityStorage:    identityrepo.NewMemory(),
+		OidcRequestStorage: oidcrequestrepo.NewMemory(),
+		Oidc: account.OidcSettings{
+			Providers: map[string]*oidc.Provider{
+				"corp": oidc.New(provider.Config("http://localhost/oidc/corp/callback"), nil),
+			},
+			RequestLifetime: time.Minute,
+		},
+		RefreshTokenLifetime: time.Hour,
+	}
+	router := NewApi(u, &CodeSnippetFake{}).Router()
+
+	// signin returns the callback request of the browser that started it
+	signin := func(t *testing.T, token string) *http.Request {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/oidc/corp/start", token, nil)
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m OidcStartResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		q, err := provider.Authorize(m.AuthorizationUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := &url.URL{Path: "/oidc/corp/callback", RawQuery: q.Encode()}
+		req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+		for _, c := range resp.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	var access string
+	t.Run("first sign in creates an account without password", func(t *testing.T) {
+		resp := serve(signin(t, ""))
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		var m TokensResponseModel
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		access = m.AccessToken
+		acc, err := accounts.GetAccountById(0)
+		if err != nil || acc.Password != "" || !acc.EmailVerified {
+			t.Errorf("account MUST be created without password and with verified email, but %+v (%v) given", acc, err)
+		}
+	})
+	t.Run("second sign in reuses the account", func(t *testing.T) {
+		resp := serve(signin(t, ""))
+		assertStatusCode(t, http.StatusOK, resp.Code)
+		if _, err := accounts.GetAccountById(1); err == nil {
+			t.Error("second sign in MUST NOT create another account")
+		}
+	})
+	t.Run("failure on replayed callback", func(t *testing.T) {
+		callback := signin(t, "")
+		serve(callback)
+		resp := serve(callback.Clone(callback.Context()))
+		assertStatusCode(t, http.StatusBadRequest, resp.Code)
+	})
+	t.Run("failure on unlinking the only credential", func(t *testing.T) {
+		resp := makeAuthorizedRequest(router, http.MethodDelete, "/identities/corp", access)
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+	t.Run("failure on linking an identity twice", func(t *testing.T) {
+		resp := serve(signin(t, access))
+		assertStatusCode(t, http.StatusConflict, resp.Code)
+	})
+}
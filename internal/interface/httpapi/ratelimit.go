@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/ratelimit"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/prom"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signupRoute   = "signup"
+	signinRoute   = "signin"
+	refreshRoute  = "refresh"
+	postCodeRoute = "post_code"
+	emailRoute    = "email"
+	passwordRoute = "password_reset"
+	commentRoute  = "comment"
+	lintRoute     = "lint"
+
+	ipKey      = "ip"
+	accountKey = "account"
+)
+
+type RouteLimit struct {
+	PerIp      ratelimit.Rule
+	PerAccount ratelimit.Rule
+}
+
+type RateLimits struct {
+	Limiter ratelimit.Interface
+	Routes  map[string]RouteLimit
+}
+
+// ParseTrustedProxies parses addresses and CIDR blocks of proxies.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func (a *Api) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range a.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIp is the address of the peer, unless it is a trusted proxy. Then it
+// is the rightmost X-Forwarded-For hop that is not one, as every hop left of
+// the first untrusted one may be made up by the client.
+func (a *Api) clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !a.TrustForwardedFor || !a.isTrustedProxy(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !a.isTrustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+	// proxies all the way down
+	return host
+}
+
+type limitCheck struct {
+	kind string
+	key  string
+	rule ratelimit.Rule
+}
+
+// take spends a token of every limit, but only once all of them have one to
+// spare, so that a request rejected by one limit does not count against the
+// others.
+func (l *RateLimits) take(route string, checks []limitCheck) (bool, time.Duration) {
+	var active []limitCheck
+	for _, c := range checks {
+		if !c.rule.IsZero() {
+			active = append(active, c)
+		}
+	}
+	for _, c := range active {
+		allowed, retryAfter, err := l.Limiter.Peek(route+":"+c.kind+":"+c.key, c.rule)
+		if err != nil {
+			prom.RateLimiterFailed()
+			fmt.Printf("rate limiter failed: %s\n", err)
+			return true, 0
+		}
+		if !allowed {
+			prom.RateLimited(route, c.kind)
+			return false, retryAfter
+		}
+	}
+	for _, c := range active {
+		allowed, retryAfter, err := l.Limiter.Take(route+":"+c.kind+":"+c.key, c.rule)
+		if err != nil {
+			prom.RateLimiterFailed()
+			fmt.Printf("rate limiter failed: %s\n", err)
+			return true, 0
+		}
+		// another request took the last token in the meantime
+		if !allowed {
+			prom.RateLimited(route, c.kind)
+			return false, retryAfter
+		}
+	}
+	return true, 0
+}
+
+func (a *Api) rateLimit(route string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.RateLimits == nil || a.RateLimits.Limiter == nil {
+			handler(w, r)
+			return
+		}
+		limit, ok := a.RateLimits.Routes[route]
+		if !ok {
+			handler(w, r)
+			return
+		}
+		checks := []limitCheck{{kind: ipKey, key: a.clientIp(r), rule: limit.PerIp}}
+		if aid, ok := r.Context().Value(accountIdContextKey).(uint); ok {
+			checks = append(checks, limitCheck{kind: accountKey, key: strconv.FormatUint(uint64(aid), 10), rule: limit.PerAccount})
+		}
+		allowed, retryAfter := a.RateLimits.take(route, checks)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		handler(w, r)
+	}
+}
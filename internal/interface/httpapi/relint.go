@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/codesnippet"
+	"net/http"
+)
+
+func relintStatusCode(err error) int {
+	switch err {
+	case
+		codesnippetrepo.ErrInvalidSnippedId:
+
+		return http.StatusNotFound
+	case
+		codesnippet.ErrNotSnippetOwner,
+		codesnippet.ErrNotAdmin:
+
+		return http.StatusForbidden
+	case
+		codesnippet.ErrRelintInProgress,
+		codesnippet.ErrLintInProgress:
+
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (a *Api) postRelint(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sid, ok := urlPathId(r, snippetIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := a.CodeSnippetUseCases.Relint(acc, sid); err != nil {
+		w.WriteHeader(relintStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/toad/%d", sid))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type PostRelintStaleResponseModel struct {
+	Queued int `json:"queued"`
+}
+
+func (a *Api) postRelintStale(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	acc, err := a.AccountUseCases.GetAccountById(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	n, err := a.CodeSnippetUseCases.RelintStale(acc)
+	if err != nil {
+		w.WriteHeader(relintStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(PostRelintStaleResponseModel{Queued: n}); err != nil {
+		return
+	}
+}
@@ -0,0 +1,168 @@
+package httpapi
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	"html/template"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	mediaTypeJson = "application/json"
+	mediaTypeHtml = "text/html"
+)
+
+// acceptedMediaType picks the first of the offered media types the Accept
+// header lists, ignoring quality values. It falls back to the last offer.
+func acceptedMediaType(r *http.Request, offers ...string) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for _, offer := range offers {
+			if mediaType == offer {
+				return offer
+			}
+		}
+	}
+	return offers[len(offers)-1]
+}
+
+type CommentResponseModel struct {
+	Id        uint      `json:"id"`
+	ParentId  *uint     `json:"parent_id,omitempty"`
+	Uid       uint      `json:"uid"`
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Body      string    `json:"body"`
+	Hidden    bool      `json:"hidden"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toCommentResponseModel(c comment.Comment) CommentResponseModel {
+	m := CommentResponseModel{
+		Id:        c.Id,
+		Uid:       c.Uid,
+		StartLine: c.StartLine,
+		EndLine:   c.EndLine,
+		Body:      c.Body,
+		Hidden:    c.Hidden,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.HasParent {
+		parentId := c.ParentId
+		m.ParentId = &parentId
+	}
+	return m
+}
+
+type SnippetResponseModel struct {
+	Id       uint                   `json:"id"`
+	Code     string                 `json:"code"`
+	Lang     string                 `json:"lang"`
+	Checked  bool                   `json:"checked"`
+	Lint     LintResponseModel      `json:"lint"`
+	Message  string                 `json:"message"`
+	Stars    uint                   `json:"stars"`
+	Comments []CommentResponseModel `json:"comments"`
+}
+
+func toSnippetResponseModel(sid uint, s codesnippet.CodeSnippet, cc []comment.Comment) SnippetResponseModel {
+	m := SnippetResponseModel{
+		Id:       sid,
+		Code:     s.Code,
+		Lang:     s.Lang,
+		Checked:  s.Lint.Status.Done(),
+		Lint:     toLintResponseModel(s.Lint),
+		Message:  s.Message,
+		Comments: make([]CommentResponseModel, len(cc)),
+	}
+	for i, c := range cc {
+		m.Comments[i] = toCommentResponseModel(c)
+	}
+	return m
+}
+
+type commentView struct {
+	CommentResponseModel
+	Replies []*commentView
+}
+
+type lineView struct {
+	Number  int
+	Text    string
+	Threads []*commentView
+}
+
+type snippetView struct {
+	SnippetResponseModel
+	Status string
+	Lines  []lineView
+}
+
+// newSnippetView threads the comments and attaches each thread below the
+// last line it is anchored to.
+func newSnippetView(m SnippetResponseModel) snippetView {
+	v := snippetView{SnippetResponseModel: m, Status: m.Message}
+	if !m.Checked {
+		v.Status = "Not checked yet"
+	}
+	for i, text := range strings.Split(strings.TrimSuffix(m.Code, "\n"), "\n") {
+		v.Lines = append(v.Lines, lineView{Number: i + 1, Text: text})
+	}
+	views := make(map[uint]*commentView, len(m.Comments))
+	for _, c := range m.Comments {
+		views[c.Id] = &commentView{CommentResponseModel: c}
+	}
+	for _, c := range m.Comments {
+		cv := views[c.Id]
+		if c.ParentId != nil {
+			if parent, ok := views[*c.ParentId]; ok {
+				parent.Replies = append(parent.Replies, cv)
+				continue
+			}
+		}
+		if c.EndLine >= 1 && c.EndLine <= len(v.Lines) {
+			v.Lines[c.EndLine-1].Threads = append(v.Lines[c.EndLine-1].Threads, cv)
+		}
+	}
+	return v
+}
+
+var snippetTemplate = template.Must(template.New("snippet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Toad {{.Id}}</title>
+<style>
+td.line { color: #888; text-align: right; vertical-align: top; padding-right: 1em; }
+pre { margin: 0; }
+.comment { border-left: 2px solid #ccc; margin: .5em 0 .5em 1em; padding-left: .5em; font-family: sans-serif; }
+.hidden { color: #888; font-style: italic; }
+.body { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<p>Status: {{.Status}} &middot; Lint: {{.Lint.Status}}
+{{- with .Lint.Linter}} by {{.}}{{end}}{{with .Lint.LinterVersion}} ({{.}}){{end}}
+{{- with .Lint.Error}} &middot; {{.}}{{end}} &middot; &#9733; {{.Stars}}</p>
+<table>
+{{- range .Lines}}
+<tr id="L{{.Number}}"><td class="line">{{.Number}}</td><td><pre>{{.Text}}</pre>
+{{- range .Threads}}{{template "comment" .}}{{end -}}
+</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+{{define "comment"}}<div class="comment" id="comment-{{.Id}}">
+<div>#{{.Id}} by {{.Uid}} on lines {{.StartLine}}-{{.EndLine}}</div>
+{{- if .Hidden}}<div class="hidden">hidden by a moderator</div>{{end}}
+{{- if .Body}}<div class="body">{{.Body}}</div>{{end}}
+{{- range .Replies}}{{template "comment" .}}{{end -}}
+</div>{{end}}
+`))
@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/accountrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/apitokenrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/challengerepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/loginattemptrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/sessionrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passhash"
+	"github.com/mp-hl-2021/code-swamp/internal/service/secretbox"
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"github.com/mp-hl-2021/code-swamp/internal/service/totp"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test_totpThrottling guesses second factor codes with a signed in session,
+// with the real account use cases on memory repos.
+func Test_totpThrottling(t *testing.T) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := token.NewKeySet(token.Key{Id: "test", PrivateKey: k, PublicKey: &k.PublicKey, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := secretbox.New(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := sessionrepo.NewMemory()
+	u := &account.UseCases{
+		Auth:             token.NewJwt(keys, time.Minute, sessions),
+		AccountStorage:   accountrepo.NewMemory(nil, nil),
+		SessionStorage:   sessions,
+		ApiTokenStorage:  apitokenrepo.NewMemory(),
+		ChallengeStorage: challengerepo.NewMemory(),
+		TwoFactor: account.TwoFactorSettings{
+			Issuer:            "code-swamp",
+			ChallengeLifetime: time.Minute,
+			Secrets:           box,
+		},
+		LoginThrottling: &account.LoginThrottling{
+			Storage:  loginattemptrepo.NewMemory(),
+			PerLogin: account.ThrottlePolicy{FreeAttempts: 2, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour},
+		},
+		PasswordHasher:       &passhash.Hasher{Algorithm: passhash.Bcrypt, BcryptCost: 4},
+		RefreshTokenLifetime: time.Hour,
+	}
+	if _, err := u.CreateAccount("katyukha", "Kud-Kudah 1"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := u.LoginToAccount("katyukha", "Kud-Kudah 1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := tokens.AccessToken
+	router := NewApi(u, &CodeSnippetFake{}).Router()
+
+	resp := makeAuthorizedRequest(router, http.MethodPost, "/me/totp", access)
+	assertStatusCode(t, http.StatusCreated, resp.Code)
+	var e TotpEnrollmentResponseModel
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(e.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = makeJsonRequest(t, router, http.MethodPost, "/me/totp/confirm", access, PostTotpCodeRequestModel{Code: code})
+	assertStatusCode(t, http.StatusOK, resp.Code)
+
+	t.Run("failure after repeated wrong codes", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp := makeJsonRequest(t, router, http.MethodDelete, "/me/totp", access, DeleteTotpRequestModel{Code: "000000"})
+			assertStatusCode(t, http.StatusUnauthorized, resp.Code)
+		}
+		resp := makeJsonRequest(t, router, http.MethodDelete, "/me/totp", access, DeleteTotpRequestModel{Code: "000000"})
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+		if resp.Header().Get("Retry-After") != "60" {
+			t.Errorf("Server MUST tell when to retry, but %q given", resp.Header().Get("Retry-After"))
+		}
+	})
+	t.Run("failure on other second factor routes once locked out", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/me/totp/recovery-codes", access, PostTotpCodeRequestModel{Code: "000000"})
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+	t.Run("failure on sign in once locked out", func(t *testing.T) {
+		resp := makeJsonRequest(t, router, http.MethodPost, "/signin", "", PostSigninRequestModel{Login: "katyukha", Password: "Kud-Kudah 1"})
+		assertStatusCode(t, http.StatusTooManyRequests, resp.Code)
+	})
+}
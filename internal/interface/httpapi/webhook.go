@@ -0,0 +1,209 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	webhookrepository "github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func webhookStatusCode(err error) int {
+	switch err {
+	case
+		webhookrepository.ErrNotFound:
+
+		return http.StatusNotFound
+	case
+		webhook.ErrInvalidUrl,
+		webhook.ErrInvalidEvent,
+		webhook.ErrPrivateAddress:
+
+		return http.StatusBadRequest
+	case
+		webhook.ErrTooManyWebhooks:
+
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type PostWebhookRequestModel struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type WebhookResponseModel struct {
+	Id        uint      `json:"id"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func toWebhookResponseModel(wh webhook.Webhook) WebhookResponseModel {
+	m := WebhookResponseModel{
+		Id:        wh.Id,
+		Url:       wh.Url,
+		Events:    make([]string, len(wh.Events)),
+		CreatedAt: wh.CreatedAt,
+	}
+	for i, e := range wh.Events {
+		m.Events[i] = string(e)
+	}
+	return m
+}
+
+func (a *Api) postWebhook(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var m PostWebhookRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	events := make([]webhookrepository.Event, len(m.Events))
+	for i, e := range m.Events {
+		events[i] = webhookrepository.Event(e)
+	}
+	wh, secret, err := a.WebhookUseCases.CreateWebhook(aid, m.Url, events)
+	if err != nil {
+		w.WriteHeader(webhookStatusCode(err))
+		fmt.Println(err)
+		return
+	}
+	mm := toWebhookResponseModel(wh)
+	mm.Secret = secret
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/webhooks/%d", wh.Id))
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		return
+	}
+}
+
+type GetWebhooksResponseModel struct {
+	Webhooks []WebhookResponseModel `json:"webhooks"`
+}
+
+func (a *Api) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ww, err := a.WebhookUseCases.GetWebhooks(aid)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	mm := GetWebhooksResponseModel{
+		Webhooks: make([]WebhookResponseModel, len(ww)),
+	}
+	for i, wh := range ww {
+		mm.Webhooks[i] = toWebhookResponseModel(wh)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func (a *Api) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, ok := urlPathId(r, webhookIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := a.WebhookUseCases.DeleteWebhook(aid, id); err != nil {
+		w.WriteHeader(webhookStatusCode(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type DeliveryResponseModel struct {
+	Id            uint            `json:"id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func toDeliveryResponseModel(d webhookrepository.Delivery) DeliveryResponseModel {
+	m := DeliveryResponseModel{
+		Id:         d.Id,
+		Event:      string(d.Event),
+		Payload:    d.Payload,
+		State:      string(d.State),
+		Attempts:   d.Attempts,
+		StatusCode: d.StatusCode,
+		Error:      d.Error,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+	if d.State == webhookrepository.Pending {
+		next := d.NextAttemptAt
+		m.NextAttemptAt = &next
+	}
+	return m
+}
+
+type GetDeliveriesResponseModel struct {
+	Deliveries []DeliveryResponseModel `json:"deliveries"`
+}
+
+func (a *Api) getDeliveries(w http.ResponseWriter, r *http.Request) {
+	aid, ok := r.Context().Value(accountIdContextKey).(uint)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, ok := urlPathId(r, webhookIdUrlPathKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		l, err := strconv.Atoi(s)
+		if err != nil || l < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+	dd, err := a.WebhookUseCases.GetDeliveries(aid, id, limit)
+	if err != nil {
+		w.WriteHeader(webhookStatusCode(err))
+		return
+	}
+	mm := GetDeliveriesResponseModel{
+		Deliveries: make([]DeliveryResponseModel, len(dd)),
+	}
+	for i, d := range dd {
+		mm.Deliveries[i] = toDeliveryResponseModel(d)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(mm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
@@ -2,20 +2,38 @@ package accountrepo
 
 import (
 	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
 	"sync"
 )
 
+type SnippetOwners interface {
+	DeleteSnippetsByUser(uid uint) error
+	AnonymiseSnippetsByUser(uid uint) error
+}
+
+// TeamMembers fails with team.ErrLastOwner rather than leave a team without
+// an owner.
+type TeamMembers interface {
+	DeleteMembershipsByUser(uid uint) error
+}
+
 type Memory struct {
 	accountsById    map[uint]account.Account
 	accountsByLogin map[string]account.Account
+	recoveryCodes   map[uint]map[string]bool
+	snippets        SnippetOwners
+	teams           TeamMembers
 	nextId          uint
 	mu              *sync.Mutex
 }
 
-func NewMemory() *Memory {
+func NewMemory(snippets SnippetOwners, teams TeamMembers) *Memory {
 	return &Memory{
 		accountsById:    make(map[uint]account.Account),
 		accountsByLogin: make(map[string]account.Account),
+		recoveryCodes:   make(map[uint]map[string]bool),
+		snippets:        snippets,
+		teams:           teams,
 		mu:              &sync.Mutex{},
 	}
 }
@@ -23,7 +41,7 @@ func NewMemory() *Memory {
 func (m *Memory) CreateAccount(cred account.Credentials) (account.Account, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.accountsByLogin[cred.Login]; ok {
+	if _, ok := m.accountsByLogin[cred.CanonicalLogin]; ok {
 		return account.Account{}, account.ErrAlreadyExist
 	}
 	a := account.Account {
@@ -31,7 +49,7 @@ func (m *Memory) CreateAccount(cred account.Credentials) (account.Account, error
 		Credentials: cred,
 	}
 	m.accountsById[a.Id] = a
-	m.accountsByLogin[a.Login] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
 	m.nextId++
 	return a, nil
 }
@@ -46,12 +64,154 @@ func (m *Memory) GetAccountById(id uint) (account.Account, error) {
 	return a, nil
 }
 
-func (m *Memory) GetAccountByLogin(login string) (account.Account, error) {
+func (m *Memory) GetAccountByLogin(canonicalLogin string) (account.Account, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	a, ok := m.accountsByLogin[login]
+	a, ok := m.accountsByLogin[canonicalLogin]
 	if !ok {
 		return a, account.ErrNotFound
 	}
 	return a, nil
-}
\ No newline at end of file
+}
+
+func (m *Memory) UpdatePassword(id uint, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+	a.Password = password
+	m.accountsById[a.Id] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
+	return nil
+}
+
+func (m *Memory) SetEmail(id uint, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+	a.Email = email
+	a.EmailVerified = false
+	m.accountsById[a.Id] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
+	return nil
+}
+
+func (m *Memory) VerifyEmail(id uint, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok || a.Email != email {
+		return account.ErrNotFound
+	}
+	for _, other := range m.accountsById {
+		if other.Id != id && other.EmailVerified && other.Email == email {
+			return account.ErrAlreadyExist
+		}
+	}
+	a.EmailVerified = true
+	m.accountsById[a.Id] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
+	return nil
+}
+
+func (m *Memory) GetAccountByVerifiedEmail(email string) (account.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.accountsById {
+		if a.EmailVerified && a.Email == email {
+			return a, nil
+		}
+	}
+	return account.Account{}, account.ErrNotFound
+}
+
+func (m *Memory) SetTotp(id uint, secret string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+	a.TotpSecret = secret
+	a.TotpEnabled = enabled
+	m.accountsById[a.Id] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
+	return nil
+}
+
+func (m *Memory) UseTotpStep(id uint, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+	if step <= a.TotpLastStep {
+		return account.ErrTotpStepUsed
+	}
+	a.TotpLastStep = step
+	m.accountsById[a.Id] = a
+	m.accountsByLogin[a.CanonicalLogin] = a
+	return nil
+}
+
+func (m *Memory) SetRecoveryCodes(id uint, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accountsById[id]; !ok {
+		return account.ErrNotFound
+	}
+	codes := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		codes[hash] = true
+	}
+	m.recoveryCodes[id] = codes
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(id uint, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.recoveryCodes[id][hash] {
+		return account.ErrNotFound
+	}
+	delete(m.recoveryCodes[id], hash)
+	return nil
+}
+
+func (m *Memory) DeleteAccount(id uint, snippets account.SnippetPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accountsById[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+	if m.teams != nil {
+		if err := m.teams.DeleteMembershipsByUser(id); err != nil {
+			if err == team.ErrLastOwner {
+				return account.ErrSoleTeamOwner
+			}
+			return err
+		}
+	}
+	if m.snippets != nil {
+		var err error
+		if snippets == account.AnonymiseSnippets {
+			err = m.snippets.AnonymiseSnippetsByUser(id)
+		} else {
+			err = m.snippets.DeleteSnippetsByUser(id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	delete(m.accountsById, a.Id)
+	delete(m.accountsByLogin, a.CanonicalLogin)
+	delete(m.recoveryCodes, a.Id)
+	return nil
+}
@@ -0,0 +1,86 @@
+package apitokenrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/apitoken"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	tokensById map[uint]apitoken.ApiToken
+	nextId     uint
+	mu         *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tokensById: make(map[uint]apitoken.ApiToken),
+		mu:         &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateApiToken(t apitoken.ApiToken) (apitoken.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.Id = m.nextId
+	m.nextId++
+	m.tokensById[t.Id] = t
+	return t, nil
+}
+
+func (m *Memory) GetApiTokenByHash(hash string) (apitoken.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokensById {
+		if t.Hash == hash {
+			return t, nil
+		}
+	}
+	return apitoken.ApiToken{}, apitoken.ErrNotFound
+}
+
+func (m *Memory) GetApiTokensByUser(uid uint) ([]apitoken.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ts []apitoken.ApiToken
+	for _, t := range m.tokensById {
+		if t.Uid == uid {
+			ts = append(ts, t)
+		}
+	}
+	return ts, nil
+}
+
+func (m *Memory) TouchApiToken(id uint, lastUsedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokensById[id]
+	if !ok {
+		return apitoken.ErrNotFound
+	}
+	t.LastUsedAt = lastUsedAt
+	m.tokensById[id] = t
+	return nil
+}
+
+func (m *Memory) DeleteApiToken(uid, id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokensById[id]
+	if !ok || t.Uid != uid {
+		return apitoken.ErrNotFound
+	}
+	delete(m.tokensById, id)
+	return nil
+}
+
+func (m *Memory) DeleteApiTokensByUser(uid uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range m.tokensById {
+		if t.Uid == uid {
+			delete(m.tokensById, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package challengerepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/challenge"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	challengesByHash map[string]challenge.Challenge
+	mu               *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		challengesByHash: make(map[string]challenge.Challenge),
+		mu:               &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateChallenge(c challenge.Challenge) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challengesByHash[c.Hash] = c
+	return nil
+}
+
+func (m *Memory) GetChallenge(hash string) (challenge.Challenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.challengesByHash[hash]
+	if !ok || !c.ExpiresAt.After(time.Now()) {
+		return challenge.Challenge{}, challenge.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *Memory) RecordFailure(hash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.challengesByHash[hash]
+	if !ok {
+		return 0, challenge.ErrNotFound
+	}
+	c.Failures++
+	m.challengesByHash[hash] = c
+	return c.Failures, nil
+}
+
+func (m *Memory) DeleteChallenge(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.challengesByHash[hash]; !ok {
+		return challenge.ErrNotFound
+	}
+	delete(m.challengesByHash, hash)
+	return nil
+}
+
+func (m *Memory) DeleteExpiredChallenges() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for hash, c := range m.challengesByHash {
+		if !c.ExpiresAt.After(now) {
+			delete(m.challengesByHash, hash)
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package codesnippetrepo
 import (
 	"errors"
 	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,15 +17,40 @@ type SnippetInfo struct {
 	cs         codesnippet.CodeSnippet
 	uid        uint
 	userExists bool
+	tid        uint
+	teamExists bool
+	created    time.Time
 	exptime    time.Time
 }
 
 type Memory struct {
 	snippetById map[uint]SnippetInfo
+	onDelete    []func(sid uint)
 	nextId      uint
 	mu          *sync.Mutex
 }
 
+// OnDelete calls f with every snippet deleted or expired from now on, for
+// the other memory repos to drop what the postgres cascade would.
+func (m *Memory) OnDelete(f func(sid uint)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onDelete = append(m.onDelete, f)
+}
+
+// deleted is deferred before the lock is taken, so that the callbacks run
+// once it is released and may call back.
+func (m *Memory) deleted(sids *[]uint) {
+	m.mu.Lock()
+	callbacks := m.onDelete
+	m.mu.Unlock()
+	for _, sid := range *sids {
+		for _, f := range callbacks {
+			f(sid)
+		}
+	}
+}
+
 func NewMemory() *Memory {
 	return &Memory{
 		snippetById: make(map[uint]SnippetInfo),
@@ -41,6 +68,7 @@ func (m *Memory) CreateCodeSnippet(s codesnippet.CodeSnippet) (uint, error) {
 		cs:         s,
 		uid:        0,
 		userExists: false,
+		created:    time.Now(),
 		exptime:    time.Now().Add(s.Lifetime),
 	}
 	return sid, nil
@@ -55,6 +83,24 @@ func (m *Memory) CreateCodeSnippetWithUser(s codesnippet.CodeSnippet, uid uint)
 		cs:         s,
 		uid:        uid,
 		userExists: true,
+		created:    time.Now(),
+		exptime:    time.Now().Add(s.Lifetime),
+	}
+	return sid, nil
+}
+
+func (m *Memory) CreateCodeSnippetWithTeam(s codesnippet.CodeSnippet, uid, tid uint) (uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sid := m.nextId
+	m.nextId += 1
+	m.snippetById[sid] = SnippetInfo{
+		cs:         s,
+		uid:        uid,
+		userExists: true,
+		tid:        tid,
+		teamExists: true,
+		created:    time.Now(),
 		exptime:    time.Now().Add(s.Lifetime),
 	}
 	return sid, nil
@@ -67,10 +113,14 @@ func (m *Memory) GetCodeSnippetById(sid uint) (codesnippet.CodeSnippet, error) {
 	if !ok {
 		return codesnippet.CodeSnippet{}, ErrInvalidSnippedId
 	}
-	return s.cs, nil
+	cs := s.cs
+	cs.Uid, cs.HasUser = s.uid, s.userExists
+	cs.Tid, cs.HasTeam = s.tid, s.teamExists
+	cs.CreatedAt = s.created
+	return cs, nil
 }
 
-func (m *Memory) GetMyCodeSnippetIds(uid uint) ([]uint, error) {
+func (m *Memory) GetMyCodeSnippetIds(uid uint, limit, offset int) ([]uint, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	var ids []uint
@@ -79,18 +129,160 @@ func (m *Memory) GetMyCodeSnippetIds(uid uint) ([]uint, error) {
 			ids = append(ids, sid)
 		}
 	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	if offset >= len(ids) {
+		return nil, nil
+	}
+	ids = ids[offset:]
+	if limit < len(ids) {
+		ids = ids[:limit]
+	}
 	return ids, nil
 }
 
-func (m *Memory) DeleteExpiredSnippets() error {
+func (m *Memory) GetTeamCodeSnippetIds(tid uint) ([]uint, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	validSnippets := make(map[uint]SnippetInfo)
+	var ids []uint
 	for sid, i := range m.snippetById {
-		if i.exptime.After(time.Now()) {
-			validSnippets[sid] = i
+		if i.teamExists && i.tid == tid {
+			ids = append(ids, sid)
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) DeleteTeamCodeSnippet(sid uint, tid uint) error {
+	var removed []uint
+	defer m.deleted(&removed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snippetById[sid]
+	if !ok || !s.teamExists || s.tid != tid {
+		return ErrInvalidSnippedId
+	}
+	delete(m.snippetById, sid)
+	removed = append(removed, sid)
+	return nil
+}
+
+func (m *Memory) DeleteSnippetsByTeam(tid uint) error {
+	var removed []uint
+	defer m.deleted(&removed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, i := range m.snippetById {
+		if i.teamExists && i.tid == tid {
+			delete(m.snippetById, sid)
+			removed = append(removed, sid)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteCodeSnippet(sid uint, uid uint) error {
+	var removed []uint
+	defer m.deleted(&removed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snippetById[sid]
+	if !ok || !s.userExists || s.uid != uid {
+		return ErrInvalidSnippedId
+	}
+	delete(m.snippetById, sid)
+	removed = append(removed, sid)
+	return nil
+}
+
+func (m *Memory) DeleteExpiredSnippets() ([]codesnippet.ExpiredSnippet, error) {
+	var removed []uint
+	defer m.deleted(&removed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired []codesnippet.ExpiredSnippet
+	for sid, i := range m.snippetById {
+		if !i.exptime.After(time.Now()) {
+			expired = append(expired, codesnippet.ExpiredSnippet{
+				Sid:     sid,
+				Uid:     i.uid,
+				HasUser: i.userExists,
+				Tid:     i.tid,
+				HasTeam: i.teamExists,
+			})
+			delete(m.snippetById, sid)
+			removed = append(removed, sid)
+		}
+	}
+	return expired, nil
+}
+
+func (m *Memory) SetLint(sid uint, l codesnippet.Lint, msg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snippetById[sid]
+	if !ok {
+		return ErrInvalidSnippedId
+	}
+	s.cs.Lint = l
+	s.cs.Message = msg
+	m.snippetById[sid] = s
+	return nil
+}
+
+func (m *Memory) GetStaleLintSnippetIds(lang string, linter string, version string, stuckBefore time.Time) ([]uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []uint
+	for sid, i := range m.snippetById {
+		l := i.cs.Lint
+		if !i.exptime.After(time.Now()) || !strings.EqualFold(i.cs.Lang, lang) {
+			continue
+		}
+		if !l.Status.Done() {
+			started := l.StartedAt
+			if started.IsZero() {
+				started = i.created
+			}
+			if started.Before(stuckBefore) {
+				ids = append(ids, sid)
+			}
+			continue
+		}
+		if l.Linter != linter || l.LinterVersion != version {
+			ids = append(ids, sid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+func (m *Memory) DeleteSnippetsByUser(uid uint) error {
+	var removed []uint
+	defer m.deleted(&removed)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, i := range m.snippetById {
+		if i.userExists && i.uid == uid {
+			delete(m.snippetById, sid)
+			removed = append(removed, sid)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) AnonymiseSnippetsByUser(uid uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, i := range m.snippetById {
+		if i.userExists && i.uid == uid {
+			i.uid = 0
+			i.userExists = false
+			m.snippetById[sid] = i
 		}
 	}
-	m.snippetById = validSnippets
 	return nil
 }
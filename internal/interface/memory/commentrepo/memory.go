@@ -0,0 +1,94 @@
+package commentrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	commentsById map[uint]comment.Comment
+	nextId       uint
+	mu           *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		commentsById: make(map[uint]comment.Comment),
+		mu:           &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateComment(c comment.Comment) (comment.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.HasParent {
+		if _, ok := m.commentsById[c.ParentId]; !ok {
+			return comment.Comment{}, comment.ErrNotFound
+		}
+	}
+	c.Id = m.nextId
+	m.nextId++
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	m.commentsById[c.Id] = c
+	return c, nil
+}
+
+func (m *Memory) GetCommentById(id uint) (comment.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.commentsById[id]
+	if !ok {
+		return comment.Comment{}, comment.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *Memory) GetCommentsBySnippet(sid uint) ([]comment.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var cc []comment.Comment
+	for _, c := range m.commentsById {
+		if c.Sid == sid {
+			cc = append(cc, c)
+		}
+	}
+	sort.Slice(cc, func(i, j int) bool {
+		return cc[i].Id < cc[j].Id
+	})
+	return cc, nil
+}
+
+func (m *Memory) SetCommentHidden(id uint, hidden bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.commentsById[id]
+	if !ok {
+		return comment.ErrNotFound
+	}
+	c.Hidden = hidden
+	m.commentsById[id] = c
+	return nil
+}
+
+func (m *Memory) DeleteComment(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.commentsById[id]; !ok {
+		return comment.ErrNotFound
+	}
+	m.deleteThread(id)
+	return nil
+}
+
+func (m *Memory) deleteThread(id uint) {
+	delete(m.commentsById, id)
+	for rid, c := range m.commentsById {
+		if c.HasParent && c.ParentId == id {
+			m.deleteThread(rid)
+		}
+	}
+}
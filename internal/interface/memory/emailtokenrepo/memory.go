@@ -0,0 +1,63 @@
+package emailtokenrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/emailtoken"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	tokensByHash map[string]emailtoken.Token
+	mu           *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tokensByHash: make(map[string]emailtoken.Token),
+		mu:           &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateToken(t emailtoken.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokensByHash[t.Hash] = t
+	return nil
+}
+
+func (m *Memory) ConsumeToken(hash string, purpose emailtoken.Purpose) (emailtoken.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokensByHash[hash]
+	if !ok || t.Purpose != purpose {
+		return emailtoken.Token{}, emailtoken.ErrNotFound
+	}
+	delete(m.tokensByHash, hash)
+	if !t.ExpiresAt.After(time.Now()) {
+		return emailtoken.Token{}, emailtoken.ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *Memory) DeleteTokensByUser(uid uint, purpose emailtoken.Purpose) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, t := range m.tokensByHash {
+		if t.Uid == uid && t.Purpose == purpose {
+			delete(m.tokensByHash, hash)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteExpiredTokens() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for hash, t := range m.tokensByHash {
+		if !t.ExpiresAt.After(now) {
+			delete(m.tokensByHash, hash)
+		}
+	}
+	return nil
+}
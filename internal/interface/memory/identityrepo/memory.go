@@ -0,0 +1,73 @@
+package identityrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/identity"
+	"sync"
+)
+
+type key struct {
+	provider string
+	subject  string
+}
+
+type Memory struct {
+	identities map[key]identity.Identity
+	mu         *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		identities: make(map[key]identity.Identity),
+		mu:         &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateIdentity(i identity.Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key{provider: i.Provider, subject: i.Subject}
+	if _, ok := m.identities[k]; ok {
+		return identity.ErrAlreadyExist
+	}
+	for _, other := range m.identities {
+		if other.Uid == i.Uid && other.Provider == i.Provider {
+			return identity.ErrAlreadyExist
+		}
+	}
+	m.identities[k] = i
+	return nil
+}
+
+func (m *Memory) GetIdentity(provider, subject string) (identity.Identity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.identities[key{provider: provider, subject: subject}]
+	if !ok {
+		return identity.Identity{}, identity.ErrNotFound
+	}
+	return i, nil
+}
+
+func (m *Memory) GetIdentitiesByUser(uid uint) ([]identity.Identity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ii []identity.Identity
+	for _, i := range m.identities {
+		if i.Uid == uid {
+			ii = append(ii, i)
+		}
+	}
+	return ii, nil
+}
+
+func (m *Memory) DeleteIdentity(uid uint, provider string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, i := range m.identities {
+		if i.Uid == uid && i.Provider == provider {
+			delete(m.identities, k)
+			return nil
+		}
+	}
+	return identity.ErrNotFound
+}
@@ -0,0 +1,54 @@
+package linteventrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"sync"
+)
+
+const subscriberBuffer = 8
+
+type Memory struct {
+	subscribers map[uint]map[chan lintevent.Event]struct{}
+	mu          *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		subscribers: make(map[uint]map[chan lintevent.Event]struct{}),
+		mu:          &sync.Mutex{},
+	}
+}
+
+func (m *Memory) Publish(e lintevent.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subscribers[e.Sid] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(sid uint) (<-chan lintevent.Event, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch := make(chan lintevent.Event, subscriberBuffer)
+	if m.subscribers[sid] == nil {
+		m.subscribers[sid] = make(map[chan lintevent.Event]struct{})
+	}
+	m.subscribers[sid][ch] = struct{}{}
+	once := &sync.Once{}
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.subscribers[sid], ch)
+			if len(m.subscribers[sid]) == 0 {
+				delete(m.subscribers, sid)
+			}
+			close(ch)
+		})
+	}
+}
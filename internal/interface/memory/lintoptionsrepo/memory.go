@@ -0,0 +1,34 @@
+package lintoptionsrepo
+
+import (
+	"sync"
+)
+
+type Memory struct {
+	optionsByUid map[uint]map[string]map[string]string
+	mu           *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		optionsByUid: make(map[uint]map[string]map[string]string),
+		mu:           &sync.Mutex{},
+	}
+}
+
+func (m *Memory) GetDefaultLintOptions(uid uint) (map[string]map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.optionsByUid[uid], nil
+}
+
+func (m *Memory) SetDefaultLintOptions(uid uint, opts map[string]map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(opts) == 0 {
+		delete(m.optionsByUid, uid)
+		return nil
+	}
+	m.optionsByUid[uid] = opts
+	return nil
+}
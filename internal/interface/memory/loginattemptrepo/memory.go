@@ -0,0 +1,62 @@
+package loginattemptrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/loginattempt"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	attemptsByKey map[string]loginattempt.Attempts
+	mu            *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		attemptsByKey: make(map[string]loginattempt.Attempts),
+		mu:            &sync.Mutex{},
+	}
+}
+
+func (m *Memory) GetAttempts(key string) (loginattempt.Attempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attemptsByKey[key]
+	if !ok {
+		return loginattempt.Attempts{Key: key}, nil
+	}
+	return a, nil
+}
+
+func (m *Memory) RecordFailure(key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	a, ok := m.attemptsByKey[key]
+	if !ok || now.Sub(a.UpdatedAt) > window {
+		a = loginattempt.Attempts{Key: key, LockedUntil: a.LockedUntil}
+	}
+	a.Failures++
+	a.UpdatedAt = now
+	m.attemptsByKey[key] = a
+	return a.Failures, nil
+}
+
+func (m *Memory) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.attemptsByKey[key]
+	a.Key = key
+	if until.After(a.LockedUntil) {
+		a.LockedUntil = until
+	}
+	m.attemptsByKey[key] = a
+	return nil
+}
+
+func (m *Memory) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attemptsByKey, key)
+	return nil
+}
@@ -0,0 +1,52 @@
+package oidcrequestrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/oidcrequest"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	requestsByState map[string]oidcrequest.Request
+	mu              *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		requestsByState: make(map[string]oidcrequest.Request),
+		mu:              &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateRequest(r oidcrequest.Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requestsByState[r.StateHash] = r
+	return nil
+}
+
+func (m *Memory) ConsumeRequest(stateHash string) (oidcrequest.Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.requestsByState[stateHash]
+	if !ok {
+		return oidcrequest.Request{}, oidcrequest.ErrNotFound
+	}
+	delete(m.requestsByState, stateHash)
+	if !r.ExpiresAt.After(time.Now()) {
+		return oidcrequest.Request{}, oidcrequest.ErrNotFound
+	}
+	return r, nil
+}
+
+func (m *Memory) DeleteExpiredRequests() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for state, r := range m.requestsByState {
+		if !r.ExpiresAt.After(now) {
+			delete(m.requestsByState, state)
+		}
+	}
+	return nil
+}
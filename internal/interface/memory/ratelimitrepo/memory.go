@@ -0,0 +1,66 @@
+package ratelimitrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/ratelimit"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type Memory struct {
+	buckets map[string]bucket
+	mu      *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]bucket),
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (m *Memory) Take(key string, rule ratelimit.Rule) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: rule.Capacity(), updatedAt: now}
+	}
+	b.tokens = math.Min(rule.Capacity(), b.tokens+now.Sub(b.updatedAt).Seconds()*rule.RatePerSecond())
+	b.updatedAt = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	m.buckets[key] = b
+	return allowed, rule.RetryAfter(b.tokens), nil
+}
+
+func (m *Memory) Peek(key string, rule ratelimit.Rule) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[key]
+	if !ok {
+		return true, 0, nil
+	}
+	tokens := math.Min(rule.Capacity(), b.tokens+time.Since(b.updatedAt).Seconds()*rule.RatePerSecond())
+	return tokens >= 1, rule.RetryAfter(tokens), nil
+}
+
+func (m *Memory) DeleteIdleBuckets(idle time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, b := range m.buckets {
+		if now.Sub(b.updatedAt) > idle {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
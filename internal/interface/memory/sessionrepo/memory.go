@@ -0,0 +1,113 @@
+package sessionrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/session"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	sessionsById  map[string]session.Session
+	revokedTokens map[string]time.Time
+	mu            *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		sessionsById:  make(map[string]session.Session),
+		revokedTokens: make(map[string]time.Time),
+		mu:            &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateSession(s session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionsById[s.Id] = s
+	return nil
+}
+
+func (m *Memory) GetSessionById(id string) (session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessionsById[id]
+	if !ok {
+		return session.Session{}, session.ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *Memory) GetSessionByRefreshToken(hash string) (session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessionsById {
+		if s.RefreshTokenHash == hash {
+			return s, nil
+		}
+	}
+	return session.Session{}, session.ErrNotFound
+}
+
+func (m *Memory) GetSessionsByUser(uid uint) ([]session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ss []session.Session
+	for _, s := range m.sessionsById {
+		if s.Uid == uid {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
+func (m *Memory) RotateSession(oldHash string, s session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.sessionsById[s.Id]
+	if !ok || old.RefreshTokenHash != oldHash {
+		return session.ErrNotFound
+	}
+	m.sessionsById[s.Id] = s
+	return nil
+}
+
+func (m *Memory) DeleteSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessionsById[id]; !ok {
+		return session.ErrNotFound
+	}
+	delete(m.sessionsById, id)
+	return nil
+}
+
+func (m *Memory) DeleteExpiredSessions() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, s := range m.sessionsById {
+		if s.ExpiresAt.Before(now) {
+			delete(m.sessionsById, id)
+		}
+	}
+	for jti, exp := range m.revokedTokens {
+		if exp.Before(now) {
+			delete(m.revokedTokens, jti)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (m *Memory) IsAccessTokenRevoked(jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.revokedTokens[jti]
+	return ok, nil
+}
@@ -0,0 +1,109 @@
+package starrepo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snippets tells of the snippets deleted or expired, the stars of which the
+// postgres repo drops by the cascade.
+type Snippets interface {
+	OnDelete(f func(sid uint))
+}
+
+type key struct {
+	uid uint
+	sid uint
+}
+
+type Memory struct {
+	starredAt map[key]time.Time
+	counts    map[uint]uint
+	mu        *sync.Mutex
+}
+
+func NewMemory(snippets Snippets) *Memory {
+	m := &Memory{
+		starredAt: make(map[key]time.Time),
+		counts:    make(map[uint]uint),
+		mu:        &sync.Mutex{},
+	}
+	snippets.OnDelete(m.deleteSnippet)
+	return m
+}
+
+func (m *Memory) deleteSnippet(sid uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.starredAt {
+		if k.sid == sid {
+			delete(m.starredAt, k)
+		}
+	}
+	delete(m.counts, sid)
+}
+
+func (m *Memory) Star(uid, sid uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key{uid: uid, sid: sid}
+	if _, ok := m.starredAt[k]; ok {
+		return false, nil
+	}
+	m.starredAt[k] = time.Now()
+	m.counts[sid]++
+	return true, nil
+}
+
+func (m *Memory) Unstar(uid, sid uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key{uid: uid, sid: sid}
+	if _, ok := m.starredAt[k]; !ok {
+		return false, nil
+	}
+	delete(m.starredAt, k)
+	m.counts[sid]--
+	return true, nil
+}
+
+func (m *Memory) IsStarred(uid, sid uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.starredAt[key{uid: uid, sid: sid}]
+	return ok, nil
+}
+
+func (m *Memory) GetStarCount(sid uint) (uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[sid], nil
+}
+
+func (m *Memory) GetStarredSnippetIds(uid uint, limit, offset int) ([]uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ks []key
+	for k := range m.starredAt {
+		if k.uid == uid {
+			ks = append(ks, k)
+		}
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		return m.starredAt[ks[i]].After(m.starredAt[ks[j]]) ||
+			(m.starredAt[ks[i]].Equal(m.starredAt[ks[j]]) && ks[i].sid > ks[j].sid)
+	})
+	var ids []uint
+	for _, k := range ks {
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, k.sid)
+	}
+	return ids, nil
+}
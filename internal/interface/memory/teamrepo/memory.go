@@ -0,0 +1,283 @@
+package teamrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"sort"
+	"sync"
+	"time"
+)
+
+type TeamSnippets interface {
+	DeleteSnippetsByTeam(tid uint) error
+}
+
+type memberKey struct {
+	tid uint
+	uid uint
+}
+
+type Memory struct {
+	teamsById        map[uint]team.Team
+	members          map[memberKey]team.Membership
+	invitationsById  map[uint]team.Invitation
+	snippets         TeamSnippets
+	nextId           uint
+	nextInvitationId uint
+	mu               *sync.Mutex
+}
+
+func NewMemory(snippets TeamSnippets) *Memory {
+	return &Memory{
+		teamsById:       make(map[uint]team.Team),
+		members:         make(map[memberKey]team.Membership),
+		invitationsById: make(map[uint]team.Invitation),
+		snippets:        snippets,
+		mu:              &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateTeam(name string, uid uint) (team.Team, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.teamsById {
+		if t.Name == name {
+			return team.Team{}, team.ErrAlreadyExist
+		}
+	}
+	t := team.Team{
+		Id:        m.nextId,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	m.nextId++
+	m.teamsById[t.Id] = t
+	m.members[memberKey{tid: t.Id, uid: uid}] = team.Membership{
+		Tid:      t.Id,
+		Uid:      uid,
+		Role:     team.Owner,
+		JoinedAt: t.CreatedAt,
+	}
+	return t, nil
+}
+
+func (m *Memory) GetTeamById(tid uint) (team.Team, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.teamsById[tid]
+	if !ok {
+		return team.Team{}, team.ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *Memory) GetTeamsByUser(uid uint) ([]team.Team, []team.Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tt []team.Team
+	var mm []team.Membership
+	for k, ms := range m.members {
+		if k.uid == uid {
+			mm = append(mm, ms)
+		}
+	}
+	sort.Slice(mm, func(i, j int) bool {
+		return m.teamsById[mm[i].Tid].Name < m.teamsById[mm[j].Tid].Name
+	})
+	for _, ms := range mm {
+		tt = append(tt, m.teamsById[ms.Tid])
+	}
+	return tt, mm, nil
+}
+
+func (m *Memory) DeleteTeam(tid uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.teamsById[tid]; !ok {
+		return team.ErrNotFound
+	}
+	if err := m.snippets.DeleteSnippetsByTeam(tid); err != nil {
+		return err
+	}
+	delete(m.teamsById, tid)
+	for k := range m.members {
+		if k.tid == tid {
+			delete(m.members, k)
+		}
+	}
+	for id, i := range m.invitationsById {
+		if i.Tid == tid {
+			delete(m.invitationsById, id)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetMembership(tid, uid uint) (team.Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms, ok := m.members[memberKey{tid: tid, uid: uid}]
+	if !ok {
+		return team.Membership{}, team.ErrMemberNotFound
+	}
+	return ms, nil
+}
+
+func (m *Memory) GetMembers(tid uint) ([]team.Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var mm []team.Membership
+	for k, ms := range m.members {
+		if k.tid == tid {
+			mm = append(mm, ms)
+		}
+	}
+	sort.Slice(mm, func(i, j int) bool {
+		return mm[i].JoinedAt.Before(mm[j].JoinedAt) ||
+			(mm[i].JoinedAt.Equal(mm[j].JoinedAt) && mm[i].Uid < mm[j].Uid)
+	})
+	return mm, nil
+}
+
+func (m *Memory) SetMemberRole(tid, uid uint, role team.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memberKey{tid: tid, uid: uid}
+	ms, ok := m.members[k]
+	if !ok {
+		return team.ErrMemberNotFound
+	}
+	if role != team.Owner && m.lastOwner(ms) {
+		return team.ErrLastOwner
+	}
+	ms.Role = role
+	m.members[k] = ms
+	return nil
+}
+
+func (m *Memory) DeleteMember(tid, uid uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memberKey{tid: tid, uid: uid}
+	ms, ok := m.members[k]
+	if !ok {
+		return team.ErrMemberNotFound
+	}
+	if m.lastOwner(ms) {
+		return team.ErrLastOwner
+	}
+	delete(m.members, k)
+	return nil
+}
+
+// DeleteMembershipsByUser removes the user from every team and drops the
+// invitations to them, unless they are the last owner of a team.
+func (m *Memory) DeleteMembershipsByUser(uid uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ms := range m.members {
+		if ms.Uid == uid && m.lastOwner(ms) {
+			return team.ErrLastOwner
+		}
+	}
+	for k := range m.members {
+		if k.uid == uid {
+			delete(m.members, k)
+		}
+	}
+	for id, i := range m.invitationsById {
+		if i.Uid == uid {
+			delete(m.invitationsById, id)
+		}
+	}
+	return nil
+}
+
+// lastOwner is called with m.mu held.
+func (m *Memory) lastOwner(ms team.Membership) bool {
+	if ms.Role != team.Owner {
+		return false
+	}
+	for _, other := range m.members {
+		if other.Tid == ms.Tid && other.Uid != ms.Uid && other.Role == team.Owner {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Memory) CreateInvitation(i team.Invitation) (team.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.teamsById[i.Tid]; !ok {
+		return team.Invitation{}, team.ErrNotFound
+	}
+	if _, ok := m.members[memberKey{tid: i.Tid, uid: i.Uid}]; ok {
+		return team.Invitation{}, team.ErrMemberAlreadyExist
+	}
+	for id, other := range m.invitationsById {
+		if other.Tid == i.Tid && other.Uid == i.Uid {
+			delete(m.invitationsById, id)
+		}
+	}
+	i.Id = m.nextInvitationId
+	m.nextInvitationId++
+	m.invitationsById[i.Id] = i
+	return i, nil
+}
+
+func (m *Memory) GetInvitationById(id uint) (team.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.invitationsById[id]
+	if !ok {
+		return team.Invitation{}, team.ErrInvitationNotFound
+	}
+	return i, nil
+}
+
+func (m *Memory) GetInvitationsByUser(uid uint) ([]team.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ii []team.Invitation
+	for _, i := range m.invitationsById {
+		if i.Uid == uid {
+			ii = append(ii, i)
+		}
+	}
+	sort.Slice(ii, func(a, b int) bool {
+		return ii[a].Id < ii[b].Id
+	})
+	return ii, nil
+}
+
+func (m *Memory) AcceptInvitation(id uint) (team.Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.invitationsById[id]
+	if !ok {
+		return team.Membership{}, team.ErrInvitationNotFound
+	}
+	delete(m.invitationsById, id)
+	k := memberKey{tid: i.Tid, uid: i.Uid}
+	if _, ok := m.members[k]; ok {
+		return team.Membership{}, team.ErrMemberAlreadyExist
+	}
+	ms := team.Membership{
+		Tid:      i.Tid,
+		Uid:      i.Uid,
+		Role:     i.Role,
+		JoinedAt: time.Now(),
+	}
+	m.members[k] = ms
+	return ms, nil
+}
+
+func (m *Memory) DeleteInvitation(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.invitationsById[id]; !ok {
+		return team.ErrInvitationNotFound
+	}
+	delete(m.invitationsById, id)
+	return nil
+}
@@ -0,0 +1,138 @@
+package webhookrepo
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	webhooksById   map[uint]webhook.Webhook
+	deliveriesById map[uint]webhook.Delivery
+	nextId         uint
+	nextDeliveryId uint
+	mu             *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		webhooksById:   make(map[uint]webhook.Webhook),
+		deliveriesById: make(map[uint]webhook.Delivery),
+		mu:             &sync.Mutex{},
+	}
+}
+
+func (m *Memory) CreateWebhook(w webhook.Webhook) (webhook.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Id = m.nextId
+	m.nextId++
+	m.webhooksById[w.Id] = w
+	return w, nil
+}
+
+func (m *Memory) GetWebhookById(id uint) (webhook.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.webhooksById[id]
+	if !ok {
+		return webhook.Webhook{}, webhook.ErrNotFound
+	}
+	return w, nil
+}
+
+func (m *Memory) GetWebhooksByUser(uid uint) ([]webhook.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ww []webhook.Webhook
+	for _, w := range m.webhooksById {
+		if w.Uid == uid {
+			ww = append(ww, w)
+		}
+	}
+	sort.Slice(ww, func(i, j int) bool {
+		return ww[i].Id < ww[j].Id
+	})
+	return ww, nil
+}
+
+func (m *Memory) DeleteWebhook(uid, id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.webhooksById[id]
+	if !ok || w.Uid != uid {
+		return webhook.ErrNotFound
+	}
+	delete(m.webhooksById, id)
+	for did, d := range m.deliveriesById {
+		if d.WebhookId == id {
+			delete(m.deliveriesById, did)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CreateDelivery(d webhook.Delivery) (webhook.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooksById[d.WebhookId]; !ok {
+		return webhook.Delivery{}, webhook.ErrNotFound
+	}
+	d.Id = m.nextDeliveryId
+	m.nextDeliveryId++
+	m.deliveriesById[d.Id] = d
+	return d, nil
+}
+
+func (m *Memory) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dd []webhook.Delivery
+	for _, d := range m.deliveriesById {
+		if d.State == webhook.Pending && !d.NextAttemptAt.After(now) {
+			dd = append(dd, d)
+		}
+	}
+	sort.Slice(dd, func(i, j int) bool {
+		return dd[i].NextAttemptAt.Before(dd[j].NextAttemptAt) ||
+			(dd[i].NextAttemptAt.Equal(dd[j].NextAttemptAt) && dd[i].Id < dd[j].Id)
+	})
+	if len(dd) > limit {
+		dd = dd[:limit]
+	}
+	for _, d := range dd {
+		claimed := m.deliveriesById[d.Id]
+		claimed.NextAttemptAt = now.Add(lease)
+		m.deliveriesById[d.Id] = claimed
+	}
+	return dd, nil
+}
+
+func (m *Memory) UpdateDelivery(d webhook.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deliveriesById[d.Id]; !ok {
+		return webhook.ErrDeliveryNotFound
+	}
+	m.deliveriesById[d.Id] = d
+	return nil
+}
+
+func (m *Memory) GetDeliveriesByWebhook(id uint, limit int) ([]webhook.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dd []webhook.Delivery
+	for _, d := range m.deliveriesById {
+		if d.WebhookId == id {
+			dd = append(dd, d)
+		}
+	}
+	sort.Slice(dd, func(i, j int) bool {
+		return dd[i].Id > dd[j].Id
+	})
+	if len(dd) > limit {
+		dd = dd[:limit]
+	}
+	return dd, nil
+}
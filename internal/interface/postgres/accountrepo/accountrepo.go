@@ -2,9 +2,12 @@ package accountrepo
 
 import (
 	"database/sql"
+	"github.com/lib/pq"
 	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
 )
 
+const uniqueViolation = "23505"
+
 type Postgres struct {
 	conn *sql.DB
 }
@@ -16,15 +19,16 @@ func New(conn *sql.DB) *Postgres {
 const queryCreateAccount = `
 	INSERT INTO accounts(
 		login,
+		canonicalLogin,
 		password
-	) VALUES ($1, $2)
+	) VALUES ($1, $2, $3)
 	ON CONFLICT DO NOTHING
 	RETURNING id
 `
 
 func (p *Postgres) CreateAccount(cred account.Credentials) (account.Account, error) {
 	a := account.Account{Credentials : cred}
-	row := p.conn.QueryRow(queryCreateAccount, cred.Login, cred.Password)
+	row := p.conn.QueryRow(queryCreateAccount, cred.Login, cred.CanonicalLogin, cred.Password)
 	err := row.Scan(&a.Id)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -35,19 +39,22 @@ func (p *Postgres) CreateAccount(cred account.Credentials) (account.Account, err
 	return a, nil
 }
 
-const queryGetAccountById = `
-	SELECT
+const accountColumns = `
 		id,
 		login,
-		password
-	FROM accounts 
-	WHERE id = $1
+		canonicalLogin,
+		password,
+		coalesce(email, ''),
+		emailVerified,
+		coalesce(totpSecret, ''),
+		totpEnabled,
+		totpLastStep
 `
 
-func (p *Postgres) GetAccountById(id uint) (account.Account, error) {
+func scanAccount(row *sql.Row) (account.Account, error) {
 	a := account.Account{}
-	row := p.conn.QueryRow(queryGetAccountById, id)
-	err := row.Scan(&a.Id, &a.Login, &a.Password)
+	err := row.Scan(&a.Id, &a.Login, &a.CanonicalLogin, &a.Password, &a.Email, &a.EmailVerified,
+		&a.TotpSecret, &a.TotpEnabled, &a.TotpLastStep)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return account.Account{}, account.ErrNotFound
@@ -57,24 +64,281 @@ func (p *Postgres) GetAccountById(id uint) (account.Account, error) {
 	return a, nil
 }
 
+const queryGetAccountById = `
+	SELECT` + accountColumns + `
+	FROM accounts
+	WHERE id = $1
+`
+
+func (p *Postgres) GetAccountById(id uint) (account.Account, error) {
+	return scanAccount(p.conn.QueryRow(queryGetAccountById, id))
+}
+
 const queryGetAccountByLogin = `
-	SELECT
-		id,
-		login,
-		password
-	FROM accounts 
-	WHERE login = $1
+	SELECT` + accountColumns + `
+	FROM accounts
+	WHERE canonicalLogin = $1
 `
 
-func (p *Postgres) GetAccountByLogin(login string) (account.Account, error) {
-	a := account.Account{}
-	row := p.conn.QueryRow(queryGetAccountByLogin, login)
-	err := row.Scan(&a.Id, &a.Login, &a.Password)
+func (p *Postgres) GetAccountByLogin(canonicalLogin string) (account.Account, error) {
+	return scanAccount(p.conn.QueryRow(queryGetAccountByLogin, canonicalLogin))
+}
+
+const queryUpdatePassword = `
+	UPDATE accounts
+	SET password = $2,
+	    updatedAt = now()
+	WHERE id = $1
+`
+
+func (p *Postgres) UpdatePassword(id uint, password string) error {
+	res, err := p.conn.Exec(queryUpdatePassword, id, password)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return account.Account{}, account.ErrNotFound
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return nil
+}
+
+const querySetEmail = `
+	UPDATE accounts
+	SET email = $2,
+	    emailVerified = false,
+	    updatedAt = now()
+	WHERE id = $1
+`
+
+func (p *Postgres) SetEmail(id uint, email string) error {
+	res, err := p.conn.Exec(querySetEmail, id, email)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return nil
+}
+
+const queryVerifyEmail = `
+	UPDATE accounts
+	SET emailVerified = true,
+	    updatedAt = now()
+	WHERE id = $1 AND email = $2
+`
+
+func (p *Postgres) VerifyEmail(id uint, email string) error {
+	res, err := p.conn.Exec(queryVerifyEmail, id, email)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == uniqueViolation {
+			return account.ErrAlreadyExist
 		}
-		return account.Account{}, err
+		return err
 	}
-	return a, nil
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return nil
+}
+
+const queryGetAccountByVerifiedEmail = `
+	SELECT` + accountColumns + `
+	FROM accounts
+	WHERE email = $1 AND emailVerified
+`
+
+func (p *Postgres) GetAccountByVerifiedEmail(email string) (account.Account, error) {
+	return scanAccount(p.conn.QueryRow(queryGetAccountByVerifiedEmail, email))
+}
+
+const querySetTotp = `
+	UPDATE accounts
+	SET totpSecret = nullif($2, ''),
+	    totpEnabled = $3,
+	    updatedAt = now()
+	WHERE id = $1
+`
+
+func (p *Postgres) SetTotp(id uint, secret string, enabled bool) error {
+	res, err := p.conn.Exec(querySetTotp, id, secret, enabled)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return nil
+}
+
+const queryUseTotpStep = `
+	UPDATE accounts
+	SET totpLastStep = $2
+	WHERE id = $1 AND totpLastStep < $2
+`
+
+func (p *Postgres) UseTotpStep(id uint, step int64) error {
+	res, err := p.conn.Exec(queryUseTotpStep, id, step)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrTotpStepUsed
+	}
+	return nil
+}
+
+const queryDeleteRecoveryCodes = `
+	DELETE FROM recovery_codes
+	WHERE uid = $1
+`
+
+const queryCreateRecoveryCode = `
+	INSERT INTO recovery_codes(
+		uid,
+		hash
+	) VALUES ($1, $2)
+`
+
+func (p *Postgres) SetRecoveryCodes(id uint, hashes []string) error {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(queryDeleteRecoveryCodes, id); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(queryCreateRecoveryCode, id, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const queryUseRecoveryCode = `
+	DELETE FROM recovery_codes
+	WHERE uid = $1 AND hash = $2
+`
+
+func (p *Postgres) UseRecoveryCode(id uint, hash string) error {
+	res, err := p.conn.Exec(queryUseRecoveryCode, id, hash)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return nil
+}
+
+const queryDeleteAccountSnippets = `
+	DELETE FROM snippets
+	WHERE uid = $1
+`
+
+const queryAnonymiseAccountSnippets = `
+	UPDATE snippets
+	SET uid = NULL
+	WHERE uid = $1
+`
+
+const queryDeleteAccount = `
+	DELETE FROM accounts
+	WHERE id = $1
+`
+
+// queryLockOwnersOfTeams locks the owners of the teams the account owns, so
+// that none of the others steps down while the account goes.
+const queryLockOwnersOfTeams = `
+	SELECT tid, uid
+	FROM team_members
+	WHERE role = 'owner' AND tid IN (
+		SELECT tid
+		FROM team_members
+		WHERE uid = $1 AND role = 'owner'
+	)
+	FOR UPDATE
+`
+
+func checkNotSoleTeamOwner(tx *sql.Tx, id uint) error {
+	rows, err := tx.Query(queryLockOwnersOfTeams, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	owners := make(map[uint]int)
+	for rows.Next() {
+		var tid, uid uint
+		if err := rows.Scan(&tid, &uid); err != nil {
+			return err
+		}
+		owners[tid]++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, n := range owners {
+		if n < 2 {
+			return account.ErrSoleTeamOwner
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteAccount(id uint, snippets account.SnippetPolicy) error {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotSoleTeamOwner(tx, id); err != nil {
+		return err
+	}
+	querySnippets := queryDeleteAccountSnippets
+	if snippets == account.AnonymiseSnippets {
+		querySnippets = queryAnonymiseAccountSnippets
+	}
+	if _, err := tx.Exec(querySnippets, id); err != nil {
+		return err
+	}
+	res, err := tx.Exec(queryDeleteAccount, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return account.ErrNotFound
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,146 @@
+package apitokenrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/apitoken"
+	"strings"
+	"time"
+)
+
+const scopeSeparator = " "
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateApiToken = `
+	INSERT INTO api_tokens(
+		uid,
+		name,
+		hash,
+		scopes,
+		createdAt,
+		lastUsedAt
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+`
+
+func (p *Postgres) CreateApiToken(t apitoken.ApiToken) (apitoken.ApiToken, error) {
+	row := p.conn.QueryRow(queryCreateApiToken, t.Uid, t.Name, t.Hash,
+		strings.Join(t.Scopes, scopeSeparator), t.CreatedAt, t.LastUsedAt)
+	if err := row.Scan(&t.Id); err != nil {
+		return apitoken.ApiToken{}, err
+	}
+	return t, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApiToken(row scanner) (apitoken.ApiToken, error) {
+	t := apitoken.ApiToken{}
+	var scopes string
+	err := row.Scan(&t.Id, &t.Uid, &t.Name, &t.Hash, &scopes, &t.CreatedAt, &t.LastUsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return apitoken.ApiToken{}, apitoken.ErrNotFound
+		}
+		return apitoken.ApiToken{}, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	return t, nil
+}
+
+const queryGetApiTokenByHash = `
+	SELECT
+		id,
+		uid,
+		name,
+		hash,
+		scopes,
+		createdAt,
+		lastUsedAt
+	FROM api_tokens
+	WHERE hash = $1
+`
+
+func (p *Postgres) GetApiTokenByHash(hash string) (apitoken.ApiToken, error) {
+	return scanApiToken(p.conn.QueryRow(queryGetApiTokenByHash, hash))
+}
+
+const queryGetApiTokensByUser = `
+	SELECT
+		id,
+		uid,
+		name,
+		hash,
+		scopes,
+		createdAt,
+		lastUsedAt
+	FROM api_tokens
+	WHERE uid = $1
+	ORDER BY id
+`
+
+func (p *Postgres) GetApiTokensByUser(uid uint) ([]apitoken.ApiToken, error) {
+	rows, err := p.conn.Query(queryGetApiTokensByUser, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ts []apitoken.ApiToken
+	for rows.Next() {
+		t, err := scanApiToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, rows.Err()
+}
+
+const queryTouchApiToken = `
+	UPDATE api_tokens
+	SET lastUsedAt = $2
+	WHERE id = $1
+`
+
+func (p *Postgres) TouchApiToken(id uint, lastUsedAt time.Time) error {
+	_, err := p.conn.Exec(queryTouchApiToken, id, lastUsedAt)
+	return err
+}
+
+const queryDeleteApiToken = `
+	DELETE FROM api_tokens
+	WHERE uid = $1 AND id = $2
+`
+
+func (p *Postgres) DeleteApiToken(uid, id uint) error {
+	res, err := p.conn.Exec(queryDeleteApiToken, uid, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+const queryDeleteApiTokensByUser = `
+	DELETE FROM api_tokens
+	WHERE uid = $1
+`
+
+func (p *Postgres) DeleteApiTokensByUser(uid uint) error {
+	_, err := p.conn.Exec(queryDeleteApiTokensByUser, uid)
+	return err
+}
@@ -0,0 +1,102 @@
+package challengerepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/challenge"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateChallenge = `
+	INSERT INTO signin_challenges(
+		hash,
+		uid,
+		device,
+		failures,
+		expiresAt
+	) VALUES ($1, $2, $3, $4, $5)
+`
+
+func (p *Postgres) CreateChallenge(c challenge.Challenge) error {
+	_, err := p.conn.Exec(queryCreateChallenge, c.Hash, c.Uid, c.Device, c.Failures, c.ExpiresAt)
+	return err
+}
+
+const queryGetChallenge = `
+	SELECT
+		hash,
+		uid,
+		device,
+		failures,
+		expiresAt
+	FROM signin_challenges
+	WHERE hash = $1 AND expiresAt > now()
+`
+
+func (p *Postgres) GetChallenge(hash string) (challenge.Challenge, error) {
+	c := challenge.Challenge{}
+	row := p.conn.QueryRow(queryGetChallenge, hash)
+	err := row.Scan(&c.Hash, &c.Uid, &c.Device, &c.Failures, &c.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return challenge.Challenge{}, challenge.ErrNotFound
+		}
+		return challenge.Challenge{}, err
+	}
+	return c, nil
+}
+
+const queryRecordFailure = `
+	UPDATE signin_challenges
+	SET failures = failures + 1
+	WHERE hash = $1
+	RETURNING failures
+`
+
+func (p *Postgres) RecordFailure(hash string) (int, error) {
+	var failures int
+	err := p.conn.QueryRow(queryRecordFailure, hash).Scan(&failures)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, challenge.ErrNotFound
+		}
+		return 0, err
+	}
+	return failures, nil
+}
+
+const queryDeleteChallenge = `
+	DELETE FROM signin_challenges
+	WHERE hash = $1
+`
+
+func (p *Postgres) DeleteChallenge(hash string) error {
+	res, err := p.conn.Exec(queryDeleteChallenge, hash)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return challenge.ErrNotFound
+	}
+	return nil
+}
+
+const queryDeleteExpiredChallenges = `
+	DELETE FROM signin_challenges
+	WHERE expiresAt <= now()
+`
+
+func (p *Postgres) DeleteExpiredChallenges() error {
+	_, err := p.conn.Exec(queryDeleteExpiredChallenges)
+	return err
+}
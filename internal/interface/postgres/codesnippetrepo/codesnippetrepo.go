@@ -2,8 +2,10 @@ package codesnippetrepo
 
 import (
 	"database/sql"
+	"encoding/json"
 	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
 	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"time"
 )
 
 type Postgres struct {
@@ -19,28 +21,61 @@ const queryCreateSnippet = `
 		code,
 		language,                 
 		lifetime,
-	    isChecked,
-	    message
-	) VALUES ($1, $2, $3, $4, $5)
+	    lintStatus,
+	    message,
+	    lintOptions
+	) VALUES ($1, $2, $3, $4, $5, $6)
 	RETURNING id
 `
 
-const querySetCodeLinterMessage = `
+const querySetLint = `
 	UPDATE snippets
-	SET isChecked = $2,
-	    message = $3
+	SET lintStatus = $2,
+	    linter = $3,
+	    linterVersion = $4,
+	    lintStartedAt = $5,
+	    lintFinishedAt = $6,
+	    lintError = $7,
+	    message = $8
 	WHERE snippets.id = $1
 `
 
-func (p *Postgres) SetCodeLinterMessage(sid uint, msg string) error {
-	_, err := p.conn.Query(querySetCodeLinterMessage, sid, true, msg)
-	return err
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (p *Postgres) SetLint(sid uint, l codesnippet.Lint, msg string) error {
+	res, err := p.conn.Exec(querySetLint, sid, l.Status, l.Linter, l.LinterVersion,
+		nullTime(l.StartedAt), nullTime(l.FinishedAt), l.Error, msg)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	return nil
+}
+
+func encodeLintOptions(opts map[string]map[string]string) (string, error) {
+	if opts == nil {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(opts)
+	return string(raw), err
 }
 
 func (p *Postgres) CreateCodeSnippet(s codesnippet.CodeSnippet) (uint, error) {
-	row := p.conn.QueryRow(queryCreateSnippet, s.Code, s.Lang, s.Lifetime, s.IsChecked, s.Message)
+	opts, err := encodeLintOptions(s.LintOptions)
+	if err != nil {
+		return 0, err
+	}
+	row := p.conn.QueryRow(queryCreateSnippet, s.Code, s.Lang, s.Lifetime, s.Lint.Status, s.Message, opts)
 	var id uint
-	err := row.Scan(&id)
+	err = row.Scan(&id)
 	if err != nil {
 		return 0, err
 	}
@@ -53,16 +88,49 @@ const queryCreateSnippetWithUser = `
 		uid,
 		language,
 		lifetime,
-		isChecked,
-	    message
-	) VALUES ($1, $2, $3, $4, $5, $6)
+		lintStatus,
+	    message,
+	    lintOptions
+	) VALUES ($1, $2, $3, $4, $5, $6, $7)
 	RETURNING id
 `
 
 func (p *Postgres) CreateCodeSnippetWithUser(s codesnippet.CodeSnippet, uid uint) (uint, error) {
-	row := p.conn.QueryRow(queryCreateSnippetWithUser, s.Code, uid, s.Lang, s.Lifetime, s.IsChecked, s.Message)
+	opts, err := encodeLintOptions(s.LintOptions)
+	if err != nil {
+		return 0, err
+	}
+	row := p.conn.QueryRow(queryCreateSnippetWithUser, s.Code, uid, s.Lang, s.Lifetime, s.Lint.Status, s.Message, opts)
 	var id uint
-	err := row.Scan(&id)
+	err = row.Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const queryCreateSnippetWithTeam = `
+	INSERT INTO snippets(
+		code,
+		uid,
+		tid,
+		language,
+		lifetime,
+		lintStatus,
+		message,
+		lintOptions
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id
+`
+
+func (p *Postgres) CreateCodeSnippetWithTeam(s codesnippet.CodeSnippet, uid, tid uint) (uint, error) {
+	opts, err := encodeLintOptions(s.LintOptions)
+	if err != nil {
+		return 0, err
+	}
+	row := p.conn.QueryRow(queryCreateSnippetWithTeam, s.Code, uid, tid, s.Lang, s.Lifetime, s.Lint.Status, s.Message, opts)
+	var id uint
+	err = row.Scan(&id)
 	if err != nil {
 		return 0, err
 	}
@@ -73,22 +141,42 @@ const queryGetCodeSnippetById = `
 	SELECT
 		code,
 		language,
-	    isChecked,
-	    message
+	    lintStatus,
+	    linter,
+	    linterVersion,
+	    lintStartedAt,
+	    lintFinishedAt,
+	    lintError,
+	    message,
+	    lintOptions,
+	    uid,
+	    tid,
+	    createdAt::timestamptz
 	FROM snippets
 	WHERE id = $1
 `
 
 func (p *Postgres) GetCodeSnippetById(sid uint) (codesnippet.CodeSnippet, error) {
 	cs := codesnippet.CodeSnippet{}
+	var uid, tid sql.NullInt64
+	var startedAt, finishedAt, createdAt sql.NullTime
+	var opts string
 	row := p.conn.QueryRow(queryGetCodeSnippetById, sid)
-	err := row.Scan(&cs.Code, &cs.Lang, &cs.IsChecked, &cs.Message)
+	err := row.Scan(&cs.Code, &cs.Lang, &cs.Lint.Status, &cs.Lint.Linter, &cs.Lint.LinterVersion,
+		&startedAt, &finishedAt, &cs.Lint.Error, &cs.Message, &opts, &uid, &tid, &createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return codesnippet.CodeSnippet{}, codesnippetrepo.ErrInvalidSnippedId
 		}
 		return codesnippet.CodeSnippet{}, err
 	}
+	if err := json.Unmarshal([]byte(opts), &cs.LintOptions); err != nil {
+		return codesnippet.CodeSnippet{}, err
+	}
+	cs.Lint.StartedAt, cs.Lint.FinishedAt = startedAt.Time, finishedAt.Time
+	cs.Uid, cs.HasUser = uint(uid.Int64), uid.Valid
+	cs.Tid, cs.HasTeam = uint(tid.Int64), tid.Valid
+	cs.CreatedAt = createdAt.Time
 	return cs, nil
 }
 
@@ -96,11 +184,13 @@ const queryGetMyCodeSnippetIds = `
 	SELECT id
 	FROM snippets
 	WHERE uid = $1
+	ORDER BY id
+	LIMIT $2 OFFSET $3
 `
 
-func (p *Postgres) GetMyCodeSnippetIds(uid uint) ([]uint, error) {
+func (p *Postgres) GetMyCodeSnippetIds(uid uint, limit, offset int) ([]uint, error) {
 	var ids []uint
-	row, err := p.conn.Query(queryGetMyCodeSnippetIds, uid)
+	row, err := p.conn.Query(queryGetMyCodeSnippetIds, uid, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -114,12 +204,121 @@ func (p *Postgres) GetMyCodeSnippetIds(uid uint) ([]uint, error) {
 	return ids, nil
 }
 
+const queryGetTeamCodeSnippetIds = `
+	SELECT id
+	FROM snippets
+	WHERE tid = $1
+`
+
+func (p *Postgres) GetTeamCodeSnippetIds(tid uint) ([]uint, error) {
+	var ids []uint
+	rows, err := p.conn.Query(queryGetTeamCodeSnippetIds, tid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const queryDeleteTeamCodeSnippet = `
+	DELETE FROM snippets
+	WHERE id = $1 AND tid = $2
+`
+
+func (p *Postgres) DeleteTeamCodeSnippet(sid uint, tid uint) error {
+	res, err := p.conn.Exec(queryDeleteTeamCodeSnippet, sid, tid)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	return nil
+}
+
+const queryDeleteCodeSnippet = `
+	DELETE FROM snippets
+	WHERE id = $1 AND uid = $2
+`
+
+func (p *Postgres) DeleteCodeSnippet(sid uint, uid uint) error {
+	res, err := p.conn.Exec(queryDeleteCodeSnippet, sid, uid)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return codesnippetrepo.ErrInvalidSnippedId
+	}
+	return nil
+}
+
 const queryDeleteExpiredSnippets = `
 	DELETE FROM snippets
 	WHERE createdAt < now() - lifetime
+	RETURNING id, uid, tid
 `
 
-func (p *Postgres) DeleteExpiredSnippets() error {
-	_, err := p.conn.Query(queryDeleteExpiredSnippets)
-	return err
+func (p *Postgres) DeleteExpiredSnippets() ([]codesnippet.ExpiredSnippet, error) {
+	rows, err := p.conn.Query(queryDeleteExpiredSnippets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var expired []codesnippet.ExpiredSnippet
+	for rows.Next() {
+		var uid, tid sql.NullInt64
+		e := codesnippet.ExpiredSnippet{}
+		if err := rows.Scan(&e.Sid, &uid, &tid); err != nil {
+			return nil, err
+		}
+		e.Uid, e.HasUser = uint(uid.Int64), uid.Valid
+		e.Tid, e.HasTeam = uint(tid.Int64), tid.Valid
+		expired = append(expired, e)
+	}
+	return expired, rows.Err()
+}
+
+const queryGetStaleLintSnippetIds = `
+	SELECT id
+	FROM snippets
+	WHERE createdAt + lifetime > now()
+	  AND lower(coalesce(language, '')) = lower($1)
+	  AND CASE
+	      WHEN lintStatus IN ('pending', 'running')
+	          THEN coalesce(lintStartedAt, createdAt) < $4
+	      ELSE linter <> $2 OR linterVersion <> $3
+	  END
+	ORDER BY id
+`
+
+func (p *Postgres) GetStaleLintSnippetIds(lang string, linter string, version string, stuckBefore time.Time) ([]uint, error) {
+	rows, err := p.conn.Query(queryGetStaleLintSnippetIds, lang, linter, version, stuckBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
 }
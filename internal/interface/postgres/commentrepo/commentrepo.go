@@ -0,0 +1,145 @@
+package commentrepo
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+)
+
+const foreignKeyViolation = "23503"
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateComment = `
+	INSERT INTO snippet_comments(
+		sid,
+		uid,
+		parentId,
+		startLine,
+		endLine,
+		body
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, createdAt
+`
+
+func (p *Postgres) CreateComment(c comment.Comment) (comment.Comment, error) {
+	var parentId sql.NullInt64
+	if c.HasParent {
+		parentId = sql.NullInt64{Int64: int64(c.ParentId), Valid: true}
+	}
+	row := p.conn.QueryRow(queryCreateComment, c.Sid, c.Uid, parentId, c.StartLine, c.EndLine, c.Body)
+	err := row.Scan(&c.Id, &c.CreatedAt)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == foreignKeyViolation {
+			return comment.Comment{}, comment.ErrNotFound
+		}
+		return comment.Comment{}, err
+	}
+	return c, nil
+}
+
+const commentColumns = `
+		id,
+		sid,
+		uid,
+		parentId,
+		startLine,
+		endLine,
+		body,
+		hidden,
+		createdAt
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComment(s scanner) (comment.Comment, error) {
+	c := comment.Comment{}
+	var parentId sql.NullInt64
+	err := s.Scan(&c.Id, &c.Sid, &c.Uid, &parentId, &c.StartLine, &c.EndLine, &c.Body, &c.Hidden, &c.CreatedAt)
+	c.ParentId, c.HasParent = uint(parentId.Int64), parentId.Valid
+	return c, err
+}
+
+const queryGetCommentById = `
+	SELECT` + commentColumns + `
+	FROM snippet_comments
+	WHERE id = $1
+`
+
+func (p *Postgres) GetCommentById(id uint) (comment.Comment, error) {
+	c, err := scanComment(p.conn.QueryRow(queryGetCommentById, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return comment.Comment{}, comment.ErrNotFound
+		}
+		return comment.Comment{}, err
+	}
+	return c, nil
+}
+
+const queryGetCommentsBySnippet = `
+	SELECT` + commentColumns + `
+	FROM snippet_comments
+	WHERE sid = $1
+	ORDER BY id
+`
+
+func (p *Postgres) GetCommentsBySnippet(sid uint) ([]comment.Comment, error) {
+	rows, err := p.conn.Query(queryGetCommentsBySnippet, sid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cc []comment.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		cc = append(cc, c)
+	}
+	return cc, rows.Err()
+}
+
+const querySetCommentHidden = `
+	UPDATE snippet_comments
+	SET hidden = $2
+	WHERE id = $1
+`
+
+func (p *Postgres) SetCommentHidden(id uint, hidden bool) error {
+	return p.exec(querySetCommentHidden, id, hidden)
+}
+
+// replies are removed by the cascade
+const queryDeleteComment = `
+	DELETE FROM snippet_comments
+	WHERE id = $1
+`
+
+func (p *Postgres) DeleteComment(id uint) error {
+	return p.exec(queryDeleteComment, id)
+}
+
+func (p *Postgres) exec(query string, args ...interface{}) error {
+	res, err := p.conn.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return comment.ErrNotFound
+	}
+	return nil
+}
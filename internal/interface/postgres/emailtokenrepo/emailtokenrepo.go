@@ -0,0 +1,77 @@
+package emailtokenrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/emailtoken"
+	"time"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateToken = `
+	INSERT INTO email_tokens(
+		hash,
+		uid,
+		purpose,
+		email,
+		expiresAt
+	) VALUES ($1, $2, $3, $4, $5)
+`
+
+func (p *Postgres) CreateToken(t emailtoken.Token) error {
+	_, err := p.conn.Exec(queryCreateToken, t.Hash, t.Uid, t.Purpose, t.Email, t.ExpiresAt)
+	return err
+}
+
+const queryConsumeToken = `
+	DELETE FROM email_tokens
+	WHERE hash = $1 AND purpose = $2
+	RETURNING
+		hash,
+		uid,
+		purpose,
+		email,
+		expiresAt
+`
+
+func (p *Postgres) ConsumeToken(hash string, purpose emailtoken.Purpose) (emailtoken.Token, error) {
+	t := emailtoken.Token{}
+	row := p.conn.QueryRow(queryConsumeToken, hash, purpose)
+	err := row.Scan(&t.Hash, &t.Uid, &t.Purpose, &t.Email, &t.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return emailtoken.Token{}, emailtoken.ErrNotFound
+		}
+		return emailtoken.Token{}, err
+	}
+	if !t.ExpiresAt.After(time.Now()) {
+		return emailtoken.Token{}, emailtoken.ErrNotFound
+	}
+	return t, nil
+}
+
+const queryDeleteTokensByUser = `
+	DELETE FROM email_tokens
+	WHERE uid = $1 AND purpose = $2
+`
+
+func (p *Postgres) DeleteTokensByUser(uid uint, purpose emailtoken.Purpose) error {
+	_, err := p.conn.Exec(queryDeleteTokensByUser, uid, purpose)
+	return err
+}
+
+const queryDeleteExpiredTokens = `
+	DELETE FROM email_tokens
+	WHERE expiresAt <= now()
+`
+
+func (p *Postgres) DeleteExpiredTokens() error {
+	_, err := p.conn.Exec(queryDeleteExpiredTokens)
+	return err
+}
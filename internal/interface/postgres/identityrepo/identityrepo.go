@@ -0,0 +1,108 @@
+package identityrepo
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/identity"
+)
+
+const uniqueViolation = "23505"
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateIdentity = `
+	INSERT INTO identities(
+		provider,
+		subject,
+		uid,
+		email,
+		createdAt
+	) VALUES ($1, $2, $3, $4, $5)
+`
+
+func (p *Postgres) CreateIdentity(i identity.Identity) error {
+	_, err := p.conn.Exec(queryCreateIdentity, i.Provider, i.Subject, i.Uid, i.Email, i.CreatedAt)
+	if err, ok := err.(*pq.Error); ok && err.Code == uniqueViolation {
+		return identity.ErrAlreadyExist
+	}
+	return err
+}
+
+const queryGetIdentity = `
+	SELECT
+		provider,
+		subject,
+		uid,
+		email,
+		createdAt
+	FROM identities
+	WHERE provider = $1 AND subject = $2
+`
+
+func (p *Postgres) GetIdentity(provider, subject string) (identity.Identity, error) {
+	i := identity.Identity{}
+	row := p.conn.QueryRow(queryGetIdentity, provider, subject)
+	err := row.Scan(&i.Provider, &i.Subject, &i.Uid, &i.Email, &i.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return identity.Identity{}, identity.ErrNotFound
+		}
+		return identity.Identity{}, err
+	}
+	return i, nil
+}
+
+const queryGetIdentitiesByUser = `
+	SELECT
+		provider,
+		subject,
+		uid,
+		email,
+		createdAt
+	FROM identities
+	WHERE uid = $1
+	ORDER BY provider
+`
+
+func (p *Postgres) GetIdentitiesByUser(uid uint) ([]identity.Identity, error) {
+	rows, err := p.conn.Query(queryGetIdentitiesByUser, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ii []identity.Identity
+	for rows.Next() {
+		i := identity.Identity{}
+		if err := rows.Scan(&i.Provider, &i.Subject, &i.Uid, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		ii = append(ii, i)
+	}
+	return ii, rows.Err()
+}
+
+const queryDeleteIdentity = `
+	DELETE FROM identities
+	WHERE uid = $1 AND provider = $2
+`
+
+func (p *Postgres) DeleteIdentity(uid uint, provider string) error {
+	res, err := p.conn.Exec(queryDeleteIdentity, uid, provider)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return identity.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package linteventrepo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/linteventrepo"
+	"time"
+)
+
+const channel = "snippet_lint_events"
+
+// Postgres fans events out through LISTEN/NOTIFY, so that a subscriber
+// connected to one replica hears about linting done by another. Every
+// replica keeps a single listening connection and hands the notifications
+// to its local subscribers.
+type Postgres struct {
+	conn     *sql.DB
+	listener *pq.Listener
+	local    *linteventrepo.Memory
+}
+
+type notification struct {
+	Sid    uint             `json:"sid"`
+	Status lintevent.Status `json:"status"`
+}
+
+func New(conn *sql.DB, connStr string) (*Postgres, error) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, nil)
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	p := &Postgres{
+		conn:     conn,
+		listener: listener,
+		local:    linteventrepo.NewMemory(),
+	}
+	go p.run()
+	return p, nil
+}
+
+func (p *Postgres) run() {
+	for n := range p.listener.Notify {
+		// nil follows a reconnect, notifications sent meanwhile are lost
+		if n == nil {
+			continue
+		}
+		var m notification
+		if err := json.Unmarshal([]byte(n.Extra), &m); err != nil {
+			fmt.Printf("Error decoding lint event %q: %s\n", n.Extra, err)
+			continue
+		}
+		_ = p.local.Publish(lintevent.Event{Sid: m.Sid, Status: m.Status})
+	}
+}
+
+const queryNotify = `
+	SELECT pg_notify($1, $2)
+`
+
+func (p *Postgres) Publish(e lintevent.Event) error {
+	payload, err := json.Marshal(notification{Sid: e.Sid, Status: e.Status})
+	if err != nil {
+		return err
+	}
+	_, err = p.conn.Exec(queryNotify, channel, string(payload))
+	return err
+}
+
+func (p *Postgres) Subscribe(sid uint) (<-chan lintevent.Event, func()) {
+	return p.local.Subscribe(sid)
+}
+
+func (p *Postgres) Close() error {
+	return p.listener.Close()
+}
@@ -0,0 +1,59 @@
+package lintoptionsrepo
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryGetDefaultLintOptions = `
+	SELECT options
+	FROM account_lint_options
+	WHERE uid = $1
+`
+
+func (p *Postgres) GetDefaultLintOptions(uid uint) (map[string]map[string]string, error) {
+	var raw string
+	if err := p.conn.QueryRow(queryGetDefaultLintOptions, uid).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var opts map[string]map[string]string
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+const querySetDefaultLintOptions = `
+	INSERT INTO account_lint_options(uid, options)
+	VALUES ($1, $2)
+	ON CONFLICT (uid) DO UPDATE SET options = excluded.options
+`
+
+const queryDeleteDefaultLintOptions = `
+	DELETE FROM account_lint_options
+	WHERE uid = $1
+`
+
+func (p *Postgres) SetDefaultLintOptions(uid uint, opts map[string]map[string]string) error {
+	if len(opts) == 0 {
+		_, err := p.conn.Exec(queryDeleteDefaultLintOptions, uid)
+		return err
+	}
+	raw, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	_, err = p.conn.Exec(querySetDefaultLintOptions, uid, string(raw))
+	return err
+}
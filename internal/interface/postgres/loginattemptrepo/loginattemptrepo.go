@@ -0,0 +1,79 @@
+package loginattemptrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/loginattempt"
+	"time"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryGetAttempts = `
+	SELECT
+		key,
+		failures,
+		lockedUntil,
+		updatedAt
+	FROM login_attempts
+	WHERE key = $1
+`
+
+func (p *Postgres) GetAttempts(key string) (loginattempt.Attempts, error) {
+	a := loginattempt.Attempts{}
+	row := p.conn.QueryRow(queryGetAttempts, key)
+	err := row.Scan(&a.Key, &a.Failures, &a.LockedUntil, &a.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return loginattempt.Attempts{Key: key}, nil
+		}
+		return loginattempt.Attempts{}, err
+	}
+	return a, nil
+}
+
+const queryRecordFailure = `
+	INSERT INTO login_attempts AS a(
+		key,
+		failures,
+		lockedUntil,
+		updatedAt
+	) VALUES ($1, 1, 'epoch', now())
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE WHEN a.updatedAt < now() - $2 * interval '1 second'
+	                    THEN 1 ELSE a.failures + 1 END,
+	    updatedAt = now()
+	RETURNING failures
+`
+
+func (p *Postgres) RecordFailure(key string, window time.Duration) (int, error) {
+	var failures int
+	err := p.conn.QueryRow(queryRecordFailure, key, window.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+const queryLock = `
+	UPDATE login_attempts
+	SET lockedUntil = GREATEST(lockedUntil, $2)
+	WHERE key = $1
+`
+
+func (p *Postgres) Lock(key string, until time.Time) error {
+	_, err := p.conn.Exec(queryLock, key, until)
+	return err
+}
+
+const queryReset = `
+	DELETE FROM login_attempts
+	WHERE key = $1
+`
+
+func (p *Postgres) Reset(key string) error {
+	_, err := p.conn.Exec(queryReset, key)
+	return err
+}
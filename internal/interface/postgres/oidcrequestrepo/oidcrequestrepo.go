@@ -0,0 +1,74 @@
+package oidcrequestrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/oidcrequest"
+	"time"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateRequest = `
+	INSERT INTO oidc_requests(
+		stateHash,
+		provider,
+		nonce,
+		codeVerifier,
+		device,
+		link,
+		linkUid,
+		expiresAt
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
+func (p *Postgres) CreateRequest(r oidcrequest.Request) error {
+	_, err := p.conn.Exec(queryCreateRequest, r.StateHash, r.Provider, r.Nonce, r.CodeVerifier,
+		r.Device, r.Link, r.LinkUid, r.ExpiresAt)
+	return err
+}
+
+const queryConsumeRequest = `
+	DELETE FROM oidc_requests
+	WHERE stateHash = $1
+	RETURNING
+		stateHash,
+		provider,
+		nonce,
+		codeVerifier,
+		device,
+		link,
+		linkUid,
+		expiresAt
+`
+
+func (p *Postgres) ConsumeRequest(stateHash string) (oidcrequest.Request, error) {
+	r := oidcrequest.Request{}
+	row := p.conn.QueryRow(queryConsumeRequest, stateHash)
+	err := row.Scan(&r.StateHash, &r.Provider, &r.Nonce, &r.CodeVerifier, &r.Device, &r.Link, &r.LinkUid, &r.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return oidcrequest.Request{}, oidcrequest.ErrNotFound
+		}
+		return oidcrequest.Request{}, err
+	}
+	if !r.ExpiresAt.After(time.Now()) {
+		return oidcrequest.Request{}, oidcrequest.ErrNotFound
+	}
+	return r, nil
+}
+
+const queryDeleteExpiredRequests = `
+	DELETE FROM oidc_requests
+	WHERE expiresAt <= now()
+`
+
+func (p *Postgres) DeleteExpiredRequests() error {
+	_, err := p.conn.Exec(queryDeleteExpiredRequests)
+	return err
+}
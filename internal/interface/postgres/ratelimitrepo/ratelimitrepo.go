@@ -0,0 +1,76 @@
+package ratelimitrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/ratelimit"
+	"time"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+// The bucket is refilled and taken from in a single statement, so replicas
+// sharing the database never race on it. $2 is the capacity and $3 the rate
+// per second.
+const queryTake = `
+	INSERT INTO rate_limits AS r(
+		key,
+		tokens,
+		allowed,
+		updatedAt
+	) VALUES ($1, $2::double precision - 1, true, now())
+	ON CONFLICT (key) DO UPDATE
+	SET allowed = LEAST($2::double precision,
+	                    r.tokens + EXTRACT(EPOCH FROM now() - r.updatedAt)::double precision * $3::double precision) >= 1,
+	    tokens = LEAST($2::double precision,
+	                   r.tokens + EXTRACT(EPOCH FROM now() - r.updatedAt)::double precision * $3::double precision)
+	             - CASE WHEN LEAST($2::double precision,
+	                               r.tokens + EXTRACT(EPOCH FROM now() - r.updatedAt)::double precision * $3::double precision) >= 1
+	                    THEN 1 ELSE 0 END,
+	    updatedAt = now()
+	RETURNING allowed, tokens
+`
+
+func (p *Postgres) Take(key string, rule ratelimit.Rule) (bool, time.Duration, error) {
+	var allowed bool
+	var tokens float64
+	err := p.conn.QueryRow(queryTake, key, rule.Capacity(), rule.RatePerSecond()).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed, rule.RetryAfter(tokens), nil
+}
+
+const queryPeek = `
+	SELECT LEAST($2::double precision,
+	             tokens + EXTRACT(EPOCH FROM now() - updatedAt)::double precision * $3::double precision)
+	FROM rate_limits
+	WHERE key = $1
+`
+
+func (p *Postgres) Peek(key string, rule ratelimit.Rule) (bool, time.Duration, error) {
+	var tokens float64
+	err := p.conn.QueryRow(queryPeek, key, rule.Capacity(), rule.RatePerSecond()).Scan(&tokens)
+	if err == sql.ErrNoRows {
+		return true, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return tokens >= 1, rule.RetryAfter(tokens), nil
+}
+
+const queryDeleteIdleBuckets = `
+	DELETE FROM rate_limits
+	WHERE updatedAt < now() - $1 * interval '1 second'
+`
+
+func (p *Postgres) DeleteIdleBuckets(idle time.Duration) error {
+	_, err := p.conn.Exec(queryDeleteIdleBuckets, idle.Seconds())
+	return err
+}
@@ -0,0 +1,199 @@
+package sessionrepo
+
+import (
+	"database/sql"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/session"
+	"time"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateSession = `
+	INSERT INTO sessions(
+		id,
+		uid,
+		device,
+		refreshTokenHash,
+		accessTokenId,
+		accessExpiresAt,
+		createdAt,
+		lastUsedAt,
+		expiresAt
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+`
+
+func (p *Postgres) CreateSession(s session.Session) error {
+	_, err := p.conn.Exec(queryCreateSession, s.Id, s.Uid, s.Device, s.RefreshTokenHash,
+		s.AccessTokenId, s.AccessExpiresAt, s.CreatedAt, s.LastUsedAt, s.ExpiresAt)
+	return err
+}
+
+const sessionColumns = `
+		id,
+		uid,
+		device,
+		refreshTokenHash,
+		accessTokenId,
+		accessExpiresAt,
+		createdAt,
+		lastUsedAt,
+		expiresAt
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row scanner) (session.Session, error) {
+	s := session.Session{}
+	err := row.Scan(&s.Id, &s.Uid, &s.Device, &s.RefreshTokenHash, &s.AccessTokenId,
+		&s.AccessExpiresAt, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return session.Session{}, session.ErrNotFound
+		}
+		return session.Session{}, err
+	}
+	return s, nil
+}
+
+const queryGetSessionById = `
+	SELECT` + sessionColumns + `
+	FROM sessions
+	WHERE id = $1
+`
+
+func (p *Postgres) GetSessionById(id string) (session.Session, error) {
+	return scanSession(p.conn.QueryRow(queryGetSessionById, id))
+}
+
+const queryGetSessionByRefreshToken = `
+	SELECT` + sessionColumns + `
+	FROM sessions
+	WHERE refreshTokenHash = $1
+`
+
+func (p *Postgres) GetSessionByRefreshToken(hash string) (session.Session, error) {
+	return scanSession(p.conn.QueryRow(queryGetSessionByRefreshToken, hash))
+}
+
+const queryGetSessionsByUser = `
+	SELECT` + sessionColumns + `
+	FROM sessions
+	WHERE uid = $1
+	ORDER BY lastUsedAt DESC
+`
+
+func (p *Postgres) GetSessionsByUser(uid uint) ([]session.Session, error) {
+	rows, err := p.conn.Query(queryGetSessionsByUser, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ss []session.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, rows.Err()
+}
+
+const queryRotateSession = `
+	UPDATE sessions
+	SET refreshTokenHash = $3,
+	    accessTokenId = $4,
+	    accessExpiresAt = $5,
+	    lastUsedAt = $6,
+	    expiresAt = $7
+	WHERE id = $1 AND refreshTokenHash = $2
+`
+
+func (p *Postgres) RotateSession(oldHash string, s session.Session) error {
+	res, err := p.conn.Exec(queryRotateSession, s.Id, oldHash, s.RefreshTokenHash,
+		s.AccessTokenId, s.AccessExpiresAt, s.LastUsedAt, s.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return session.ErrNotFound
+	}
+	return nil
+}
+
+const queryDeleteSession = `
+	DELETE FROM sessions
+	WHERE id = $1
+`
+
+func (p *Postgres) DeleteSession(id string) error {
+	res, err := p.conn.Exec(queryDeleteSession, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return session.ErrNotFound
+	}
+	return nil
+}
+
+const queryDeleteExpiredSessions = `
+	DELETE FROM sessions
+	WHERE expiresAt < now()
+`
+
+const queryDeleteExpiredRevokedTokens = `
+	DELETE FROM revoked_tokens
+	WHERE expiresAt < now()
+`
+
+func (p *Postgres) DeleteExpiredSessions() error {
+	if _, err := p.conn.Exec(queryDeleteExpiredSessions); err != nil {
+		return err
+	}
+	_, err := p.conn.Exec(queryDeleteExpiredRevokedTokens)
+	return err
+}
+
+const queryRevokeAccessToken = `
+	INSERT INTO revoked_tokens(
+		jti,
+		expiresAt
+	) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING
+`
+
+func (p *Postgres) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	_, err := p.conn.Exec(queryRevokeAccessToken, jti, expiresAt)
+	return err
+}
+
+const queryIsAccessTokenRevoked = `
+	SELECT EXISTS(
+		SELECT 1
+		FROM revoked_tokens
+		WHERE jti = $1
+	)
+`
+
+func (p *Postgres) IsAccessTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := p.conn.QueryRow(queryIsAccessTokenRevoked, jti).Scan(&revoked)
+	return revoked, err
+}
@@ -0,0 +1,112 @@
+package starrepo
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+)
+
+const foreignKeyViolation = "23503"
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+// snippets.stars is kept in step by a trigger on snippet_stars, which also
+// covers the stars removed along with an account.
+const queryStar = `
+	INSERT INTO snippet_stars(uid, sid)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING
+`
+
+func (p *Postgres) Star(uid, sid uint) (bool, error) {
+	res, err := p.conn.Exec(queryStar, uid, sid)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == foreignKeyViolation {
+			return false, codesnippetrepo.ErrInvalidSnippedId
+		}
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+const queryUnstar = `
+	DELETE FROM snippet_stars
+	WHERE uid = $1 AND sid = $2
+`
+
+func (p *Postgres) Unstar(uid, sid uint) (bool, error) {
+	res, err := p.conn.Exec(queryUnstar, uid, sid)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+const queryIsStarred = `
+	SELECT EXISTS (
+		SELECT 1 FROM snippet_stars WHERE uid = $1 AND sid = $2
+	)
+`
+
+func (p *Postgres) IsStarred(uid, sid uint) (bool, error) {
+	var starred bool
+	err := p.conn.QueryRow(queryIsStarred, uid, sid).Scan(&starred)
+	return starred, err
+}
+
+const queryGetStarCount = `
+	SELECT stars
+	FROM snippets
+	WHERE id = $1
+`
+
+func (p *Postgres) GetStarCount(sid uint) (uint, error) {
+	var stars uint
+	err := p.conn.QueryRow(queryGetStarCount, sid).Scan(&stars)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, codesnippetrepo.ErrInvalidSnippedId
+		}
+		return 0, err
+	}
+	return stars, nil
+}
+
+const queryGetStarredSnippetIds = `
+	SELECT sid
+	FROM snippet_stars
+	WHERE uid = $1
+	ORDER BY createdAt DESC, sid DESC
+	LIMIT $2 OFFSET $3
+`
+
+func (p *Postgres) GetStarredSnippetIds(uid uint, limit, offset int) ([]uint, error) {
+	rows, err := p.conn.Query(queryGetStarredSnippetIds, uid, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,419 @@
+package teamrepo
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+const queryCreateTeam = `
+	INSERT INTO teams(name)
+	VALUES ($1)
+	RETURNING id, name, createdAt
+`
+
+const queryCreateOwner = `
+	INSERT INTO team_members(
+		tid,
+		uid,
+		role,
+		joinedAt
+	) VALUES ($1, $2, $3, $4)
+`
+
+func (p *Postgres) CreateTeam(name string, uid uint) (team.Team, error) {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return team.Team{}, err
+	}
+	defer tx.Rollback()
+
+	t := team.Team{}
+	err = tx.QueryRow(queryCreateTeam, name).Scan(&t.Id, &t.Name, &t.CreatedAt)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == uniqueViolation {
+			return team.Team{}, team.ErrAlreadyExist
+		}
+		return team.Team{}, err
+	}
+	if _, err := tx.Exec(queryCreateOwner, t.Id, uid, team.Owner, t.CreatedAt); err != nil {
+		return team.Team{}, err
+	}
+	return t, tx.Commit()
+}
+
+const queryGetTeamById = `
+	SELECT
+		id,
+		name,
+		createdAt
+	FROM teams
+	WHERE id = $1
+`
+
+func (p *Postgres) GetTeamById(tid uint) (team.Team, error) {
+	t := team.Team{}
+	row := p.conn.QueryRow(queryGetTeamById, tid)
+	err := row.Scan(&t.Id, &t.Name, &t.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return team.Team{}, team.ErrNotFound
+		}
+		return team.Team{}, err
+	}
+	return t, nil
+}
+
+const queryGetTeamsByUser = `
+	SELECT
+		t.id,
+		t.name,
+		t.createdAt,
+		m.role,
+		m.joinedAt
+	FROM teams t
+	JOIN team_members m ON m.tid = t.id
+	WHERE m.uid = $1
+	ORDER BY t.name
+`
+
+func (p *Postgres) GetTeamsByUser(uid uint) ([]team.Team, []team.Membership, error) {
+	rows, err := p.conn.Query(queryGetTeamsByUser, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var tt []team.Team
+	var mm []team.Membership
+	for rows.Next() {
+		t := team.Team{}
+		ms := team.Membership{Uid: uid}
+		if err := rows.Scan(&t.Id, &t.Name, &t.CreatedAt, &ms.Role, &ms.JoinedAt); err != nil {
+			return nil, nil, err
+		}
+		ms.Tid = t.Id
+		tt = append(tt, t)
+		mm = append(mm, ms)
+	}
+	return tt, mm, rows.Err()
+}
+
+// members, invitations and snippets are removed by the cascade
+const queryDeleteTeam = `
+	DELETE FROM teams
+	WHERE id = $1
+`
+
+func (p *Postgres) DeleteTeam(tid uint) error {
+	res, err := p.conn.Exec(queryDeleteTeam, tid)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return team.ErrNotFound
+	}
+	return nil
+}
+
+const queryGetMembership = `
+	SELECT
+		tid,
+		uid,
+		role,
+		joinedAt
+	FROM team_members
+	WHERE tid = $1 AND uid = $2
+`
+
+func (p *Postgres) GetMembership(tid, uid uint) (team.Membership, error) {
+	ms := team.Membership{}
+	row := p.conn.QueryRow(queryGetMembership, tid, uid)
+	err := row.Scan(&ms.Tid, &ms.Uid, &ms.Role, &ms.JoinedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return team.Membership{}, team.ErrMemberNotFound
+		}
+		return team.Membership{}, err
+	}
+	return ms, nil
+}
+
+const queryGetMembers = `
+	SELECT
+		tid,
+		uid,
+		role,
+		joinedAt
+	FROM team_members
+	WHERE tid = $1
+	ORDER BY joinedAt, uid
+`
+
+func (p *Postgres) GetMembers(tid uint) ([]team.Membership, error) {
+	rows, err := p.conn.Query(queryGetMembers, tid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var mm []team.Membership
+	for rows.Next() {
+		ms := team.Membership{}
+		if err := rows.Scan(&ms.Tid, &ms.Uid, &ms.Role, &ms.JoinedAt); err != nil {
+			return nil, err
+		}
+		mm = append(mm, ms)
+	}
+	return mm, rows.Err()
+}
+
+// queryLockOwners keeps the owners from changing until the transaction
+// ends, so that two of them can not step down at once.
+const queryLockOwners = `
+	SELECT uid
+	FROM team_members
+	WHERE tid = $1 AND role = 'owner'
+	FOR UPDATE
+`
+
+const querySetMemberRole = `
+	UPDATE team_members
+	SET role = $3
+	WHERE tid = $1 AND uid = $2
+`
+
+func (p *Postgres) SetMemberRole(tid, uid uint, role team.Role) error {
+	return p.execMember(role != team.Owner, querySetMemberRole, tid, uid, role)
+}
+
+const queryDeleteMember = `
+	DELETE FROM team_members
+	WHERE tid = $1 AND uid = $2
+`
+
+func (p *Postgres) DeleteMember(tid, uid uint) error {
+	return p.execMember(true, queryDeleteMember, tid, uid)
+}
+
+// execMember runs a query on the member tid, uid. With demotes set it fails
+// with team.ErrLastOwner if the member is the last owner.
+func (p *Postgres) execMember(demotes bool, query string, tid, uid uint, args ...interface{}) error {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if demotes {
+		owners, err := lockOwners(tx, tid)
+		if err != nil {
+			return err
+		}
+		if len(owners) == 1 && owners[0] == uid {
+			return team.ErrLastOwner
+		}
+	}
+	res, err := tx.Exec(query, append([]interface{}{tid, uid}, args...)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return team.ErrMemberNotFound
+	}
+	return tx.Commit()
+}
+
+func lockOwners(tx *sql.Tx, tid uint) ([]uint, error) {
+	rows, err := tx.Query(queryLockOwners, tid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var owners []uint
+	for rows.Next() {
+		var uid uint
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		owners = append(owners, uid)
+	}
+	return owners, rows.Err()
+}
+
+// a repeated invitation replaces the pending one
+const queryCreateInvitation = `
+	INSERT INTO team_invitations(
+		tid,
+		uid,
+		role,
+		invitedBy,
+		createdAt,
+		expiresAt
+	)
+	SELECT $1, $2, $3, $4, $5, $6
+	WHERE NOT EXISTS (
+		SELECT 1 FROM team_members WHERE tid = $1 AND uid = $2
+	)
+	ON CONFLICT (tid, uid) DO UPDATE SET
+		role = excluded.role,
+		invitedBy = excluded.invitedBy,
+		createdAt = excluded.createdAt,
+		expiresAt = excluded.expiresAt
+	RETURNING id
+`
+
+func (p *Postgres) CreateInvitation(i team.Invitation) (team.Invitation, error) {
+	row := p.conn.QueryRow(queryCreateInvitation, i.Tid, i.Uid, i.Role, i.InvitedBy, i.CreatedAt, i.ExpiresAt)
+	err := row.Scan(&i.Id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return team.Invitation{}, team.ErrMemberAlreadyExist
+		}
+		if err, ok := err.(*pq.Error); ok && err.Code == foreignKeyViolation {
+			return team.Invitation{}, team.ErrNotFound
+		}
+		return team.Invitation{}, err
+	}
+	return i, nil
+}
+
+const invitationColumns = `
+		id,
+		tid,
+		uid,
+		role,
+		invitedBy,
+		createdAt,
+		expiresAt
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInvitation(s scanner) (team.Invitation, error) {
+	i := team.Invitation{}
+	err := s.Scan(&i.Id, &i.Tid, &i.Uid, &i.Role, &i.InvitedBy, &i.CreatedAt, &i.ExpiresAt)
+	return i, err
+}
+
+const queryGetInvitationById = `
+	SELECT` + invitationColumns + `
+	FROM team_invitations
+	WHERE id = $1
+`
+
+func (p *Postgres) GetInvitationById(id uint) (team.Invitation, error) {
+	i, err := scanInvitation(p.conn.QueryRow(queryGetInvitationById, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return team.Invitation{}, team.ErrInvitationNotFound
+		}
+		return team.Invitation{}, err
+	}
+	return i, nil
+}
+
+const queryGetInvitationsByUser = `
+	SELECT` + invitationColumns + `
+	FROM team_invitations
+	WHERE uid = $1
+	ORDER BY id
+`
+
+func (p *Postgres) GetInvitationsByUser(uid uint) ([]team.Invitation, error) {
+	rows, err := p.conn.Query(queryGetInvitationsByUser, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ii []team.Invitation
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ii = append(ii, i)
+	}
+	return ii, rows.Err()
+}
+
+const queryTakeInvitation = `
+	DELETE FROM team_invitations
+	WHERE id = $1
+	RETURNING tid, uid, role
+`
+
+const queryCreateMember = `
+	INSERT INTO team_members(
+		tid,
+		uid,
+		role
+	) VALUES ($1, $2, $3)
+	RETURNING joinedAt
+`
+
+func (p *Postgres) AcceptInvitation(id uint) (team.Membership, error) {
+	tx, err := p.conn.Begin()
+	if err != nil {
+		return team.Membership{}, err
+	}
+	defer tx.Rollback()
+
+	ms := team.Membership{}
+	err = tx.QueryRow(queryTakeInvitation, id).Scan(&ms.Tid, &ms.Uid, &ms.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return team.Membership{}, team.ErrInvitationNotFound
+		}
+		return team.Membership{}, err
+	}
+	err = tx.QueryRow(queryCreateMember, ms.Tid, ms.Uid, ms.Role).Scan(&ms.JoinedAt)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == uniqueViolation {
+			return team.Membership{}, team.ErrMemberAlreadyExist
+		}
+		return team.Membership{}, err
+	}
+	return ms, tx.Commit()
+}
+
+const queryDeleteInvitation = `
+	DELETE FROM team_invitations
+	WHERE id = $1
+`
+
+func (p *Postgres) DeleteInvitation(id uint) error {
+	res, err := p.conn.Exec(queryDeleteInvitation, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return team.ErrInvitationNotFound
+	}
+	return nil
+}
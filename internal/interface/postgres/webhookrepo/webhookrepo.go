@@ -0,0 +1,267 @@
+package webhookrepo
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"strings"
+	"time"
+)
+
+const foreignKeyViolation = "23503"
+
+type Postgres struct {
+	conn *sql.DB
+}
+
+func New(conn *sql.DB) *Postgres {
+	return &Postgres{conn: conn}
+}
+
+func joinEvents(events []webhook.Event) string {
+	ss := make([]string, len(events))
+	for i, e := range events {
+		ss[i] = string(e)
+	}
+	return strings.Join(ss, " ")
+}
+
+func splitEvents(s string) []webhook.Event {
+	var events []webhook.Event
+	for _, e := range strings.Fields(s) {
+		events = append(events, webhook.Event(e))
+	}
+	return events
+}
+
+const queryCreateWebhook = `
+	INSERT INTO webhooks(
+		uid,
+		url,
+		secret,
+		events,
+		createdAt
+	) VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+`
+
+func (p *Postgres) CreateWebhook(w webhook.Webhook) (webhook.Webhook, error) {
+	row := p.conn.QueryRow(queryCreateWebhook, w.Uid, w.Url, w.Secret, joinEvents(w.Events), w.CreatedAt)
+	if err := row.Scan(&w.Id); err != nil {
+		return webhook.Webhook{}, err
+	}
+	return w, nil
+}
+
+const webhookColumns = `
+		id,
+		uid,
+		url,
+		secret,
+		events,
+		createdAt
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(s scanner) (webhook.Webhook, error) {
+	w := webhook.Webhook{}
+	var events string
+	err := s.Scan(&w.Id, &w.Uid, &w.Url, &w.Secret, &events, &w.CreatedAt)
+	w.Events = splitEvents(events)
+	return w, err
+}
+
+const queryGetWebhookById = `
+	SELECT` + webhookColumns + `
+	FROM webhooks
+	WHERE id = $1
+`
+
+func (p *Postgres) GetWebhookById(id uint) (webhook.Webhook, error) {
+	w, err := scanWebhook(p.conn.QueryRow(queryGetWebhookById, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return webhook.Webhook{}, webhook.ErrNotFound
+		}
+		return webhook.Webhook{}, err
+	}
+	return w, nil
+}
+
+const queryGetWebhooksByUser = `
+	SELECT` + webhookColumns + `
+	FROM webhooks
+	WHERE uid = $1
+	ORDER BY id
+`
+
+func (p *Postgres) GetWebhooksByUser(uid uint) ([]webhook.Webhook, error) {
+	rows, err := p.conn.Query(queryGetWebhooksByUser, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ww []webhook.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		ww = append(ww, w)
+	}
+	return ww, rows.Err()
+}
+
+// deliveries are removed by the cascade
+const queryDeleteWebhook = `
+	DELETE FROM webhooks
+	WHERE uid = $1 AND id = $2
+`
+
+func (p *Postgres) DeleteWebhook(uid, id uint) error {
+	res, err := p.conn.Exec(queryDeleteWebhook, uid, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+const queryCreateDelivery = `
+	INSERT INTO webhook_deliveries(
+		webhookId,
+		event,
+		payload,
+		state,
+		attempts,
+		statusCode,
+		error,
+		nextAttemptAt,
+		createdAt,
+		updatedAt
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id
+`
+
+func (p *Postgres) CreateDelivery(d webhook.Delivery) (webhook.Delivery, error) {
+	row := p.conn.QueryRow(queryCreateDelivery, d.WebhookId, d.Event, d.Payload, d.State, d.Attempts,
+		d.StatusCode, d.Error, d.NextAttemptAt, d.CreatedAt, d.UpdatedAt)
+	if err := row.Scan(&d.Id); err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == foreignKeyViolation {
+			return webhook.Delivery{}, webhook.ErrNotFound
+		}
+		return webhook.Delivery{}, err
+	}
+	return d, nil
+}
+
+const deliveryColumns = `
+		id,
+		webhookId,
+		event,
+		payload,
+		state,
+		attempts,
+		statusCode,
+		error,
+		nextAttemptAt,
+		createdAt,
+		updatedAt
+`
+
+func scanDeliveries(rows *sql.Rows) ([]webhook.Delivery, error) {
+	defer rows.Close()
+	var dd []webhook.Delivery
+	for rows.Next() {
+		d := webhook.Delivery{}
+		err := rows.Scan(&d.Id, &d.WebhookId, &d.Event, &d.Payload, &d.State, &d.Attempts,
+			&d.StatusCode, &d.Error, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		dd = append(dd, d)
+	}
+	return dd, rows.Err()
+}
+
+// SKIP LOCKED keeps concurrent workers from waiting on each other's claims.
+const queryClaimDueDeliveries = `
+	UPDATE webhook_deliveries
+	SET nextAttemptAt = $3
+	WHERE id IN (
+		SELECT id
+		FROM webhook_deliveries
+		WHERE state = 'pending' AND nextAttemptAt <= $1
+		ORDER BY nextAttemptAt, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING` + deliveryColumns
+
+func (p *Postgres) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	rows, err := p.conn.Query(queryClaimDueDeliveries, now, limit, now.Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	dd, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING gives the claimed rows, but the worker needs to know when
+	// they were due
+	for i := range dd {
+		dd[i].NextAttemptAt = now
+	}
+	return dd, nil
+}
+
+const queryUpdateDelivery = `
+	UPDATE webhook_deliveries
+	SET state = $2,
+		attempts = $3,
+		statusCode = $4,
+		error = $5,
+		nextAttemptAt = $6,
+		updatedAt = $7
+	WHERE id = $1
+`
+
+func (p *Postgres) UpdateDelivery(d webhook.Delivery) error {
+	res, err := p.conn.Exec(queryUpdateDelivery, d.Id, d.State, d.Attempts, d.StatusCode, d.Error, d.NextAttemptAt, d.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return webhook.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+const queryGetDeliveriesByWebhook = `
+	SELECT` + deliveryColumns + `
+	FROM webhook_deliveries
+	WHERE webhookId = $1
+	ORDER BY id DESC
+	LIMIT $2
+`
+
+func (p *Postgres) GetDeliveriesByWebhook(id uint, limit int) ([]webhook.Delivery, error) {
+	rows, err := p.conn.Query(queryGetDeliveriesByWebhook, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
@@ -0,0 +1,25 @@
+package prom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rateLimitedHttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_requests_total",
+		Help: "HTTP requests rejected by the rate limiter",
+	}, []string{"route", "key"})
+	rateLimiterErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "http_rate_limiter_errors_total",
+		Help: "Rate limiter backend failures, requests are let through on failure",
+	})
+)
+
+func RateLimited(route, key string) {
+	rateLimitedHttpRequests.WithLabelValues(route, key).Inc()
+}
+
+func RateLimiterFailed() {
+	rateLimiterErrors.Inc()
+}
@@ -0,0 +1,144 @@
+package formatter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"go/format"
+	"strings"
+)
+
+var (
+	ErrUnsupportedLanguage = errors.New("no formatter for the language")
+	// ErrFailed is a formatter process failing on its own, like timing out,
+	// rather than on the code.
+	ErrFailed = errors.New("formatter failed")
+)
+
+// Error is a formatter failing on the code, most often because it does not
+// parse. It is meant to be shown to the user.
+type Error struct {
+	Formatter string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return e.Formatter + ": " + e.Err.Error()
+}
+
+type Formatter interface {
+	Name() string
+	Supports(lang string) bool
+	Format(ctx context.Context, code string) (string, error)
+}
+
+type Registry struct {
+	formatters []Formatter
+}
+
+func NewRegistry(formatters ...Formatter) *Registry {
+	return &Registry{formatters: formatters}
+}
+
+// For returns the first formatter for the language, nil if there is none.
+func (r *Registry) For(lang string) Formatter {
+	for _, f := range r.formatters {
+		if f.Supports(lang) {
+			return f
+		}
+	}
+	return nil
+}
+
+// Format fails with an *Error when the formatter could not format the code,
+// and with an error wrapping ErrFailed when the formatter process did not
+// run through. The code is never half formatted.
+func (r *Registry) Format(ctx context.Context, lang string, code string) (string, error) {
+	f := r.For(lang)
+	if f == nil {
+		return "", ErrUnsupportedLanguage
+	}
+	formatted, err := f.Format(ctx, code)
+	if err != nil {
+		// a formatter exits with an error on code it does not accept
+		var runErr *linter.RunError
+		if errors.As(err, &runErr) && runErr.Status != linter.StatusExited {
+			return "", fmt.Errorf("%w: %s: %v", ErrFailed, f.Name(), err)
+		}
+		return "", &Error{Formatter: f.Name(), Err: err}
+	}
+	return formatted, nil
+}
+
+// Go formats Go code the way gofmt does, in process. Snippets may be a list
+// of declarations or of statements too.
+type Go struct{}
+
+func (g *Go) Name() string {
+	return "gofmt"
+}
+
+func (g *Go) Supports(lang string) bool {
+	return strings.EqualFold(lang, "Go")
+}
+
+func (g *Go) Format(ctx context.Context, code string) (string, error) {
+	b, err := format.Source([]byte(code))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// JSON formats JSON the way the canonical view shows it.
+type JSON struct{}
+
+func (j *JSON) Name() string {
+	return "json"
+}
+
+func (j *JSON) Supports(lang string) bool {
+	return strings.EqualFold(lang, "JSON")
+}
+
+func (j *JSON) Format(ctx context.Context, code string) (string, error) {
+	return linter.CanonicalJSON(code)
+}
+
+// Command formats code with an external program, which is given the code in
+// File in its working directory and prints the formatted code. Args refer to
+// File by name.
+type Command struct {
+	Id        string
+	Languages []string
+	Path      string
+	Args      []string
+	File      string
+	Runner    *linter.Runner
+}
+
+func (c *Command) Name() string {
+	return c.Id
+}
+
+func (c *Command) Supports(lang string) bool {
+	for _, l := range c.Languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) Format(ctx context.Context, code string) (string, error) {
+	out, err := c.Runner.Run(ctx, map[string]string{c.File: code}, c.Path, c.Args...)
+	if err != nil {
+		return "", err
+	}
+	// some print nothing rather than fail
+	if len(out.Stdout) == 0 && code != "" {
+		return "", &linter.RunError{Status: linter.StatusInvalidOutput, Err: errors.New("no output")}
+	}
+	return string(out.Stdout), nil
+}
@@ -0,0 +1,57 @@
+package formatter
+
+import (
+	"context"
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Format(t *testing.T) {
+	r := NewRegistry(&Go{}, &JSON{}, &Command{
+		Id:        "upper",
+		Languages: []string{"Python"},
+		Path:      "/bin/sh",
+		Args:      []string{"-c", "grep -q sleep snippet.py && exec sleep 5; grep -q quiet snippet.py && exit 0; tr a-z A-Z < snippet.py; grep -q fail snippet.py && exit 1; true"},
+		File:      "snippet.py",
+		Runner:    &linter.Runner{Timeout: 200 * time.Millisecond, MaxOutput: 1024},
+	})
+	for _, c := range []struct {
+		lang, code, want string
+	}{
+		{"Go", "package main\nfunc main(){\nx:=1\n_ = x}", "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n"},
+		{"go", "x:=1", "x := 1"},
+		{"JSON", ` {"b":1.50, "a":["<x>"]} `, "{\n  \"a\": [\n    \"<x>\"\n  ],\n  \"b\": 1.50\n}\n"},
+		{"python", "print(1)\n", "PRINT(1)\n"},
+	} {
+		got, err := r.Format(context.Background(), c.lang, c.code)
+		if err != nil || got != c.want {
+			t.Errorf("Format(%q) MUST return %q, but %q, %v given", c.code, c.want, got, err)
+		}
+	}
+
+	for lang, code := range map[string]string{
+		"go":     "func main() {",
+		"json":   `{"a": 1,}`,
+		"python": "fail",
+	} {
+		_, err := r.Format(context.Background(), lang, code)
+		if fe, ok := err.(*Error); !ok || fe.Formatter != r.For(lang).Name() {
+			t.Errorf("Format(%q) MUST fail with the formatter named, but %v given", code, err)
+		}
+	}
+	for _, code := range []string{"sleep", "quiet"} {
+		_, err := r.Format(context.Background(), "python", code)
+		if _, ok := err.(*Error); ok || !errors.Is(err, ErrFailed) {
+			t.Errorf("Format(%q) MUST fail with %v, but %v given", code, ErrFailed, err)
+		}
+	}
+	if _, err := r.Format(context.Background(), "rust", "fn main() {}"); err != ErrUnsupportedLanguage {
+		t.Errorf("Format of rust MUST return %v, but %v given", ErrUnsupportedLanguage, err)
+	}
+	if _, err := r.Format(context.Background(), "python", "fail"); !strings.Contains(err.Error(), "upper: ") {
+		t.Errorf("Format error MUST name the formatter, but %v given", err)
+	}
+}
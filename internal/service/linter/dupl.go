@@ -0,0 +1,94 @@
+package linter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DuplName      = "dupl"
+	DuplThreshold = "threshold"
+
+	duplFile = "snippet.go"
+)
+
+// DuplArgs turns validated options into dupl flags.
+func DuplArgs(opts map[string]string) []string {
+	var args []string
+	if t, ok := opts[DuplThreshold]; ok {
+		args = append(args, "-t", t)
+	}
+	return args
+}
+
+// Dupl finds clones in Go code with github.com/mibk/dupl.
+type Dupl struct {
+	Path   string
+	Runner *Runner
+
+	versionOnce sync.Once
+	version     string
+}
+
+func (d *Dupl) Name() string {
+	return DuplName
+}
+
+// Version is a digest of the binary, dupl has no version flag.
+func (d *Dupl) Version() string {
+	d.versionOnce.Do(func() {
+		d.version = binaryDigest(d.Path)
+	})
+	return d.version
+}
+
+func (d *Dupl) Supports(lang string) bool {
+	return supports([]string{"Go"}, lang)
+}
+
+func (d *Dupl) Options() []Option {
+	return []Option{{Name: DuplThreshold, Min: 1, Max: 1000}}
+}
+
+func (d *Dupl) Lint(ctx context.Context, code string, opts map[string]string) ([]Finding, error) {
+	args := append(DuplArgs(opts), "-plumbing", duplFile)
+	out, err := d.Runner.Run(ctx, map[string]string{duplFile: code}, d.Path, args...)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := parseDuplPlumbing(string(out.Stdout))
+	if err != nil {
+		return nil, &RunError{Status: StatusInvalidOutput, Err: err}
+	}
+	return findings, nil
+}
+
+// path:3-5: duplicate of path:7-9
+var duplPlumbingLine = regexp.MustCompile(`:(\d+)-(\d+): duplicate of .*:(\d+)-(\d+)$`)
+
+func parseDuplPlumbing(output string) ([]Finding, error) {
+	var findings []Finding
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := duplPlumbingLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("unexpected dupl output %q", line)
+		}
+		n := make([]int, 4)
+		for i := range n {
+			n[i], _ = strconv.Atoi(m[i+1])
+		}
+		findings = append(findings, Finding{
+			Line:    n[0],
+			EndLine: n[1],
+			Message: fmt.Sprintf("duplicate of lines %d-%d", n[2], n[3]),
+		})
+	}
+	return findings, nil
+}
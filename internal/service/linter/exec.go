@@ -0,0 +1,203 @@
+package linter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Status is how running a linter process ended.
+type Status string
+
+const (
+	StatusSucceeded      Status = "succeeded"
+	StatusExited         Status = "exited"
+	StatusKilled         Status = "killed"
+	StatusTimedOut       Status = "timed_out"
+	StatusOutputTooLarge Status = "output_too_large"
+	StatusNotStarted     Status = "not_started"
+	StatusInvalidOutput  Status = "invalid_output"
+	StatusFailed         Status = "failed"
+)
+
+const (
+	defaultRunTimeout = 10 * time.Second
+	defaultMaxOutput  = 1024 * 1024
+)
+
+// RunError tells why a linter process gave no usable output.
+type RunError struct {
+	Status Status
+	Err    error
+}
+
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// StatusOf returns the status a linter error stands for.
+func StatusOf(err error) Status {
+	if err == nil {
+		return StatusSucceeded
+	}
+	if err == ErrTimeout {
+		return StatusTimedOut
+	}
+	var re *RunError
+	if errors.As(err, &re) {
+		return re.Status
+	}
+	return StatusFailed
+}
+
+// Runner runs every linter process in a private temporary directory with a
+// minimal environment. A process still running after Timeout is killed
+// together with everything it started.
+type Runner struct {
+	Timeout time.Duration
+	// MaxOutput caps stdout and stderr each, in bytes.
+	MaxOutput int
+}
+
+// Output is what a process that exited on its own wrote.
+type Output struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+func (r *Runner) timeout() time.Duration {
+	if r == nil || r.Timeout == 0 {
+		return defaultRunTimeout
+	}
+	return r.Timeout
+}
+
+func (r *Runner) maxOutput() int {
+	if r == nil || r.MaxOutput == 0 {
+		return defaultMaxOutput
+	}
+	return r.MaxOutput
+}
+
+// Run writes the files into a fresh directory and runs path there with the
+// args. A non-zero exit is reported as StatusExited with the output kept.
+func (r *Runner) Run(ctx context.Context, files map[string]string, path string, args ...string) (Output, error) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		return Output{}, &RunError{Status: StatusFailed, Err: err}
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			return Output{}, &RunError{Status: StatusFailed, Err: err}
+		}
+	}
+	// a relative path would be looked up in the temporary directory
+	if filepath.Base(path) != path && !filepath.IsAbs(path) {
+		if path, err = filepath.Abs(path); err != nil {
+			return Output{}, &RunError{Status: StatusNotStarted, Err: err}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout())
+	defer cancel()
+	stdout := &cappedBuffer{max: r.maxOutput()}
+	stderr := &cappedBuffer{max: r.maxOutput()}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=/usr/bin:/bin", "HOME=" + dir, "TMPDIR=" + dir, "LANG=C"}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return Output{}, &RunError{Status: StatusNotStarted, Err: err}
+	}
+	// CommandContext only kills the process itself, children keeping the
+	// output pipes open would make Wait hang.
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-exited:
+		}
+	}()
+	err = cmd.Wait()
+	close(exited)
+
+	out := Output{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return out, &RunError{Status: StatusTimedOut, Err: ErrTimeout}
+	case ctx.Err() != nil:
+		return out, &RunError{Status: StatusKilled, Err: ctx.Err()}
+	case stdout.overflow || stderr.overflow:
+		return out, &RunError{Status: StatusOutputTooLarge, Err: fmt.Errorf("output exceeds %d bytes", r.maxOutput())}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == -1 {
+			return out, &RunError{Status: StatusKilled, Err: err}
+		}
+		return out, &RunError{Status: StatusExited, Err: fmt.Errorf("%v: %s", err, bytes.TrimSpace(out.Stderr))}
+	}
+	if err != nil {
+		return out, &RunError{Status: StatusFailed, Err: err}
+	}
+	return out, nil
+}
+
+// binaryDigest returns a short digest of the executable at path, or an empty
+// string if it cannot be read.
+func binaryDigest(path string) string {
+	path, err := exec.LookPath(path)
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// cappedBuffer keeps the first max bytes written and drops the rest, so a
+// chatty process is not blocked on a full pipe.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.overflow = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
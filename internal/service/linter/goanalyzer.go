@@ -0,0 +1,371 @@
+package linter
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GoAnalyzerName          = "goanalyzer"
+	GoAnalyzerMaxComplexity = "max_complexity"
+
+	// goAnalyzerVersion changes whenever the checks do.
+	goAnalyzerVersion        = "2"
+	defaultMaxComplexity     = 10
+	snippetPackageClause     = "package snippet; "
+	snippetFuncOpening       = snippetPackageClause + "func _() { "
+	snippetFuncClosing       = "\n}"
+	missingPackageClauseHint = "expected 'package'"
+	missingDeclarationHint   = "expected declaration"
+)
+
+// GoAnalyzer parses Go code and runs vet-style checks on the syntax tree,
+// in process. Snippets without a package clause are parsed as if they had
+// one, and snippets of statements as if they were the body of a function.
+type GoAnalyzer struct{}
+
+func (g *GoAnalyzer) Name() string {
+	return GoAnalyzerName
+}
+
+func (g *GoAnalyzer) Version() string {
+	return goAnalyzerVersion
+}
+
+func (g *GoAnalyzer) Supports(lang string) bool {
+	return supports([]string{"Go"}, lang)
+}
+
+func (g *GoAnalyzer) Options() []Option {
+	return []Option{{Name: GoAnalyzerMaxComplexity, Min: 1, Max: 100}}
+}
+
+func (g *GoAnalyzer) Lint(ctx context.Context, code string, opts map[string]string) ([]Finding, error) {
+	maxComplexity := defaultMaxComplexity
+	if v, ok := opts[GoAnalyzerMaxComplexity]; ok {
+		maxComplexity, _ = strconv.Atoi(v)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "snippet.go", code, parser.AllErrors)
+	prefix := ""
+	// the prefix shares the first line with the code and the closing brace
+	// goes on a new last line, so that line numbers stay put and only the
+	// columns of the first line move
+	if err != nil && strings.Contains(err.Error(), missingPackageClauseHint) {
+		prefix = snippetPackageClause
+		fset = token.NewFileSet()
+		f, err = parser.ParseFile(fset, "snippet.go", prefix+code, parser.AllErrors)
+	}
+	if err != nil && prefix != "" && strings.Contains(err.Error(), missingDeclarationHint) {
+		prefix = snippetFuncOpening
+		fset = token.NewFileSet()
+		f, err = parser.ParseFile(fset, "snippet.go", prefix+code+snippetFuncClosing, parser.AllErrors)
+	}
+	a := &goAnalysis{fset: fset, prefix: prefix}
+	if list, ok := err.(scanner.ErrorList); ok {
+		// one error a line, the rest tend to follow from it
+		list.RemoveMultiples()
+		last := strings.Count(strings.TrimSuffix(code, "\n"), "\n") + 1
+		for _, e := range list {
+			if e.Pos.Line > last {
+				// past the code is the closing of the wrapping function,
+				// worth mentioning only when nothing before explains it
+				if len(a.findings) > 0 {
+					break
+				}
+				e.Pos.Line, e.Pos.Column = last, 1
+			}
+			a.reportAt(e.Pos, e.Pos, "syntax error: "+e.Msg)
+		}
+		return a.findings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range f.Decls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		a.checkUnusedResults(fn)
+		a.checkUnreachable(fn)
+		a.checkShadowedErr(fn)
+		if c := cyclomaticComplexity(fn); c > maxComplexity {
+			name := fn.Name.Name
+			if prefix == snippetFuncOpening {
+				name = "the snippet"
+			}
+			a.report(fn.Name, fmt.Sprintf("cyclomatic complexity of %s is %d, more than %d", name, c, maxComplexity))
+		}
+	}
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		if a.findings[i].Line != a.findings[j].Line {
+			return a.findings[i].Line < a.findings[j].Line
+		}
+		return a.findings[i].Column < a.findings[j].Column
+	})
+	return a.findings, nil
+}
+
+type goAnalysis struct {
+	fset *token.FileSet
+	// prefix is what the first line of the code was parsed with
+	prefix   string
+	findings []Finding
+}
+
+func (a *goAnalysis) report(n ast.Node, message string) {
+	a.reportAt(a.fset.Position(n.Pos()), a.fset.Position(n.End()), message)
+}
+
+func (a *goAnalysis) reportAt(start, end token.Position, message string) {
+	column := start.Column
+	if start.Line == 1 {
+		column -= len(a.prefix)
+		// the wrapping function itself
+		if column < 1 {
+			column = 1
+		}
+	}
+	a.findings = append(a.findings, Finding{
+		Line:    start.Line,
+		EndLine: end.Line,
+		Column:  column,
+		Message: message,
+	})
+}
+
+// pureFuncs are functions called only for their results, keyed by package
+// name, builtins under the empty one.
+var pureFuncs = map[string]map[string]bool{
+	"":        {"append": true, "cap": true, "complex": true, "imag": true, "len": true, "make": true, "new": true, "real": true},
+	"errors":  {"New": true},
+	"fmt":     {"Errorf": true, "Sprint": true, "Sprintf": true, "Sprintln": true},
+	"sort":    {"Reverse": true},
+	"strconv": {"Itoa": true, "Quote": true, "FormatInt": true},
+	"strings": {
+		"Contains": true, "Fields": true, "HasPrefix": true, "HasSuffix": true, "Index": true, "Join": true,
+		"Repeat": true, "Replace": true, "ReplaceAll": true, "Split": true, "ToLower": true, "ToUpper": true,
+		"Trim": true, "TrimPrefix": true, "TrimSpace": true, "TrimSuffix": true,
+	},
+}
+
+func (a *goAnalysis) checkUnusedResults(fn *ast.FuncDecl) {
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		stmt, ok := n.(*ast.ExprStmt)
+		if !ok {
+			return true
+		}
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		pkg, name := "", ""
+		switch f := call.Fun.(type) {
+		case *ast.Ident:
+			name = f.Name
+		case *ast.SelectorExpr:
+			if x, ok := f.X.(*ast.Ident); ok {
+				pkg, name = x.Name, f.Sel.Name
+			}
+		}
+		if pureFuncs[pkg][name] {
+			qualified := name
+			if pkg != "" {
+				qualified = pkg + "." + name
+			}
+			a.report(call, fmt.Sprintf("result of %s call not used", qualified))
+		}
+		return true
+	})
+}
+
+// terminates tells whether control never goes past the statement.
+func terminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok != token.FALLTHROUGH
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == "os" && sel.Sel.Name == "Exit" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *goAnalysis) checkUnreachable(fn *ast.FuncDecl) {
+	check := func(list []ast.Stmt) {
+		for i := 0; i+1 < len(list); i++ {
+			if !terminates(list[i]) {
+				continue
+			}
+			// a label may be jumped to
+			if _, ok := list[i+1].(*ast.LabeledStmt); ok {
+				continue
+			}
+			a.report(list[i+1], "unreachable code")
+			return
+		}
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.BlockStmt:
+			check(s.List)
+		case *ast.CaseClause:
+			check(s.Body)
+		case *ast.CommClause:
+			check(s.Body)
+		}
+		return true
+	})
+}
+
+type shadow struct {
+	inner    *ast.Ident
+	outer    *ast.Object
+	scopeEnd token.Pos
+}
+
+// checkShadowedErr reports err declared with := while an err of an
+// enclosing scope is in use after the inner scope ends, where the inner
+// value was most likely meant to reach.
+func (a *goAnalysis) checkShadowedErr(fn *ast.FuncDecl) {
+	type scope struct {
+		node ast.Node
+		objs map[string]*ast.Object
+	}
+	var scopes []*scope
+	var stack []ast.Node
+	var shadows []shadow
+	declare := func(id *ast.Ident) {
+		if id == nil || id.Obj == nil || id.Name == "_" || len(scopes) == 0 {
+			return
+		}
+		inner := scopes[len(scopes)-1]
+		if id.Name == "err" {
+			for i := len(scopes) - 2; i >= 0; i-- {
+				if outer, ok := scopes[i].objs[id.Name]; ok {
+					shadows = append(shadows, shadow{inner: id, outer: outer, scopeEnd: inner.node.End()})
+					break
+				}
+			}
+		}
+		inner.objs[id.Name] = id.Obj
+	}
+	declareFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, f := range fields.List {
+			for _, id := range f.Names {
+				declare(id)
+			}
+		}
+	}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(scopes) > 0 && scopes[len(scopes)-1].node == top {
+				scopes = scopes[:len(scopes)-1]
+			}
+			return true
+		}
+		stack = append(stack, n)
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.FuncLit, *ast.BlockStmt, *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt,
+			*ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.CaseClause, *ast.CommClause:
+			scopes = append(scopes, &scope{node: n, objs: map[string]*ast.Object{}})
+		}
+		switch s := n.(type) {
+		case *ast.FuncDecl:
+			declareFields(s.Recv)
+			declareFields(s.Type.Params)
+			declareFields(s.Type.Results)
+		case *ast.FuncLit:
+			declareFields(s.Type.Params)
+			declareFields(s.Type.Results)
+		case *ast.AssignStmt:
+			if s.Tok == token.DEFINE {
+				for _, lhs := range s.Lhs {
+					if id, ok := lhs.(*ast.Ident); ok && id.Obj != nil && id.Obj.Decl == s {
+						declare(id)
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if s.Tok == token.DEFINE {
+				for _, e := range []ast.Expr{s.Key, s.Value} {
+					if id, ok := e.(*ast.Ident); ok {
+						declare(id)
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, id := range s.Names {
+				declare(id)
+			}
+		}
+		return true
+	})
+	for _, sh := range shadows {
+		used := false
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Obj == sh.outer && id.Pos() > sh.scopeEnd {
+				used = true
+			}
+			return !used
+		})
+		if used {
+			line := a.fset.Position(sh.outer.Pos()).Line
+			a.report(sh.inner, fmt.Sprintf("declaration of err shadows declaration at line %d", line))
+		}
+	}
+}
+
+// cyclomaticComplexity counts the branches of the function, closures
+// included, plus one.
+func cyclomaticComplexity(fn *ast.FuncDecl) int {
+	c := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if s.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if s.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if s.Op == token.LAND || s.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+	return c
+}
@@ -0,0 +1,153 @@
+package linter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrTimeout       = errors.New("linter timed out")
+	ErrInvalidOption = errors.New("invalid linter option")
+)
+
+// Options are settings for the linters, keyed by linter name and then by
+// option name.
+type Options map[string]map[string]string
+
+// Merge returns the options with every option set in override replaced.
+func (o Options) Merge(override Options) Options {
+	merged := Options{}
+	for _, oo := range []Options{o, override} {
+		for name, opts := range oo {
+			if merged[name] == nil {
+				merged[name] = map[string]string{}
+			}
+			for k, v := range opts {
+				merged[name][k] = v
+			}
+		}
+	}
+	return merged
+}
+
+// Option describes a setting a linter accepts. All options are integers for
+// now.
+type Option struct {
+	Name string
+	Min  int
+	Max  int
+}
+
+func (o Option) validate(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < o.Min || n > o.Max {
+		return ErrInvalidOption
+	}
+	return nil
+}
+
+// Finding is one problem a linter reported, anchored to a range of lines.
+// Column is zero when the linter does not tell.
+type Finding struct {
+	Line    int
+	EndLine int
+	Column  int
+	Message string
+}
+
+type Linter interface {
+	Name() string
+	// Version tells builds of the linter apart, so that results can be
+	// told apart too. It is empty when unknown.
+	Version() string
+	Supports(lang string) bool
+	Options() []Option
+	// Lint is given options already validated against Options.
+	Lint(ctx context.Context, code string, opts map[string]string) ([]Finding, error)
+}
+
+// Report is what a single linter said about the code. Err is set when the
+// linter could not give an opinion at all, Status tells why.
+type Report struct {
+	Linter   string
+	Version  string
+	Status   Status
+	Findings []Finding
+	Err      error
+}
+
+type Registry struct {
+	linters []Linter
+}
+
+func NewRegistry(linters ...Linter) *Registry {
+	return &Registry{linters: linters}
+}
+
+func (r *Registry) For(lang string) []Linter {
+	var ll []Linter
+	for _, l := range r.linters {
+		if l.Supports(lang) {
+			ll = append(ll, l)
+		}
+	}
+	return ll
+}
+
+// Validate rejects options of unknown linters, unknown options and values
+// out of range.
+func (r *Registry) Validate(opts Options) error {
+	for name, values := range opts {
+		var l Linter
+		for _, candidate := range r.linters {
+			if candidate.Name() == name {
+				l = candidate
+				break
+			}
+		}
+		if l == nil {
+			return ErrInvalidOption
+		}
+		for k, v := range values {
+			known := false
+			for _, o := range l.Options() {
+				if o.Name == k {
+					if err := o.validate(v); err != nil {
+						return err
+					}
+					known = true
+					break
+				}
+			}
+			if !known {
+				return ErrInvalidOption
+			}
+		}
+	}
+	return nil
+}
+
+// Lint runs every linter for the language one after another, all of them
+// within the deadline of ctx.
+func (r *Registry) Lint(ctx context.Context, lang string, code string, opts Options) []Report {
+	var reports []Report
+	for _, l := range r.For(lang) {
+		findings, err := l.Lint(ctx, code, opts[l.Name()])
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			err = ErrTimeout
+		}
+		reports = append(reports, Report{Linter: l.Name(), Version: l.Version(), Status: StatusOf(err), Findings: findings, Err: err})
+	}
+	return reports
+}
+
+func supports(langs []string, lang string) bool {
+	for _, l := range langs {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package linter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseDuplPlumbing(t *testing.T) {
+	output := "/tmp/tmp1.go:3-5: duplicate of /tmp/tmp1.go:7-9\n/tmp/tmp1.go:7-9: duplicate of /tmp/tmp1.go:3-5\n"
+	findings, err := parseDuplPlumbing(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{Line: 3, EndLine: 5, Message: "duplicate of lines 7-9"},
+		{Line: 7, EndLine: 9, Message: "duplicate of lines 3-5"},
+	}
+	if len(findings) != len(want) || findings[0] != want[0] || findings[1] != want[1] {
+		t.Errorf("parseDuplPlumbing MUST return %v, but %v given", want, findings)
+	}
+	if _, err := parseDuplPlumbing("found 2 clones:\n"); err == nil {
+		t.Error("parseDuplPlumbing MUST reject unexpected output")
+	}
+}
+
+type slowLinter struct{}
+
+func (slowLinter) Name() string              { return "slow" }
+func (slowLinter) Version() string           { return "1" }
+func (slowLinter) Supports(lang string) bool { return lang == "go" }
+func (slowLinter) Options() []Option         { return nil }
+func (slowLinter) Lint(ctx context.Context, code string, opts map[string]string) ([]Finding, error) {
+	<-ctx.Done()
+	return nil, errors.New("killed")
+}
+
+func TestRegistry_Lint(t *testing.T) {
+	r := NewRegistry(&Dupl{}, slowLinter{})
+	if n := len(r.For("python")); n != 0 {
+		t.Errorf("no linter MUST support python, but %d given", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	reports := NewRegistry(slowLinter{}).Lint(ctx, "go", "package main", nil)
+	if len(reports) != 1 || reports[0].Err != ErrTimeout {
+		t.Errorf("Lint MUST report %v, but %+v given", ErrTimeout, reports)
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	r := NewRegistry(&Dupl{})
+	for _, opts := range []Options{
+		{"golint": {}},
+		{DuplName: {"tokens": "10"}},
+		{DuplName: {DuplThreshold: "0"}},
+		{DuplName: {DuplThreshold: "ten"}},
+	} {
+		if err := r.Validate(opts); err != ErrInvalidOption {
+			t.Errorf("Validate(%v) MUST return %v, but %v given", opts, ErrInvalidOption, err)
+		}
+	}
+	if err := r.Validate(Options{DuplName: {DuplThreshold: "30"}}); err != nil {
+		t.Errorf("Validate MUST accept a threshold, but %v given", err)
+	}
+}
+
+func TestOptions_Merge(t *testing.T) {
+	defaults := Options{DuplName: {DuplThreshold: "30"}, "other": {"a": "1"}}
+	merged := defaults.Merge(Options{DuplName: {DuplThreshold: "50"}})
+	if merged[DuplName][DuplThreshold] != "50" || merged["other"]["a"] != "1" {
+		t.Errorf("Merge MUST override defaults option by option, but %v given", merged)
+	}
+	if defaults[DuplName][DuplThreshold] != "30" {
+		t.Error("Merge MUST NOT modify the defaults")
+	}
+}
+
+func run(t *testing.T, r *Runner, script string) (Output, error) {
+	return r.Run(context.Background(), map[string]string{"in.txt": "input"}, "/bin/sh", "-c", script)
+}
+
+func TestRunner_Run(t *testing.T) {
+	r := &Runner{Timeout: time.Second, MaxOutput: 1024}
+	out, err := run(t, r, `cat in.txt; test "$HOME" = "$(pwd)" && env | sort | tr '\n' ' '`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out.Stdout), "input") {
+		t.Errorf("process MUST run next to its files, but %q given", out.Stdout)
+	}
+	if env := string(out.Stdout); strings.Contains(env, "GOPATH=") || !strings.Contains(env, "LANG=C") {
+		t.Errorf("process MUST get a minimal environment, but %q given", env)
+	}
+
+	for script, want := range map[string]Status{
+		"echo oops >&2; exit 3":              StatusExited,
+		"head -c 4096 /dev/zero":             StatusOutputTooLarge,
+		"kill -9 $$":                         StatusKilled,
+		"sleep 10 & sleep 10 & wait":         StatusTimedOut,
+		"(sleep 10; echo late) & echo early": StatusTimedOut,
+	} {
+		r := &Runner{Timeout: 100 * time.Millisecond, MaxOutput: 1024}
+		start := time.Now()
+		_, err := run(t, r, script)
+		if got := StatusOf(err); got != want {
+			t.Errorf("running %q MUST end with %s, but %s (%v) given", script, want, got, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("running %q MUST be cut off at the timeout, but took %s", script, elapsed)
+		}
+	}
+
+	if _, err := r.Run(context.Background(), nil, "./no/such/linter"); StatusOf(err) != StatusNotStarted {
+		t.Errorf("missing binary MUST end with %s, but %v given", StatusNotStarted, err)
+	}
+}
+
+func Test_binaryDigest(t *testing.T) {
+	d := binaryDigest("/bin/sh")
+	if !strings.HasPrefix(d, "sha256:") || len(d) != len("sha256:")+12 {
+		t.Errorf("binaryDigest MUST return a short digest, but %q given", d)
+	}
+	if d := binaryDigest("./no/such/linter"); d != "" {
+		t.Errorf("binaryDigest of a missing binary MUST be empty, but %q given", d)
+	}
+}
+
+func TestGoAnalyzer_Lint(t *testing.T) {
+	g := &GoAnalyzer{}
+	for code, want := range map[string]Finding{
+		"package main\n\nfunc f() {\n\tx := \n}\n":  {Line: 5, EndLine: 5, Column: 1, Message: "syntax error: expected operand, found '}'"},
+		"func f() {\n\treturn\n\tprintln(1)\n}\n":   {Line: 3, EndLine: 3, Column: 2, Message: "unreachable code"},
+		"func f(s []int) {\n\tappend(s, 1)\n}\n":    {Line: 2, EndLine: 2, Column: 2, Message: "result of append call not used"},
+		"func f() {\n\tfmt.Sprintf(\"%d\", 1)\n}\n": {Line: 2, EndLine: 2, Column: 2, Message: "result of fmt.Sprintf call not used"},
+		"func f() (err error) {\n\tif true {\n\t\t_, err := g()\n\t\t_ = err\n\t}\n\treturn err\n}\n": {
+			Line: 3, EndLine: 3, Column: 6, Message: "declaration of err shadows declaration at line 1",
+		},
+		"func f(a, b bool) {\n\tif a && b {\n\t} else if a || b {\n\t}\n\tfor range []int{} {\n\t}\n}\n": {
+			Line: 1, EndLine: 1, Column: 6, Message: "cyclomatic complexity of f is 6, more than 5",
+		},
+		"x := []int{}\nappend(x, 1)\n":     {Line: 2, EndLine: 2, Column: 1, Message: "result of append call not used"},
+		"fmt.Sprintf(\"%d\", 1)\n":         {Line: 1, EndLine: 1, Column: 1, Message: "result of fmt.Sprintf call not used"},
+		"x := 1\nif x > 0 {\n\tx := \n}\n": {Line: 4, EndLine: 4, Column: 1, Message: "syntax error: expected operand, found '}'"},
+	} {
+		findings, err := g.Lint(context.Background(), code, map[string]string{GoAnalyzerMaxComplexity: "5"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 1 || findings[0] != want {
+			t.Errorf("Lint(%q) MUST find %+v, but %+v given", code, want, findings)
+		}
+	}
+
+	clean := "package main\n\nfunc f() error {\n\tif err := g(); err != nil {\n\t\treturn err\n\t}\n\tpanic(1)\nend:\n\treturn nil\n}\n"
+	if findings, err := g.Lint(context.Background(), clean, nil); err != nil || len(findings) != 0 {
+		t.Errorf("Lint(%q) MUST find nothing, but %+v, %v given", clean, findings, err)
+	}
+	unclosed := "x := 1\nif x > 0 {\n"
+	if findings, err := g.Lint(context.Background(), unclosed, nil); err != nil || len(findings) != 1 || findings[0].Line != 2 {
+		t.Errorf("Lint(%q) MUST find a syntax error on line 2, but %+v, %v given", unclosed, findings, err)
+	}
+	statements := "x := 1\nfmt.Println(x)\n"
+	if findings, err := g.Lint(context.Background(), statements, nil); err != nil || len(findings) != 0 {
+		t.Errorf("Lint(%q) MUST find nothing, but %+v, %v given", statements, findings, err)
+	}
+}
+
+func TestValidator_Lint(t *testing.T) {
+	for _, c := range []struct {
+		v    *Validator
+		code string
+		want Finding
+	}{
+		{JSONValidator(), "{\n  \"a\": [1, 2,]\n}\n", Finding{Line: 2, EndLine: 2, Column: 14, Message: "syntax error: invalid character ']' looking for beginning of value"}},
+		{YAMLValidator(), "a: 1\nb: c: d\n", Finding{Line: 2, EndLine: 2, Message: "syntax error: mapping values are not allowed in this context"}},
+		{XMLValidator(), "<a>\n<b></a>\n", Finding{Line: 2, EndLine: 2, Message: "syntax error: element <b> closed by </a>"}},
+		{XMLValidator(), "\n\n", Finding{Line: 1, EndLine: 2, Message: "syntax error: no root element"}},
+		{TOMLValidator(), "a = 1\nb = = 2\n", Finding{Line: 2, EndLine: 2, Column: 5, Message: "syntax error: expected value but found '=' instead"}},
+	} {
+		findings, err := c.v.Lint(context.Background(), c.code, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 1 || findings[0] != c.want {
+			t.Errorf("%s of %q MUST find %+v, but %+v given", c.v.Name(), c.code, c.want, findings)
+		}
+	}
+	if findings, err := TOMLValidator().Lint(context.Background(), "", nil); err != nil || len(findings) != 0 {
+		t.Errorf("empty TOML MUST be valid, but %+v, %v given", findings, err)
+	}
+}
+
+func TestRegistry_Canonical(t *testing.T) {
+	r := NewRegistry(&GoAnalyzer{}, JSONValidator(), YAMLValidator(), XMLValidator(), TOMLValidator())
+	for _, c := range []struct {
+		lang, code, want string
+	}{
+		{"json", `{"b": 1.50, "a": ["<x>"]}`, "{\n  \"a\": [\n    \"<x>\"\n  ],\n  \"b\": 1.50\n}\n"},
+		{"yaml", "b:\n    - x # why\na: 1\n---\nc: 2\n", "b:\n  - x # why\na: 1\n---\nc: 2\n"},
+		{"xml", "<?xml version=\"1.0\"?><a xmlns:x=\"u\"><x:b k=\"v\">t</x:b>\n<!-- c --><c/></a>", "<?xml version=\"1.0\"?>\n<a xmlns:x=\"u\">\n  <x:b k=\"v\">t</x:b>\n  <!-- c -->\n  <c></c>\n</a>\n"},
+		{"toml", "[t]\nx = \"y\"\n\nb = 1\n", "[t]\n  b = 1\n  x = \"y\"\n"},
+	} {
+		got, err := r.Canonical(c.lang, c.code)
+		if err != nil || got != c.want {
+			t.Errorf("Canonical(%q) MUST return %q, but %q, %v given", c.code, c.want, got, err)
+		}
+	}
+	if _, err := r.Canonical("json", "{"); err != ErrInvalidCode {
+		t.Errorf("Canonical of invalid code MUST return %v, but %v given", ErrInvalidCode, err)
+	}
+	if _, err := r.Canonical("go", "package main"); err != ErrNoCanonicalForm {
+		t.Errorf("Canonical of Go MUST return %v, but %v given", ErrNoCanonicalForm, err)
+	}
+}
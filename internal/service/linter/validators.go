@@ -0,0 +1,337 @@
+package linter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoCanonicalForm = errors.New("language has no canonical form")
+	ErrInvalidCode     = errors.New("code does not parse")
+)
+
+// Canonicalizer is a Linter that can also pretty print the code it accepts.
+type Canonicalizer interface {
+	Linter
+	// Canonical fails with ErrInvalidCode on code Lint finds syntax errors
+	// in.
+	Canonical(code string) (string, error)
+}
+
+// Canonical pretty prints the code with the first linter for the language
+// that can.
+func (r *Registry) Canonical(lang string, code string) (string, error) {
+	for _, l := range r.For(lang) {
+		if c, ok := l.(Canonicalizer); ok {
+			return c.Canonical(code)
+		}
+	}
+	return "", ErrNoCanonicalForm
+}
+
+// syntaxError is where code stops parsing. Line is zero when the parser
+// does not tell, Column when it tells the line only.
+type syntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Validator checks that code of a config language parses, in process, and
+// reports where it does not.
+type Validator struct {
+	lang      string
+	parse     func(code string) *syntaxError
+	canonical func(code string) (string, error)
+}
+
+func JSONValidator() *Validator {
+	return &Validator{lang: "JSON", parse: parseJSON, canonical: CanonicalJSON}
+}
+
+func YAMLValidator() *Validator {
+	return &Validator{lang: "YAML", parse: parseYAML, canonical: canonicalYAML}
+}
+
+func XMLValidator() *Validator {
+	return &Validator{lang: "XML", parse: parseXML, canonical: canonicalXML}
+}
+
+func TOMLValidator() *Validator {
+	return &Validator{lang: "TOML", parse: parseTOML, canonical: canonicalTOML}
+}
+
+// Validators are the validators of every config language there is one for.
+func Validators() []Linter {
+	return []Linter{JSONValidator(), YAMLValidator(), XMLValidator(), TOMLValidator()}
+}
+
+func (v *Validator) Name() string {
+	return strings.ToLower(v.lang) + "-syntax"
+}
+
+// Version is that of the checks, the parsers are pinned by go.mod.
+func (v *Validator) Version() string {
+	return "1"
+}
+
+func (v *Validator) Supports(lang string) bool {
+	return supports([]string{v.lang}, lang)
+}
+
+func (v *Validator) Options() []Option {
+	return nil
+}
+
+func (v *Validator) Lint(ctx context.Context, code string, opts map[string]string) ([]Finding, error) {
+	e := v.parse(code)
+	if e == nil {
+		return nil, nil
+	}
+	f := Finding{Line: e.Line, EndLine: e.Line, Column: e.Column, Message: "syntax error: " + e.Message}
+	if e.Line == 0 {
+		// anywhere, then
+		f.Line, f.EndLine = 1, strings.Count(strings.TrimSuffix(code, "\n"), "\n")+1
+	}
+	return []Finding{f}, nil
+}
+
+func (v *Validator) Canonical(code string) (string, error) {
+	if v.parse(code) != nil {
+		return "", ErrInvalidCode
+	}
+	return v.canonical(code)
+}
+
+// position turns a byte offset into a line and a column, both counted
+// from one.
+func position(code string, offset int) (int, int) {
+	if offset > len(code) {
+		offset = len(code)
+	}
+	before := code[:offset]
+	line := strings.Count(before, "\n") + 1
+	return line, offset - strings.LastIndex(before, "\n")
+}
+
+func parseJSON(code string) *syntaxError {
+	var v interface{}
+	err := json.Unmarshal([]byte(code), &v)
+	if err == nil {
+		return nil
+	}
+	e := &syntaxError{Message: strings.TrimPrefix(err.Error(), "json: ")}
+	if se, ok := err.(*json.SyntaxError); ok {
+		// the offset is past the offending byte
+		offset := int(se.Offset)
+		if offset > 0 && offset <= len(code) {
+			offset--
+		}
+		e.Line, e.Column = position(code, offset)
+	}
+	return e
+}
+
+// CanonicalJSON indents JSON by two spaces, sorts the keys and keeps the
+// numbers as written.
+func CanonicalJSON(code string) (string, error) {
+	d := json.NewDecoder(strings.NewReader(code))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func yamlDocuments(code string) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	d := yaml.NewDecoder(strings.NewReader(code))
+	for {
+		var n yaml.Node
+		err := d.Decode(&n)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &n)
+	}
+}
+
+func parseYAML(code string) *syntaxError {
+	_, err := yamlDocuments(code)
+	if err == nil {
+		return nil
+	}
+	e := &syntaxError{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Message = m[2]
+	}
+	return e
+}
+
+// canonicalYAML indents by two spaces and keeps the comments and the order
+// of the keys.
+func canonicalYAML(code string) (string, error) {
+	docs, err := yamlDocuments(code)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(2)
+	for _, doc := range docs {
+		if err := e.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := e.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func parseXML(code string) *syntaxError {
+	d := xml.NewDecoder(strings.NewReader(code))
+	root := false
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if se, ok := err.(*xml.SyntaxError); ok {
+			return &syntaxError{Line: se.Line, Message: se.Msg}
+		}
+		if err != nil {
+			return &syntaxError{Message: err.Error()}
+		}
+		if _, ok := t.(xml.StartElement); ok {
+			root = true
+		}
+	}
+	if !root {
+		return &syntaxError{Message: "no root element"}
+	}
+	return nil
+}
+
+// rawName keeps the namespace prefix as written, the encoder would declare
+// namespaces of its own otherwise.
+func rawName(n xml.Name) xml.Name {
+	if n.Space == "" {
+		return n
+	}
+	return xml.Name{Local: n.Space + ":" + n.Local}
+}
+
+// canonicalXML indents by two spaces and drops the whitespace between
+// elements.
+func canonicalXML(code string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(code))
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	e.Indent("", "  ")
+	depth, root := 0, false
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			start := xml.StartElement{Name: rawName(tt.Name)}
+			for _, a := range tt.Attr {
+				start.Attr = append(start.Attr, xml.Attr{Name: rawName(a.Name), Value: a.Value})
+			}
+			t = start
+			depth++
+		case xml.EndElement:
+			t = xml.EndElement{Name: rawName(tt.Name)}
+			depth--
+		case xml.CharData:
+			if len(bytes.TrimSpace(tt)) == 0 {
+				continue
+			}
+		case xml.Comment, xml.ProcInst, xml.Directive:
+			// the encoder does not indent these, so they go on lines of
+			// their own here
+			if err := e.Flush(); err != nil {
+				return "", err
+			}
+			if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteString("\n" + strings.Repeat("  ", depth))
+			}
+		}
+		if err := e.EncodeToken(t); err != nil {
+			return "", err
+		}
+		// neither does it break the line before the root element
+		if depth == 0 && !root {
+			if err := e.Flush(); err != nil {
+				return "", err
+			}
+			buf.WriteByte('\n')
+		}
+		if depth > 0 {
+			root = true
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return "", err
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+var tomlErrorPrefix = regexp.MustCompile(`^toml: (line \d+( \(last key .*?\))?: )?`)
+
+func parseTOML(code string) *syntaxError {
+	var v map[string]interface{}
+	_, err := toml.Decode(code, &v)
+	if err == nil {
+		return nil
+	}
+	e := &syntaxError{Message: tomlErrorPrefix.ReplaceAllString(err.Error(), "")}
+	if pe, ok := err.(toml.ParseError); ok {
+		e.Line, e.Column = position(code, pe.Position.Start)
+	}
+	return e
+}
+
+// canonicalTOML sorts the keys and puts the tables last, the comments are
+// lost.
+func canonicalTOML(code string) (string, error) {
+	var v map[string]interface{}
+	if _, err := toml.Decode(code, &v); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,102 @@
+package loginname
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrMixedScripts = errors.New("login mixes letters from different scripts")
+)
+
+var folder = cases.Fold()
+
+// Canonicalize maps every way of writing a login to one form: compatibility
+// characters such as fullwidth letters are decomposed (NFKC), case is folded
+// and letters that look like Latin ones are replaced with them. So "Ｋａｔｙａ"
+// and "KATYA" are both "katya", and "КАТУА" spelled in Cyrillic becomes
+// "katya" too. Logins mixing scripts are rejected as likely spoofing.
+func Canonicalize(login string) (string, error) {
+	s := norm.NFKC.String(login)
+	s = folder.String(s)
+	s = norm.NFKC.String(s)
+	if isMixedScript(s) {
+		return "", ErrMixedScripts
+	}
+	return skeleton(s), nil
+}
+
+var scripts = []*unicode.RangeTable{
+	unicode.Latin,
+	unicode.Cyrillic,
+	unicode.Greek,
+	unicode.Armenian,
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Hangul,
+	unicode.Arabic,
+	unicode.Hebrew,
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for _, t := range scripts {
+		if unicode.Is(t, r) {
+			return t
+		}
+	}
+	return nil
+}
+
+func isMixedScript(s string) bool {
+	var seen *unicode.RangeTable
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		t := scriptOf(r)
+		if t == nil {
+			continue
+		}
+		if seen != nil && seen != t && !isJapanese(seen, t) {
+			return true
+		}
+		seen = t
+	}
+	return false
+}
+
+func isJapanese(a, b *unicode.RangeTable) bool {
+	japanese := func(t *unicode.RangeTable) bool {
+		return t == unicode.Han || t == unicode.Hiragana || t == unicode.Katakana
+	}
+	return japanese(a) && japanese(b)
+}
+
+// confusables maps lower case Cyrillic, Greek and Armenian letters to the
+// Latin letters they are visually indistinguishable from, a subset of the
+// Unicode confusables table (UTS #39).
+var confusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'і': 'i', 'ї': 'i', 'ј': 'j', 'к': 'k',
+	'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x',
+	'ѕ': 's', 'һ': 'h', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'ӏ': 'l', 'ɡ': 'g',
+	'α': 'a', 'β': 'b', 'γ': 'y', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w', 'ϲ': 'c', 'ϳ': 'j',
+	'օ': 'o', 'ս': 'u', 'ց': 'g', 'հ': 'h', 'ո': 'n', 'ռ': 'n', 'ք': 'p',
+}
+
+func skeleton(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package loginname
+
+import (
+	"testing"
+)
+
+func Test_Canonicalize(t *testing.T) {
+	for _, c := range []struct {
+		login     string
+		canonical string
+	}{
+		{"katya", "katya"},
+		{"KATYA", "katya"},
+		{"Ｋａｔｙａ", "katya"},
+		{"КАТУА", "katya"},
+		{"Straße", "strasse"},
+		{"ﬁsh42", "fish42"},
+	} {
+		canonical, err := Canonicalize(c.login)
+		if err != nil {
+			t.Errorf("Canonicalize(%q) MUST succeed, but %v given", c.login, err)
+			continue
+		}
+		if canonical != c.canonical {
+			t.Errorf("Canonicalize(%q) MUST return %q, but %q given", c.login, c.canonical, canonical)
+		}
+	}
+
+	t.Run("mixed scripts are rejected", func(t *testing.T) {
+		if _, err := Canonicalize("kаtyа"); err != ErrMixedScripts {
+			t.Errorf("Canonicalize MUST return ErrMixedScripts, but %v given", err)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File writes every message to its own .eml file in Dir instead of sending
+// it, which is handy for local development.
+type File struct {
+	Dir  string
+	From string
+}
+
+func (f *File) Send(m Message) error {
+	now := time.Now()
+	msg, err := format(f.From, m, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return ioutil.WriteFile(filepath.Join(f.Dir, name), msg, 0600)
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_format(t *testing.T) {
+	t.Run("message with CRLF line endings", func(t *testing.T) {
+		b, err := format("swamp@example.com", Message{To: "toad@example.com", Subject: "Hi", Body: "a\nb"}, time.Unix(0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(b)
+		if !strings.Contains(s, "To: toad@example.com\r\n") || !strings.HasSuffix(s, "\r\n\r\na\r\nb") {
+			t.Errorf("unexpected message %q", s)
+		}
+	})
+	t.Run("header injection is rejected", func(t *testing.T) {
+		_, err := format("swamp@example.com", Message{To: "toad@example.com\r\nBcc: all@example.com"}, time.Unix(0, 0))
+		if err != ErrInvalidHeader {
+			t.Errorf("format MUST return %v, but %v given", ErrInvalidHeader, err)
+		}
+	})
+}
+
+func Test_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := &File{Dir: filepath.Join(dir, "out"), From: "swamp@example.com"}
+	if err := f.Send(Message{To: "toad@example.com", Subject: "Hi", Body: "ribbit"}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(f.Dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("File MUST write one .eml file, but %v (%v) found", files, err)
+	}
+}
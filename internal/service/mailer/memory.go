@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"sync"
+	"time"
+)
+
+type Memory struct {
+	messages []Message
+	mu       *sync.Mutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		mu: &sync.Mutex{},
+	}
+}
+
+func (m *Memory) Send(msg Message) error {
+	if _, err := format("", msg, time.Time{}); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDiscovery      = errors.New("failed to discover provider metadata")
+	ErrExchange       = errors.New("failed to exchange authorization code")
+	ErrInvalidIdToken = errors.New("invalid id token")
+	ErrUnknownKey     = errors.New("id token is signed with an unknown key")
+)
+
+const (
+	maxResponseBytes   = 1 << 20
+	defaultHttpTimeout = 10 * time.Second
+)
+
+type Config struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+}
+
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// Provider is a relying party for one OpenID Connect provider. The provider
+// metadata and keys are fetched on first use and cached, so that an
+// unreachable provider does not keep the server from starting.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]*rsa.PublicKey
+}
+
+func New(c Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHttpTimeout}
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{config: c, client: client}
+}
+
+// NewPkce returns an RFC 7636 code verifier and its S256 challenge.
+func NewPkce() (verifier, challenge string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(b)
+	return verifier, PkceChallenge(verifier), nil
+}
+
+func PkceChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+func (p *Provider) getJson(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
+
+func (p *Provider) discover() (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	var m metadata
+	u := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJson(u, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscovery, err)
+	}
+	if m.Issuer != p.config.Issuer || m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JwksUri == "" {
+		return nil, fmt.Errorf("%w: incomplete metadata from %s", ErrDiscovery, u)
+	}
+	p.metadata = &m
+	return p.metadata, nil
+}
+
+func (p *Provider) AuthCodeUrl(state, nonce, codeChallenge string) (string, error) {
+	m, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientId)
+	v.Set("redirect_uri", p.config.RedirectUrl)
+	v.Set("scope", strings.Join(p.config.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", codeChallenge)
+	v.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+type tokenResponse struct {
+	IdToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// Exchange redeems the authorization code and returns the claims of the
+// verified id token.
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (Claims, error) {
+	m, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectUrl)
+	form.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequest(http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %s", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+	var t tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&t); err != nil {
+		return Claims{}, fmt.Errorf("%w: %s", ErrExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK || t.IdToken == "" {
+		return Claims{}, fmt.Errorf("%w: %s %s", ErrExchange, resp.Status, t.Error)
+	}
+	return p.verify(m, t.IdToken, nonce)
+}
+
+type idTokenClaims struct {
+	Audience          audience `json:"aud"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	jwt.StandardClaims
+}
+
+// audience is either a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = ss
+	return nil
+}
+
+func (p *Provider) verify(m *metadata, idToken, nonce string) (Claims, error) {
+	c := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, c, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(m, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %s", ErrInvalidIdToken, err)
+	}
+	if c.Issuer != m.Issuer {
+		return Claims{}, fmt.Errorf("%w: unexpected issuer %s", ErrInvalidIdToken, c.Issuer)
+	}
+	if !c.hasAudience(p.config.ClientId) {
+		return Claims{}, fmt.Errorf("%w: not issued for this client", ErrInvalidIdToken)
+	}
+	if c.Nonce != nonce || c.Subject == "" || c.ExpiresAt == 0 {
+		return Claims{}, fmt.Errorf("%w: nonce, subject or expiry mismatch", ErrInvalidIdToken)
+	}
+	return Claims{
+		Subject:           c.Subject,
+		Email:             c.Email,
+		EmailVerified:     c.EmailVerified,
+		PreferredUsername: c.PreferredUsername,
+	}, nil
+}
+
+func (c *idTokenClaims) hasAudience(clientId string) bool {
+	for _, a := range c.Audience {
+		if a == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the provider key with the kid, refetching the key set once
+// when the kid is unknown, as providers rotate their keys.
+func (p *Provider) key(m *metadata, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	var jwks token.Jwks
+	if err := p.getJson(m.JwksUri, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if k, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = k
+		}
+	}
+	p.keys = keys
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
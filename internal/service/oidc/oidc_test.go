@@ -0,0 +1,69 @@
+package oidc_test
+
+import (
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc/oidctest"
+	"testing"
+)
+
+func Test_Provider(t *testing.T) {
+	server, err := oidctest.NewServer("code-swamp", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	p := oidc.New(server.Config("http://localhost/callback"), nil)
+
+	authorize := func(t *testing.T) (code, verifier string) {
+		verifier, challenge, err := oidc.NewPkce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := p.AuthCodeUrl("state", "nonce", challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := server.Authorize(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Get("state") != "state" {
+			t.Fatalf("provider MUST return the state, but %q given", q.Get("state"))
+		}
+		return q.Get("code"), verifier
+	}
+
+	t.Run("successful exchange", func(t *testing.T) {
+		code, verifier := authorize(t)
+		c, err := p.Exchange(code, verifier, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Subject != "1" || c.Email != "toad@swamp.org" || !c.EmailVerified {
+			t.Errorf("unexpected claims %+v", c)
+		}
+	})
+	t.Run("failure on wrong code verifier", func(t *testing.T) {
+		code, _ := authorize(t)
+		verifier, _, _ := oidc.NewPkce()
+		if _, err := p.Exchange(code, verifier, "nonce"); !errors.Is(err, oidc.ErrExchange) {
+			t.Errorf("Exchange MUST return %v, but %v given", oidc.ErrExchange, err)
+		}
+	})
+	t.Run("failure on wrong nonce", func(t *testing.T) {
+		code, verifier := authorize(t)
+		if _, err := p.Exchange(code, verifier, "other"); !errors.Is(err, oidc.ErrInvalidIdToken) {
+			t.Errorf("Exchange MUST return %v, but %v given", oidc.ErrInvalidIdToken, err)
+		}
+	})
+	t.Run("failure on reused code", func(t *testing.T) {
+		code, verifier := authorize(t)
+		if _, err := p.Exchange(code, verifier, "nonce"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Exchange(code, verifier, "nonce"); !errors.Is(err, oidc.ErrExchange) {
+			t.Errorf("Exchange MUST return %v, but %v given", oidc.ErrExchange, err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package passhash
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_Verify(t *testing.T) {
+	hasher := &Hasher{
+		Algorithm:  Argon2id,
+		BcryptCost: bcrypt.MinCost,
+		Argon2id:   Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+
+	t.Run("current argon2id hash is up to date", func(t *testing.T) {
+		hash, err := hasher.Hash("kek1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, outdated, err := hasher.Verify(hash, "kek1234")
+		if err != nil || !ok || outdated {
+			t.Errorf("Verify MUST accept up to date hash, but ok=%v outdated=%v err=%v given", ok, outdated, err)
+		}
+		if ok, _, _ := hasher.Verify(hash, "kek12345"); ok {
+			t.Error("Verify MUST reject wrong password")
+		}
+	})
+	t.Run("bcrypt hash is verified and outdated", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("kek1234"), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, outdated, err := hasher.Verify(string(hash), "kek1234")
+		if err != nil || !ok || !outdated {
+			t.Errorf("Verify MUST accept outdated bcrypt hash, but ok=%v outdated=%v err=%v given", ok, outdated, err)
+		}
+	})
+	t.Run("argon2id hash with other parameters is outdated", func(t *testing.T) {
+		stronger := *hasher
+		stronger.Argon2id.Iterations = 2
+		hash, err := hasher.Hash("kek1234")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, outdated, err := stronger.Verify(hash, "kek1234")
+		if err != nil || !ok || !outdated {
+			t.Errorf("Verify MUST report outdated parameters, but ok=%v outdated=%v err=%v given", ok, outdated, err)
+		}
+	})
+}
+
+func Test_HashBcryptTooLong(t *testing.T) {
+	hasher := &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	if _, err := hasher.Hash(string(make([]byte, BcryptMaxBytes+1))); err != ErrPasswordTooLong {
+		t.Errorf("Hash MUST reject passwords bcrypt would cut, but %v given", err)
+	}
+}
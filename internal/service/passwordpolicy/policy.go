@@ -0,0 +1,230 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+type Class string
+
+const (
+	Lower  Class = "lower"
+	Upper  Class = "upper"
+	Digit  Class = "digit"
+	Symbol Class = "symbol"
+	Space  Class = "space"
+	Other  Class = "other"
+)
+
+var Classes = []Class{Lower, Upper, Digit, Symbol, Space, Other}
+
+// poolSizes are rough alphabet sizes used for the entropy estimate.
+var poolSizes = map[Class]float64{
+	Lower:  26,
+	Upper:  26,
+	Digit:  10,
+	Symbol: 33,
+	Space:  1,
+	Other:  100,
+}
+
+var (
+	ErrUnknownClass = errors.New("unknown character class")
+	ErrTooShort     = errors.New("password is too short")
+	ErrTooLong      = errors.New("password is too long")
+	ErrLowEntropy   = errors.New("password is too easy to guess")
+	ErrBreached     = errors.New("password appears in a list of breached passwords")
+)
+
+type ClassNotAllowedError struct {
+	Class Class
+}
+
+func (e *ClassNotAllowedError) Error() string {
+	return fmt.Sprintf("password contains %s characters which are not allowed", e.Class)
+}
+
+type ClassRequiredError struct {
+	Class Class
+}
+
+func (e *ClassRequiredError) Error() string {
+	return fmt.Sprintf("password contains no %s characters", e.Class)
+}
+
+type ValidationError struct {
+	Violations []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes caps the UTF-8 encoded length, for hashes that only read so
+	// many bytes. MinLength and MaxLength count characters.
+	MaxBytes        int
+	AllowedClasses  []Class
+	RequiredClasses []Class
+	MinEntropy      float64
+	Breached        *BreachedList
+}
+
+func Default() *Policy {
+	return &Policy{
+		MinLength:       6,
+		MaxLength:       40,
+		RequiredClasses: []Class{Lower, Upper, Digit},
+	}
+}
+
+func ParseClass(s string) (Class, error) {
+	for _, c := range Classes {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", ErrUnknownClass
+}
+
+func classOf(r rune) Class {
+	switch {
+	case unicode.IsLower(r):
+		return Lower
+	case unicode.IsUpper(r):
+		return Upper
+	case unicode.IsDigit(r):
+		return Digit
+	case unicode.IsSpace(r):
+		return Space
+	case unicode.IsPunct(r) || unicode.IsSymbol(r):
+		return Symbol
+	default:
+		return Other
+	}
+}
+
+// Entropy estimates the password strength in bits as if every character was
+// drawn at random from the union of the classes it uses.
+func Entropy(password string) float64 {
+	used := make(map[Class]bool)
+	n := 0
+	for _, r := range password {
+		used[classOf(r)] = true
+		n++
+	}
+	pool := 0.0
+	for c := range used {
+		pool += poolSizes[c]
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(n) * math.Log2(pool)
+}
+
+// Validate checks the password against every rule and reports all of the
+// violated ones in a *ValidationError.
+func (p *Policy) Validate(password string) error {
+	var violations []error
+	used := make(map[Class]bool)
+	n := 0
+	for _, r := range password {
+		used[classOf(r)] = true
+		n++
+	}
+	if n < p.MinLength {
+		violations = append(violations, ErrTooShort)
+	}
+	if (p.MaxLength > 0 && n > p.MaxLength) || (p.MaxBytes > 0 && len(password) > p.MaxBytes) {
+		violations = append(violations, ErrTooLong)
+	}
+	if len(p.AllowedClasses) != 0 {
+		for _, c := range Classes {
+			if used[c] && !containsClass(p.AllowedClasses, c) {
+				violations = append(violations, &ClassNotAllowedError{Class: c})
+			}
+		}
+	}
+	for _, c := range p.RequiredClasses {
+		if !used[c] {
+			violations = append(violations, &ClassRequiredError{Class: c})
+		}
+	}
+	if p.MinEntropy > 0 && Entropy(password) < p.MinEntropy {
+		violations = append(violations, ErrLowEntropy)
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, ErrBreached)
+	}
+	if len(violations) != 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func containsClass(cs []Class, c Class) bool {
+	for _, x := range cs {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// BreachedList is a set of known breached passwords. Lookups ignore case, so
+// "Password1" is rejected when "password1" is listed.
+type BreachedList struct {
+	passwords map[string]struct{}
+}
+
+func NewBreachedList(passwords []string) *BreachedList {
+	l := &BreachedList{passwords: make(map[string]struct{}, len(passwords))}
+	for _, p := range passwords {
+		l.passwords[strings.ToLower(p)] = struct{}{}
+	}
+	return l
+}
+
+// LoadBreachedList reads one password per line, skipping empty lines and
+// lines starting with '#'.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var passwords []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords = append(passwords, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBreachedList(passwords), nil
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	_, ok := l.passwords[strings.ToLower(password)]
+	return ok
+}
+
+func (l *BreachedList) Len() int {
+	return len(l.passwords)
+}
@@ -0,0 +1,48 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Validate(t *testing.T) {
+	policy := &Policy{
+		MinLength:       8,
+		MaxLength:       64,
+		AllowedClasses:  []Class{Lower, Upper, Digit, Symbol, Space},
+		RequiredClasses: []Class{Lower, Digit},
+		MinEntropy:      40,
+		Breached:        NewBreachedList([]string{"password123"}),
+	}
+
+	t.Run("passphrase with symbols is accepted", func(t *testing.T) {
+		if err := policy.Validate("correct horse, battery staple #42"); err != nil {
+			t.Errorf("Policy MUST accept passphrase, but %v given", err)
+		}
+	})
+	t.Run("every violated rule is reported", func(t *testing.T) {
+		err := policy.Validate("日日日")
+		var v *ValidationError
+		if !errors.As(err, &v) {
+			t.Fatalf("Policy MUST return *ValidationError, but %v given", err)
+		}
+		if len(v.Violations) != 5 {
+			t.Errorf("Policy MUST report 5 violations, but %v given", v.Violations)
+		}
+	})
+	t.Run("breached password is rejected regardless of case", func(t *testing.T) {
+		err := policy.Validate("Password123")
+		var v *ValidationError
+		if !errors.As(err, &v) || len(v.Violations) != 1 || v.Violations[0] != ErrBreached {
+			t.Errorf("Policy MUST reject breached password, but %v given", err)
+		}
+	})
+	t.Run("password longer than MaxBytes is rejected", func(t *testing.T) {
+		bytesPolicy := &Policy{MaxLength: 64, MaxBytes: 72}
+		err := bytesPolicy.Validate("пароль пароль пароль пароль пароль пароль 1")
+		var v *ValidationError
+		if !errors.As(err, &v) || len(v.Violations) != 1 || v.Violations[0] != ErrTooLong {
+			t.Errorf("Policy MUST reject password over MaxBytes, but %v given", err)
+		}
+	})
+}
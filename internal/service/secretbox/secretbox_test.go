@@ -0,0 +1,29 @@
+package secretbox
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_Box(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := box.Open(sealed)
+	if err != nil || string(opened) != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Open MUST return the sealed secret, but %q (%v) given", opened, err)
+	}
+
+	other, _ := New(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Open(sealed); err != ErrInvalidCiphertext {
+		t.Errorf("Open with another key MUST return %v, but %v given", ErrInvalidCiphertext, err)
+	}
+	if _, err := New([]byte("short")); err != ErrInvalidKey {
+		t.Errorf("New MUST return %v, but %v given", ErrInvalidKey, err)
+	}
+}
@@ -3,64 +3,111 @@ package token
 import (
 	"github.com/dgrijalva/jwt-go"
 
-	"crypto/rsa"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
 )
 
+var (
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
 type JwtHandler struct {
-	publicKey  *rsa.PublicKey
-	privateKey *rsa.PrivateKey
+	keys *KeySet
 
-	expire time.Duration
+	expire  time.Duration
+	revoked RevocationList
 }
 
 type Claims struct {
-	Id uint
+	Id  uint
+	Sid string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
-func NewJwt(privateBytes, publicBytes []byte, keyExpiration time.Duration) (*JwtHandler, error) {
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateBytes)
-	if err != nil {
-		return nil, err
+func NewJwt(keys *KeySet, keyExpiration time.Duration, revoked RevocationList) *JwtHandler {
+	return &JwtHandler{
+		keys:    keys,
+		expire:  keyExpiration,
+		revoked: revoked,
 	}
-	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicBytes)
-	if err != nil {
-		return nil, err
+}
+
+func newJti() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return &JwtHandler{
-		publicKey:  publicKey,
-		privateKey: privateKey,
-		expire:     keyExpiration,
-	}, nil
+	return hex.EncodeToString(b), nil
 }
 
-func (j JwtHandler) IssueToken(userId uint) (string, error) {
+func (j JwtHandler) IssueToken(userId uint, sessionId string) (Token, error) {
+	jti, err := newJti()
+	if err != nil {
+		return Token{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(j.expire)
 	claims := Claims{
-		Id: userId,
+		Id:  userId,
+		Sid: sessionId,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(j.expire).Unix(),
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
 		},
 	}
+	key := j.keys.SigningKey()
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(j.privateKey)
+	token.Header["kid"] = key.Id
+	s, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Value: s, Jti: jti, ExpiresAt: expiresAt}, nil
 }
 
-func (j JwtHandler) UserIdByToken(tokenString string) (uint, error) {
+func (j JwtHandler) ParseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, fmt.Errorf("unexpected token signing method")
 		}
-		return j.publicKey, nil
+		kid, _ := token.Header["kid"].(string)
+		key, err := j.keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.PublicKey, nil
 	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return 0, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
+	}
+	if j.revoked != nil {
+		revoked, err := j.revoked.IsAccessTokenRevoked(claims.StandardClaims.Id)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+func (j JwtHandler) UserIdByToken(tokenString string) (uint, error) {
+	claims, err := j.ParseToken(tokenString)
+	if err != nil {
+		return 0, err
 	}
 	return claims.Id, nil
-}
\ No newline at end of file
+}
+
+func (j JwtHandler) Jwks() Jwks {
+	return j.keys.Jwks()
+}
@@ -0,0 +1,416 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	privateKeySuffix = ".rsa"
+	publicKeySuffix  = ".rsa.pub"
+	generatedKeyBits = 2048
+	rotationLockName = ".rotate.lock"
+	staleLockAge     = time.Minute
+	lockPollInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrNoSigningKey = errors.New("no signing key")
+	ErrUnknownKey   = errors.New("unknown key id")
+)
+
+type Key struct {
+	Id         string
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+	CreatedAt  time.Time
+}
+
+type KeySet struct {
+	signing      Key
+	verification map[string]Key
+	mu           *sync.RWMutex
+}
+
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	s := &KeySet{mu: &sync.RWMutex{}}
+	if err := s.Replace(keys); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KeyFromPEM builds a key from PEM encoded RSA keys. The private part is
+// optional, a key without it can only be used for verification. An empty id
+// is replaced with the RFC 7638 thumbprint of the public key.
+func KeyFromPEM(id string, privateBytes, publicBytes []byte) (Key, error) {
+	k := Key{Id: id}
+	if len(privateBytes) != 0 {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateBytes)
+		if err != nil {
+			return Key{}, err
+		}
+		k.PrivateKey = privateKey
+		k.PublicKey = &privateKey.PublicKey
+	}
+	if len(publicBytes) != 0 {
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicBytes)
+		if err != nil {
+			return Key{}, err
+		}
+		if k.PrivateKey != nil && k.PrivateKey.PublicKey.N.Cmp(publicKey.N) != 0 {
+			return Key{}, fmt.Errorf("key %s: public key does not match private key", id)
+		}
+		k.PublicKey = publicKey
+	}
+	if k.PublicKey == nil {
+		return Key{}, fmt.Errorf("key %s: no key material", id)
+	}
+	if k.Id == "" {
+		k.Id = thumbprint(k.PublicKey)
+	}
+	return k, nil
+}
+
+func thumbprint(key *rsa.PublicKey) string {
+	j := jwkFromKey("", key)
+	h := sha256.Sum256([]byte(`{"e":"` + j.E + `","kty":"RSA","n":"` + j.N + `"}`))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// Replace swaps the whole key set. The newest key with a private part
+// becomes the signing key, every key is accepted for verification.
+func (s *KeySet) Replace(keys []Key) error {
+	var signing Key
+	verification := make(map[string]Key, len(keys))
+	for _, k := range keys {
+		verification[k.Id] = k
+		if k.PrivateKey == nil {
+			continue
+		}
+		if signing.PrivateKey == nil || k.CreatedAt.After(signing.CreatedAt) ||
+			(k.CreatedAt.Equal(signing.CreatedAt) && k.Id > signing.Id) {
+			signing = k
+		}
+	}
+	if signing.PrivateKey == nil {
+		return ErrNoSigningKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signing = signing
+	s.verification = verification
+	return nil
+}
+
+func (s *KeySet) SigningKey() Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signing
+}
+
+func (s *KeySet) VerificationKey(kid string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" {
+		return s.signing, nil
+	}
+	k, ok := s.verification[kid]
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+	return k, nil
+}
+
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+func jwkFromKey(kid string, key *rsa.PublicKey) Jwk {
+	return Jwk{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+var ErrInvalidJwk = errors.New("invalid RSA JWK")
+
+func (k Jwk) PublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, ErrInvalidJwk
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, ErrInvalidJwk
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, ErrInvalidJwk
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (s *KeySet) Jwks() Jwks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jwks := Jwks{Keys: make([]Jwk, 0, len(s.verification))}
+	for _, k := range s.verification {
+		jwks.Keys = append(jwks.Keys, jwkFromKey(k.Id, k.PublicKey))
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks
+}
+
+// KeyDirectory keeps key pairs as <kid>.rsa and <kid>.rsa.pub files. A new
+// pair is generated once the newest one is older than RotationPeriod. A
+// retired pair is removed once its successor is older than
+// VerificationPeriod, which must outlive the access tokens it signed, and
+// WatchInterval, for which replicas that have not reloaded yet keep signing
+// with it.
+type KeyDirectory struct {
+	Path               string
+	RotationPeriod     time.Duration
+	VerificationPeriod time.Duration
+	WatchInterval      time.Duration
+}
+
+func (d KeyDirectory) Load() ([]Key, error) {
+	infos, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	privates := make(map[string]os.FileInfo)
+	publics := make(map[string]os.FileInfo)
+	for _, info := range infos {
+		name := info.Name()
+		switch {
+		case strings.HasSuffix(name, publicKeySuffix):
+			publics[strings.TrimSuffix(name, publicKeySuffix)] = info
+		case strings.HasSuffix(name, privateKeySuffix):
+			privates[strings.TrimSuffix(name, privateKeySuffix)] = info
+		}
+	}
+	var keys []Key
+	for kid, info := range publics {
+		publicBytes, err := ioutil.ReadFile(filepath.Join(d.Path, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var privateBytes []byte
+		createdAt := info.ModTime()
+		if privateInfo, ok := privates[kid]; ok {
+			privateBytes, err = ioutil.ReadFile(filepath.Join(d.Path, privateInfo.Name()))
+			if err != nil {
+				return nil, err
+			}
+			createdAt = privateInfo.ModTime()
+		}
+		k, err := KeyFromPEM(kid, privateBytes, publicBytes)
+		if err != nil {
+			return nil, err
+		}
+		k.CreatedAt = createdAt
+		keys = append(keys, k)
+	}
+	for kid, info := range privates {
+		if _, ok := publics[kid]; ok {
+			continue
+		}
+		privateBytes, err := ioutil.ReadFile(filepath.Join(d.Path, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		k, err := KeyFromPEM(kid, privateBytes, nil)
+		if err != nil {
+			return nil, err
+		}
+		k.CreatedAt = info.ModTime()
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func newestSigningKey(keys []Key) time.Time {
+	var newest time.Time
+	for _, k := range keys {
+		if k.PrivateKey != nil && k.CreatedAt.After(newest) {
+			newest = k.CreatedAt
+		}
+	}
+	return newest
+}
+
+func (d KeyDirectory) Rotate() error {
+	keys, err := d.Load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if newest := newestSigningKey(keys); newest.IsZero() || now.Sub(newest) >= d.RotationPeriod {
+		locked, err := d.lock()
+		for err == nil && !locked && newest.IsZero() {
+			// nothing to sign with until the replica holding the lock is done
+			time.Sleep(lockPollInterval)
+			if keys, err = d.Load(); err != nil {
+				return err
+			}
+			if newest = newestSigningKey(keys); newest.IsZero() {
+				locked, err = d.lock()
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if locked {
+			defer d.unlock()
+			// another replica may have generated it just now
+			if keys, err = d.Load(); err != nil {
+				return err
+			}
+			if newest := newestSigningKey(keys); newest.IsZero() || now.Sub(newest) >= d.RotationPeriod {
+				k, err := d.generate(now)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	for i := 0; i+1 < len(keys); i++ {
+		k, successor := keys[i], keys[i+1].CreatedAt
+		if now.Sub(successor) < d.VerificationPeriod+d.WatchInterval {
+			continue
+		}
+		for _, suffix := range []string{privateKeySuffix, publicKeySuffix} {
+			err := os.Remove(filepath.Join(d.Path, k.Id+suffix))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lock makes sure a single replica generates the next key. A lock older
+// than staleLockAge was left by a replica that died holding it.
+func (d KeyDirectory) lock() (bool, error) {
+	path := filepath.Join(d.Path, rotationLockName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		info, statErr := os.Stat(path)
+		if statErr != nil || time.Since(info.ModTime()) < staleLockAge {
+			return false, nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if os.IsExist(err) {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+func (d KeyDirectory) unlock() {
+	os.Remove(filepath.Join(d.Path, rotationLockName))
+}
+
+func (d KeyDirectory) generate(now time.Time) (Key, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, generatedKeyBits)
+	if err != nil {
+		return Key{}, err
+	}
+	publicBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return Key{}, err
+	}
+	kid := fmt.Sprintf("%d-%s", now.Unix(), thumbprint(&privateKey.PublicKey)[:8])
+	err = ioutil.WriteFile(filepath.Join(d.Path, kid+publicKeySuffix),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0644)
+	if err != nil {
+		return Key{}, err
+	}
+	err = ioutil.WriteFile(filepath.Join(d.Path, kid+privateKeySuffix),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}), 0600)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Id: kid, PrivateKey: privateKey, PublicKey: &privateKey.PublicKey, CreatedAt: now}, nil
+}
+
+// Watch rotates the directory and reloads the key set every WatchInterval
+// until stop is closed. Errors are reported to onError and the previous keys
+// stay in use.
+func (s *KeySet) Watch(d KeyDirectory, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(d.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.reload(d); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *KeySet) reload(d KeyDirectory) error {
+	if err := d.Rotate(); err != nil {
+		return err
+	}
+	keys, err := d.Load()
+	if err != nil {
+		return err
+	}
+	return s.Replace(keys)
+}
+
+func LoadKeySet(d KeyDirectory) (*KeySet, error) {
+	s := &KeySet{mu: &sync.RWMutex{}}
+	if err := s.reload(d); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
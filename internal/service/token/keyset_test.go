@@ -0,0 +1,158 @@
+package token
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func keyDirectory(t *testing.T) KeyDirectory {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return KeyDirectory{
+		Path:               dir,
+		RotationPeriod:     time.Hour,
+		VerificationPeriod: 10 * time.Minute,
+		WatchInterval:      time.Minute,
+	}
+}
+
+// age makes the key files look created that long ago.
+func age(t *testing.T, d KeyDirectory, kid string, by time.Duration) {
+	at := time.Now().Add(-by)
+	for _, suffix := range []string{privateKeySuffix, publicKeySuffix} {
+		if err := os.Chtimes(filepath.Join(d.Path, kid+suffix), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func load(t *testing.T, d KeyDirectory) []Key {
+	keys, err := d.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestKeyDirectory_Rotate(t *testing.T) {
+	d := keyDirectory(t)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	keys := load(t, d)
+	if len(keys) != 1 || keys[0].PrivateKey == nil {
+		t.Fatalf("Rotate MUST generate a signing key in an empty directory, but %d keys given", len(keys))
+	}
+	first := keys[0].Id
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := load(t, d); len(keys) != 1 {
+		t.Errorf("Rotate MUST NOT generate a key before RotationPeriod, but %d keys given", len(keys))
+	}
+
+	age(t, d, first, d.RotationPeriod)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	keys = load(t, d)
+	if len(keys) != 2 {
+		t.Fatalf("Rotate MUST generate a key after RotationPeriod and keep the old one, but %d keys given", len(keys))
+	}
+	s, err := NewKeySet(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SigningKey().Id == first {
+		t.Error("the new key MUST sign")
+	}
+	if _, err := s.VerificationKey(first); err != nil {
+		t.Errorf("the old key MUST verify, but %v given", err)
+	}
+
+	// replicas that have not reloaded may still sign with the old key
+	second := s.SigningKey().Id
+	age(t, d, second, d.VerificationPeriod+d.WatchInterval/2)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := load(t, d); len(keys) != 2 {
+		t.Errorf("Rotate MUST keep the old key within the watch interval, but %d keys given", len(keys))
+	}
+	age(t, d, second, d.VerificationPeriod+d.WatchInterval)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := load(t, d); len(keys) != 1 || keys[0].Id != second {
+		t.Errorf("Rotate MUST retire the old key, but %v given", keys)
+	}
+}
+
+func TestKeyDirectory_RotateLocked(t *testing.T) {
+	d := keyDirectory(t)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	kid := load(t, d)[0].Id
+	age(t, d, kid, d.RotationPeriod)
+	locked, err := d.lock()
+	if err != nil || !locked {
+		t.Fatalf("lock MUST succeed, but %v, %v given", locked, err)
+	}
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := load(t, d); len(keys) != 1 {
+		t.Errorf("Rotate MUST leave generating to the replica holding the lock, but %d keys given", len(keys))
+	}
+
+	stale := time.Now().Add(-staleLockAge)
+	if err := os.Chtimes(filepath.Join(d.Path, rotationLockName), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := load(t, d); len(keys) != 2 {
+		t.Errorf("Rotate MUST take over a stale lock, but %d keys given", len(keys))
+	}
+	if _, err := os.Stat(filepath.Join(d.Path, rotationLockName)); !os.IsNotExist(err) {
+		t.Errorf("Rotate MUST release the lock, but %v given", err)
+	}
+}
+
+func TestKeyDirectory_Load(t *testing.T) {
+	d := keyDirectory(t)
+	if err := d.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	kid := load(t, d)[0].Id
+	if err := os.Rename(filepath.Join(d.Path, kid+privateKeySuffix), filepath.Join(d.Path, "other"+privateKeySuffix)); err != nil {
+		t.Fatal(err)
+	}
+	keys := load(t, d)
+	if len(keys) != 2 {
+		t.Fatalf("Load MUST load unpaired keys, but %d keys given", len(keys))
+	}
+	for _, k := range keys {
+		if k.Id == kid && k.PrivateKey != nil {
+			t.Error("public key alone MUST only verify")
+		}
+		if k.Id == "other" && k.PrivateKey == nil {
+			t.Error("private key alone MUST sign")
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(d.Path, "broken"+publicKeySuffix), []byte("nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Load(); err == nil {
+		t.Error("Load MUST reject broken keys")
+	}
+}
@@ -1,6 +1,20 @@
 package token
 
+import "time"
+
+type Token struct {
+	Value     string
+	Jti       string
+	ExpiresAt time.Time
+}
+
+type RevocationList interface {
+	IsAccessTokenRevoked(jti string) (bool, error)
+}
+
 type Interface interface {
-	IssueToken(userId uint) (string, error)
+	IssueToken(userId uint, sessionId string) (Token, error)
+	ParseToken(token string) (*Claims, error)
 	UserIdByToken(token string) (uint, error)
-}
\ No newline at end of file
+	Jwks() Jwks
+}
@@ -0,0 +1,48 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238 appendix B, truncated to six digits.
+func Test_Code(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		c, err := Code(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != expected {
+			t.Errorf("Code at %d MUST be %s, but %s given", unix, expected, c)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c, _ := Code(secret, now.Add(-Period))
+
+	if _, ok, _ := Validate(secret, c, now, 1); !ok {
+		t.Error("Validate MUST accept the previous code within skew")
+	}
+	if _, ok, _ := Validate(secret, c, now, 0); ok {
+		t.Error("Validate MUST reject the previous code without skew")
+	}
+	if _, ok, _ := Validate(secret, "12345", now, 1); ok {
+		t.Error("Validate MUST reject codes of wrong length")
+	}
+}
@@ -0,0 +1,49 @@
+package webhooksig
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	SignatureHeader = "X-Swamp-Signature"
+	EventHeader     = "X-Swamp-Event"
+	DeliveryHeader  = "X-Swamp-Delivery"
+
+	prefix = "sha256="
+)
+
+// NewSecret returns a random hex encoded signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of the signature header for the payload, an
+// HMAC-SHA256 of the raw body keyed with the webhook secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header the way a receiver should, in constant
+// time.
+func Verify(secret string, payload []byte, signature string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(given, mac.Sum(nil))
+}
@@ -0,0 +1,21 @@
+package webhooksig
+
+import "testing"
+
+func Test_Sign(t *testing.T) {
+	// RFC 4231 test case 2
+	sig := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if sig != want {
+		t.Errorf("Sign MUST return %s, but %s given", want, sig)
+	}
+	if !Verify("Jefe", []byte("what do ya want for nothing?"), sig) {
+		t.Error("Verify MUST accept its own signature")
+	}
+	if Verify("Jefe", []byte("what do ya want for something?"), sig) {
+		t.Error("Verify MUST reject a signature of another payload")
+	}
+	if Verify("Jefe", []byte("what do ya want for nothing?"), sig[len("sha256="):]) {
+		t.Error("Verify MUST reject a signature without the prefix")
+	}
+}
@@ -4,69 +4,158 @@ import (
 	"errors"
 	"fmt"
 	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/apitoken"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/challenge"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/emailtoken"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/identity"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/oidcrequest"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/session"
+	"github.com/mp-hl-2021/code-swamp/internal/service/loginname"
+	"github.com/mp-hl-2021/code-swamp/internal/service/mailer"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passhash"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passwordpolicy"
 	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrInvalidLoginString    = errors.New("login string contains invalid character")
-	ErrInvalidLoginString2   = errors.New("login string should start with a letter")
-	ErrInvalidPasswordString = errors.New("password string contains invalid character")
-	ErrTooShortString        = errors.New("too short string")
-	ErrTooLongString         = errors.New("too long string")
-	ErrNoDigits              = errors.New("password string contains no digits")
-	ErrNoUpperCaseLetters    = errors.New("password string contains no upper case letters")
-	ErrNoLowerCaseLetters    = errors.New("password string contains no lower case letters")
-
-	ErrInvalidLogin    = errors.New("login not found")
-	ErrInvalidPassword = errors.New("invalid password")
+	ErrInvalidLoginString  = errors.New("login string contains invalid character")
+	ErrInvalidLoginString2 = errors.New("login string should start with a letter")
+	ErrConfusableLogin     = errors.New("login mixes letters from different scripts")
+	ErrTooShortString      = errors.New("too short string")
+	ErrTooLongString       = errors.New("too long string")
+
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidPassword    = errors.New("invalid password")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
 
 	ErrInvalidLanguage = errors.New("language is invalid")
 )
 
 const (
-	minLoginLength    = 6
-	maxLoginLength    = 30
-	minPasswordLength = 6
-	maxPasswordLength = 40
+	minLoginLength = 6
+	maxLoginLength = 30
+
+	// maxPasswordBytes only guards the hashing at sign in, the actual limits
+	// come from the password policy.
+	maxPasswordBytes = 1024
 )
 
 type Account struct {
-	Id uint
+	Id            uint
+	Login         string
+	Email         string
+	EmailVerified bool
+	TotpEnabled   bool
 }
 
 type Interface interface {
 	CreateAccount(login, password string) (Account, error)
-	LoginToAccount(login, password string) (string, error)
+	LoginToAccount(login, password, device, ip string) (Tokens, error)
+	RefreshTokens(refreshToken string) (Tokens, error)
+	Logout(token string) error
+
+	GetSessions(aid uint) ([]Session, error)
+	RevokeSession(aid uint, sid string) error
+
+	ChangePassword(aid uint, currentPassword, newPassword string) error
+	DeleteAccount(aid uint, deleteSnippets bool) error
+
+	SetEmail(aid uint, email string) error
+	VerifyEmail(token string) error
+	RequestPasswordReset(email string) error
+	ResetPassword(token, newPassword string) error
+
+	CompleteSignin(challenge, code, ip string) (Tokens, error)
+	EnrollTotp(aid uint) (TotpEnrollment, error)
+	ConfirmTotp(aid uint, code, ip string) ([]string, error)
+	RegenerateRecoveryCodes(aid uint, code, ip string) ([]string, error)
+	DisableTotp(aid uint, code, ip string) error
+
+	StartOidcLogin(provider string, linkAid *uint, device string) (OidcStart, error)
+	FinishOidcLogin(provider, state, code string) (OidcResult, error)
+	GetIdentities(aid uint) ([]LinkedIdentity, error)
+	UnlinkIdentity(aid uint, provider string) error
+
+	CreateApiToken(aid uint, name string, scopes []string) (string, ApiToken, error)
+	GetApiTokens(aid uint) ([]ApiToken, error)
+	RevokeApiToken(aid, id uint) error
 
 	GetAccountById(id uint) (Account, error)
-	Authenticate(token string) (uint, error)
+	GetAccountByLogin(login string) (Account, error)
+	Authenticate(token string) (Identity, error)
+	GetJwks() token.Jwks
 }
 
 type UseCases struct {
-	Auth               token.Interface
-	AccountStorage     account.Interface
+	Auth                 token.Interface
+	AccountStorage       account.Interface
+	SessionStorage       session.Interface
+	ApiTokenStorage      apitoken.Interface
+	EmailTokenStorage    emailtoken.Interface
+	Mailer               mailer.Interface
+	Email                EmailSettings
+	ChallengeStorage     challenge.Interface
+	TwoFactor            TwoFactorSettings
+	IdentityStorage      identity.Interface
+	OidcRequestStorage   oidcrequest.Interface
+	Oidc                 OidcSettings
+	LoginThrottling      *LoginThrottling
+	PasswordPolicy       *passwordpolicy.Policy
+	PasswordHasher       *passhash.Hasher
+	RefreshTokenLifetime time.Duration
+
+	dummyPasswordHashOnce sync.Once
+	dummyPasswordHash     string
+}
+
+func (u *UseCases) passwordHasher() *passhash.Hasher {
+	if u.PasswordHasher == nil {
+		return passhash.Default()
+	}
+	return u.PasswordHasher
+}
+
+// dummyHash is verified against when the login is unknown, so that an
+// unknown login takes as long to reject as a wrong password.
+func (u *UseCases) dummyHash() string {
+	u.dummyPasswordHashOnce.Do(func() {
+		u.dummyPasswordHash, _ = u.passwordHasher().Hash("dummy password")
+	})
+	return u.dummyPasswordHash
+}
+
+func (u *UseCases) passwordPolicy() *passwordpolicy.Policy {
+	if u.PasswordPolicy == nil {
+		return passwordpolicy.Default()
+	}
+	return u.PasswordPolicy
 }
 
 func (u *UseCases) CreateAccount(login, password string) (Account, error) {
-	fmt.Printf("Register: %s %s\n", login, password)
-	if err := validateLogin(login); err != nil {
+	fmt.Printf("Register: %s\n", login)
+	canonicalLogin, err := canonicalizeLogin(login)
+	if err != nil {
 		return Account{}, err
 	}
-	if err := validatePassword(password); err != nil {
+	if err := u.passwordPolicy().Validate(password); err != nil {
 		return Account{}, err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := u.passwordHasher().Hash(password)
 	if err != nil {
 		return Account{}, err
 	}
 
 	acc, err := u.AccountStorage.CreateAccount(account.Credentials{
-		Login:    login,
-		Password: string(hashedPassword),
+		Login:          login,
+		CanonicalLogin: canonicalLogin,
+		Password:       hashedPassword,
 	})
 	if err != nil {
 		return Account{}, err
@@ -74,25 +163,112 @@ func (u *UseCases) CreateAccount(login, password string) (Account, error) {
 	return Account{Id: acc.Id}, nil
 }
 
-func (u *UseCases) LoginToAccount(login, password string) (string, error) {
-	fmt.Printf("Login: %s %s\n", login, password)
-	if err := validateLogin(login); err != nil {
-		return "", err
+func (u *UseCases) LoginToAccount(login, password, device, ip string) (Tokens, error) {
+	fmt.Printf("Login: %s\n", login)
+	login, err := canonicalizeLogin(login)
+	if err != nil {
+		return Tokens{}, err
 	}
-	if err := validatePassword(password); err != nil {
-		return "", err
+	if password == "" || len(password) > maxPasswordBytes {
+		return Tokens{}, ErrInvalidCredentials
+	}
+	if err := u.LoginThrottling.check(login, ip); err != nil {
+		return Tokens{}, err
 	}
 	acc, err := u.AccountStorage.GetAccountByLogin(login)
+	if err != nil && err != account.ErrNotFound {
+		return Tokens{}, err
+	}
+	// accounts created through an identity provider have no password
+	hash := acc.Credentials.Password
+	known := err == nil && hash != ""
+	if !known {
+		hash = u.dummyHash()
+	}
+	ok, outdated, err := u.passwordHasher().Verify(hash, password)
 	if err != nil {
-		return "", ErrInvalidLogin
+		return Tokens{}, err
+	}
+	if !ok || !known {
+		if err := u.LoginThrottling.fail(login, ip); err != nil {
+			return Tokens{}, err
+		}
+		return Tokens{}, ErrInvalidCredentials
+	}
+	if outdated {
+		u.rehashPassword(acc.Id, password)
+	}
+	// the failures are only forgotten once the second factor is right too
+	if acc.TotpEnabled {
+		return Tokens{}, u.startChallenge(acc.Id, device)
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(acc.Credentials.Password), []byte(password)); err != nil {
-		return "", ErrInvalidPassword
+	if err := u.LoginThrottling.succeed(login); err != nil {
+		return Tokens{}, err
 	}
 
-	token, err := u.Auth.IssueToken(acc.Id)
+	return u.startSession(acc.Id, device)
+}
+
+// rehashPassword stores the password hashed with the current parameters. A
+// failure is not worth failing the sign in for, the next one retries.
+func (u *UseCases) rehashPassword(aid uint, password string) {
+	hashedPassword, err := u.passwordHasher().Hash(password)
+	if err == nil {
+		err = u.AccountStorage.UpdatePassword(aid, hashedPassword)
+	}
+	if err != nil {
+		fmt.Printf("Failed to rehash password of %d: %s\n", aid, err)
+	}
+}
 
-	return token, err
+func (u *UseCases) ChangePassword(aid uint, currentPassword, newPassword string) error {
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return err
+	}
+	// an account without a password may set its first one
+	if acc.Credentials.Password != "" {
+		ok, _, err := u.passwordHasher().Verify(acc.Credentials.Password, currentPassword)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidPassword
+		}
+	}
+	if err := u.passwordPolicy().Validate(newPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := u.passwordHasher().Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := u.AccountStorage.UpdatePassword(aid, hashedPassword); err != nil {
+		return err
+	}
+	return u.revokeAllSessions(aid)
+}
+
+// DeleteAccount signs the account out only once the storage agreed to delete
+// it, it refuses while the account is the only owner of a team.
+func (u *UseCases) DeleteAccount(aid uint, deleteSnippets bool) error {
+	// the sessions go with the account, their access tokens are revoked
+	// after
+	ss, err := u.SessionStorage.GetSessionsByUser(aid)
+	if err != nil {
+		return err
+	}
+	policy := account.AnonymiseSnippets
+	if deleteSnippets {
+		policy = account.DeleteSnippets
+	}
+	if err := u.AccountStorage.DeleteAccount(aid, policy); err != nil {
+		return err
+	}
+	if err := u.revokeSessions(ss); err != nil {
+		return err
+	}
+	return u.ApiTokenStorage.DeleteApiTokensByUser(aid)
 }
 
 func (a *UseCases) GetAccountById(id uint) (Account, error) {
@@ -100,15 +276,65 @@ func (a *UseCases) GetAccountById(id uint) (Account, error) {
 	if err != nil {
 		return Account{}, err
 	}
-	return Account{Id: acc.Id}, err
+	return toAccount(acc), nil
+}
+
+func toAccount(acc account.Account) Account {
+	return Account{
+		Id:            acc.Id,
+		Login:         acc.Login,
+		Email:         acc.Email,
+		EmailVerified: acc.EmailVerified,
+		TotpEnabled:   acc.TotpEnabled,
+	}
+}
+
+func (a *UseCases) GetAccountByLogin(login string) (Account, error) {
+	canonicalLogin, err := canonicalizeLogin(login)
+	if err != nil {
+		return Account{}, account.ErrNotFound
+	}
+	acc, err := a.AccountStorage.GetAccountByLogin(canonicalLogin)
+	if err != nil {
+		return Account{}, err
+	}
+	return toAccount(acc), nil
 }
 
-func (a *UseCases) Authenticate(token string) (uint, error) {
-	return a.Auth.UserIdByToken(token)
+func (a *UseCases) Authenticate(token string) (Identity, error) {
+	if strings.HasPrefix(token, apiTokenPrefix) {
+		return a.authenticateApiToken(token)
+	}
+	id, err := a.Auth.UserIdByToken(token)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Id: id}, nil
+}
+
+func (a *UseCases) GetJwks() token.Jwks {
+	return a.Auth.Jwks()
+}
+
+func canonicalizeLogin(login string) (string, error) {
+	if err := validateLogin(login); err != nil {
+		return "", err
+	}
+	canonicalLogin, err := loginname.Canonicalize(login)
+	if err != nil {
+		return "", ErrConfusableLogin
+	}
+	if err := validateLogin(canonicalLogin); err != nil {
+		return "", err
+	}
+	return canonicalLogin, nil
 }
 
 func validateLogin(login string) error {
 	chars := 0
+	if login == "" {
+		return ErrTooShortString
+	}
 	if !unicode.IsLetter([]rune(login)[0]) {
 		return ErrInvalidLoginString2
 	}
@@ -126,44 +352,3 @@ func validateLogin(login string) error {
 	}
 	return nil
 }
-
-func validatePassword(password string) error {
-	chars := 0
-	lower := 0
-	upper := 0
-	digit := 0
-	for _, r := range password {
-		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
-			return ErrInvalidPasswordString
-		}
-		if unicode.IsLower(r) {
-			lower = 1
-		}
-
-		if unicode.IsUpper(r) {
-			upper = 1
-		}
-
-		if unicode.IsDigit(r) {
-			digit = 1
-		}
-
-		chars++
-	}
-	if chars < minPasswordLength {
-		return ErrTooShortString
-	}
-	if chars > maxPasswordLength {
-		return ErrTooLongString
-	}
-	if lower == 0 {
-		return ErrNoLowerCaseLetters
-	}
-	if upper == 0 {
-		return ErrNoUpperCaseLetters
-	}
-	if digit == 0 {
-		return ErrNoDigits
-	}
-	return nil
-}
@@ -0,0 +1,130 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/accountrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/apitokenrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/sessionrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/passhash"
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"testing"
+	"time"
+)
+
+const (
+	testLogin    = "froggy"
+	testPassword = "Correct horse battery 1"
+)
+
+func newUseCases(t *testing.T, snippets *codesnippetrepo.Memory) *UseCases {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := token.NewKeySet(token.Key{Id: "test", PrivateKey: privateKey, PublicKey: &privateKey.PublicKey, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := sessionrepo.NewMemory()
+	return &UseCases{
+		Auth:                 token.NewJwt(keys, time.Minute, sessions),
+		AccountStorage:       accountrepo.NewMemory(snippets, nil),
+		SessionStorage:       sessions,
+		ApiTokenStorage:      apitokenrepo.NewMemory(),
+		PasswordHasher:       &passhash.Hasher{Algorithm: passhash.Bcrypt, BcryptCost: 4},
+		RefreshTokenLifetime: time.Hour,
+	}
+}
+
+func createAccount(t *testing.T, u *UseCases) uint {
+	acc, err := u.CreateAccount(testLogin, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acc.Id
+}
+
+func login(t *testing.T, u *UseCases, password string) Tokens {
+	tokens, err := u.LoginToAccount(testLogin, password, "Firefox", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// assertSignedOut checks that neither token of the session works anymore.
+func assertSignedOut(t *testing.T, u *UseCases, tokens Tokens) {
+	t.Helper()
+	if _, err := u.Authenticate(tokens.AccessToken); err == nil {
+		t.Error("access token MUST be revoked")
+	}
+	if _, err := u.RefreshTokens(tokens.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("refresh token MUST be rejected, but %v given", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	u := newUseCases(t, nil)
+	aid := createAccount(t, u)
+	first, second := login(t, u, testPassword), login(t, u, testPassword)
+
+	if err := u.ChangePassword(aid, "wrong password", "New horse battery 2"); err != ErrInvalidPassword {
+		t.Errorf("ChangePassword MUST return %v on a wrong password, but %v given", ErrInvalidPassword, err)
+	}
+	if _, err := u.Authenticate(first.AccessToken); err != nil {
+		t.Errorf("a failed change MUST keep the sessions, but %v given", err)
+	}
+	if err := u.ChangePassword(aid, testPassword, "New horse battery 2"); err != nil {
+		t.Fatal(err)
+	}
+	assertSignedOut(t, u, first)
+	assertSignedOut(t, u, second)
+	if ss, err := u.GetSessions(aid); err != nil || len(ss) != 0 {
+		t.Errorf("ChangePassword MUST end every session, but %v, %v given", ss, err)
+	}
+	if _, err := u.LoginToAccount(testLogin, testPassword, "", ""); err != ErrInvalidCredentials {
+		t.Errorf("the old password MUST be rejected, but %v given", err)
+	}
+	login(t, u, "New horse battery 2")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	for _, deleteSnippets := range []bool{true, false} {
+		snippets := codesnippetrepo.NewMemory()
+		u := newUseCases(t, snippets)
+		aid := createAccount(t, u)
+		sid, err := snippets.CreateCodeSnippetWithUser(codesnippet.CodeSnippet{Code: "x := 1", Lang: "Go", Lifetime: time.Hour}, aid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apiToken, _, err := u.CreateApiToken(aid, "ci", []string{ScopeSnippetsRead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens := login(t, u, testPassword)
+
+		if err := u.DeleteAccount(aid, deleteSnippets); err != nil {
+			t.Fatal(err)
+		}
+		assertSignedOut(t, u, tokens)
+		if _, err := u.Authenticate(apiToken); err != ErrApiTokenNotFound {
+			t.Errorf("api token MUST be deleted, but %v given", err)
+		}
+		if _, err := u.GetAccountById(aid); err == nil {
+			t.Error("account MUST be deleted")
+		}
+		_, err = snippets.GetCodeSnippetById(sid)
+		if deleteSnippets && err == nil {
+			t.Error("snippet MUST be deleted with the account")
+		}
+		if !deleteSnippets && err != nil {
+			t.Errorf("snippet MUST be kept anonymised, but %v given", err)
+		}
+		if ids, _ := snippets.GetMyCodeSnippetIds(aid, 10, 0); len(ids) != 0 {
+			t.Errorf("no snippet MUST stay owned by the account, but %v given", ids)
+		}
+	}
+}
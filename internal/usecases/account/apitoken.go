@@ -0,0 +1,162 @@
+package account
+
+import (
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/apitoken"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ScopeSnippetsRead   = "snippets:read"
+	ScopeSnippetsWrite  = "snippets:write"
+	ScopeSnippetsDelete = "snippets:delete"
+
+	apiTokenPrefix        = "csp_"
+	maxApiTokenNameLength = 255
+)
+
+var Scopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite, ScopeSnippetsDelete}
+
+var (
+	ErrInvalidScope        = errors.New("unknown scope")
+	ErrNoScopes            = errors.New("at least one scope is required")
+	ErrInvalidApiTokenName = errors.New("api token name should be 1 to 255 characters long")
+	ErrApiTokenNotFound    = errors.New("api token not found")
+)
+
+// Identity is the caller behind a token. Session tokens carry no scopes and
+// may do anything, personal api tokens only what their scopes allow.
+type Identity struct {
+	Id     uint
+	Scopes []string
+}
+
+func (i Identity) IsSession() bool {
+	return i.Scopes == nil
+}
+
+func (i Identity) HasScope(scope string) bool {
+	if i.IsSession() {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type ApiToken struct {
+	Id         uint
+	Name       string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+	seen := make(map[string]bool, len(scopes))
+	var valid []string
+	for _, s := range scopes {
+		known := false
+		for _, k := range Scopes {
+			if s == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, ErrInvalidScope
+		}
+		if !seen[s] {
+			seen[s] = true
+			valid = append(valid, s)
+		}
+	}
+	return valid, nil
+}
+
+func toApiToken(t apitoken.ApiToken) ApiToken {
+	return ApiToken{
+		Id:         t.Id,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func (u *UseCases) CreateApiToken(aid uint, name string, scopes []string) (string, ApiToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxApiTokenNameLength {
+		return "", ApiToken{}, ErrInvalidApiTokenName
+	}
+	scopes, err := validateScopes(scopes)
+	if err != nil {
+		return "", ApiToken{}, err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return "", ApiToken{}, err
+	}
+	plain := apiTokenPrefix + secret
+	now := time.Now()
+	t, err := u.ApiTokenStorage.CreateApiToken(apitoken.ApiToken{
+		Uid:        aid,
+		Name:       name,
+		Hash:       hashToken(plain),
+		Scopes:     scopes,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+	if err != nil {
+		return "", ApiToken{}, err
+	}
+	return plain, toApiToken(t), nil
+}
+
+func (u *UseCases) GetApiTokens(aid uint) ([]ApiToken, error) {
+	ts, err := u.ApiTokenStorage.GetApiTokensByUser(aid)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]ApiToken, len(ts))
+	for i, t := range ts {
+		tokens[i] = toApiToken(t)
+	}
+	return tokens, nil
+}
+
+func (u *UseCases) RevokeApiToken(aid, id uint) error {
+	if err := u.ApiTokenStorage.DeleteApiToken(aid, id); err != nil {
+		if err == apitoken.ErrNotFound {
+			return ErrApiTokenNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *UseCases) authenticateApiToken(plain string) (Identity, error) {
+	t, err := u.ApiTokenStorage.GetApiTokenByHash(hashToken(plain))
+	if err != nil {
+		if err == apitoken.ErrNotFound {
+			return Identity{}, ErrApiTokenNotFound
+		}
+		return Identity{}, err
+	}
+	if err := u.ApiTokenStorage.TouchApiToken(t.Id, time.Now()); err != nil {
+		return Identity{}, err
+	}
+	scopes := t.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return Identity{Id: t.Uid, Scopes: scopes}, nil
+}
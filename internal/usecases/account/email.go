@@ -0,0 +1,182 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/emailtoken"
+	"github.com/mp-hl-2021/code-swamp/internal/service/mailer"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrInvalidEmailToken = errors.New("invalid or expired email token")
+	ErrEmailTaken        = errors.New("email address is already verified by another account")
+	ErrMailerUnavailable = errors.New("mailer is not configured")
+)
+
+const maxEmailLength = 254
+
+type EmailSettings struct {
+	// BaseUrl is where the links in the mails point to, e.g. the web
+	// frontend, which passes the token on to the API.
+	BaseUrl                   string
+	VerificationTokenLifetime time.Duration
+	ResetTokenLifetime        time.Duration
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
+}
+
+func (u *UseCases) sendEmailToken(uid uint, email string, purpose emailtoken.Purpose, lifetime time.Duration) error {
+	if u.Mailer == nil {
+		return ErrMailerUnavailable
+	}
+	if err := u.EmailTokenStorage.DeleteExpiredTokens(); err != nil {
+		return err
+	}
+	if err := u.EmailTokenStorage.DeleteTokensByUser(uid, purpose); err != nil {
+		return err
+	}
+	plain, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	t := emailtoken.Token{
+		Hash:      hashToken(plain),
+		Uid:       uid,
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	if err := u.EmailTokenStorage.CreateToken(t); err != nil {
+		return err
+	}
+
+	var m mailer.Message
+	switch purpose {
+	case emailtoken.VerifyEmail:
+		m = mailer.Message{
+			To:      email,
+			Subject: "Verify your code-swamp email address",
+			Body: fmt.Sprintf("Open %s to verify this address, or use the token %s.\n\nThe link expires in %s.\n",
+				u.emailLink("/verify-email", plain), plain, lifetime),
+		}
+	case emailtoken.ResetPassword:
+		m = mailer.Message{
+			To:      email,
+			Subject: "Reset your code-swamp password",
+			Body: fmt.Sprintf("Open %s to choose a new password, or use the token %s.\n\nThe link expires in %s. "+
+				"If you did not ask for a reset, ignore this mail.\n",
+				u.emailLink("/reset-password", plain), plain, lifetime),
+		}
+	}
+	return u.Mailer.Send(m)
+}
+
+func (u *UseCases) emailLink(path, token string) string {
+	return strings.TrimRight(u.Email.BaseUrl, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func (u *UseCases) SetEmail(aid uint, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := u.AccountStorage.SetEmail(aid, email); err != nil {
+		return err
+	}
+	return u.sendEmailToken(aid, email, emailtoken.VerifyEmail, u.Email.VerificationTokenLifetime)
+}
+
+func (u *UseCases) VerifyEmail(token string) error {
+	t, err := u.EmailTokenStorage.ConsumeToken(hashToken(token), emailtoken.VerifyEmail)
+	if err != nil {
+		if err == emailtoken.ErrNotFound {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	switch err := u.AccountStorage.VerifyEmail(t.Uid, t.Email); err {
+	case nil:
+		return nil
+	case account.ErrNotFound:
+		// the address was changed after the mail had been sent
+		return ErrInvalidEmailToken
+	case account.ErrAlreadyExist:
+		return ErrEmailTaken
+	default:
+		return err
+	}
+}
+
+// RequestPasswordReset does not tell whether the address belongs to anyone,
+// so that it can not be used to enumerate accounts. The mail is sent in the
+// background, as neither the time sending takes nor its failure may tell
+// either.
+func (u *UseCases) RequestPasswordReset(email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if u.Mailer == nil {
+		return ErrMailerUnavailable
+	}
+	acc, err := u.AccountStorage.GetAccountByVerifiedEmail(email)
+	if err != nil {
+		if err == account.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	go func() {
+		if err := u.sendEmailToken(acc.Id, email, emailtoken.ResetPassword, u.Email.ResetTokenLifetime); err != nil {
+			fmt.Printf("Error sending password reset mail to %d: %s\n", acc.Id, err)
+		}
+	}()
+	return nil
+}
+
+func (u *UseCases) ResetPassword(token, newPassword string) error {
+	if err := u.passwordPolicy().Validate(newPassword); err != nil {
+		return err
+	}
+	t, err := u.EmailTokenStorage.ConsumeToken(hashToken(token), emailtoken.ResetPassword)
+	if err != nil {
+		if err == emailtoken.ErrNotFound {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	acc, err := u.AccountStorage.GetAccountById(t.Uid)
+	if err != nil {
+		if err == account.ErrNotFound {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	hashedPassword, err := u.passwordHasher().Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := u.AccountStorage.UpdatePassword(acc.Id, hashedPassword); err != nil {
+		return err
+	}
+	if err := u.LoginThrottling.succeed(acc.CanonicalLogin); err != nil {
+		return err
+	}
+	return u.revokeAllSessions(acc.Id)
+}
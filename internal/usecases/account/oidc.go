@@ -0,0 +1,290 @@
+package account
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/identity"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/oidcrequest"
+	"github.com/mp-hl-2021/code-swamp/internal/service/oidc"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidOidcState = errors.New("invalid or expired authorization state")
+	ErrOidcFailed       = errors.New("identity provider sign in failed")
+	ErrOidcUnavailable  = errors.New("identity provider is unavailable")
+	ErrIdentityTaken    = errors.New("identity is already linked")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrLastCredential   = errors.New("account would be left without a way to sign in")
+)
+
+const maxGeneratedLoginAttempts = 5
+
+type OidcSettings struct {
+	Providers       map[string]*oidc.Provider
+	RequestLifetime time.Duration
+}
+
+type LinkedIdentity struct {
+	Provider  string
+	Email     string
+	CreatedAt time.Time
+}
+
+// OidcStart is where to send the browser. The caller binds State to the
+// browser, so that a callback can not be completed in another one.
+type OidcStart struct {
+	AuthorizationUrl string
+	State            string
+	ExpiresAt        time.Time
+}
+
+type OidcResult struct {
+	Tokens Tokens
+	Linked bool
+}
+
+// StartOidcLogin returns the provider url to send the browser to. With a
+// non-nil linkAid the callback links the identity to that account instead
+// of signing in.
+func (u *UseCases) StartOidcLogin(provider string, linkAid *uint, device string) (OidcStart, error) {
+	p, ok := u.Oidc.Providers[provider]
+	if !ok {
+		return OidcStart{}, ErrUnknownProvider
+	}
+	if err := u.OidcRequestStorage.DeleteExpiredRequests(); err != nil {
+		return OidcStart{}, err
+	}
+	state, err := randomString(32)
+	if err != nil {
+		return OidcStart{}, err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return OidcStart{}, err
+	}
+	verifier, challenge, err := oidc.NewPkce()
+	if err != nil {
+		return OidcStart{}, err
+	}
+	device = truncateDevice(device)
+	r := oidcrequest.Request{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Device:       device,
+		ExpiresAt:    time.Now().Add(u.Oidc.RequestLifetime),
+	}
+	if linkAid != nil {
+		r.Link = true
+		r.LinkUid = *linkAid
+	}
+	authUrl, err := p.AuthCodeUrl(state, nonce, challenge)
+	if err != nil {
+		if errors.Is(err, oidc.ErrDiscovery) {
+			fmt.Printf("OIDC sign in with %s failed: %s\n", provider, err)
+			return OidcStart{}, ErrOidcUnavailable
+		}
+		return OidcStart{}, err
+	}
+	if err := u.OidcRequestStorage.CreateRequest(r); err != nil {
+		return OidcStart{}, err
+	}
+	return OidcStart{AuthorizationUrl: authUrl, State: state, ExpiresAt: r.ExpiresAt}, nil
+}
+
+func (u *UseCases) FinishOidcLogin(provider, state, code string) (OidcResult, error) {
+	r, err := u.OidcRequestStorage.ConsumeRequest(hashToken(state))
+	if err != nil {
+		if err == oidcrequest.ErrNotFound {
+			return OidcResult{}, ErrInvalidOidcState
+		}
+		return OidcResult{}, err
+	}
+	if r.Provider != provider {
+		return OidcResult{}, ErrInvalidOidcState
+	}
+	p, ok := u.Oidc.Providers[provider]
+	if !ok {
+		return OidcResult{}, ErrUnknownProvider
+	}
+	claims, err := p.Exchange(code, r.CodeVerifier, r.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchange) || errors.Is(err, oidc.ErrInvalidIdToken) || errors.Is(err, oidc.ErrUnknownKey) {
+			fmt.Printf("OIDC sign in with %s failed: %s\n", provider, err)
+			return OidcResult{}, ErrOidcFailed
+		}
+		if errors.Is(err, oidc.ErrDiscovery) {
+			fmt.Printf("OIDC sign in with %s failed: %s\n", provider, err)
+			return OidcResult{}, ErrOidcUnavailable
+		}
+		return OidcResult{}, err
+	}
+
+	if r.Link {
+		if err := u.linkIdentity(r.LinkUid, provider, claims); err != nil {
+			return OidcResult{}, err
+		}
+		return OidcResult{Linked: true}, nil
+	}
+
+	uid, err := u.accountByIdentity(provider, claims)
+	if err != nil {
+		return OidcResult{}, err
+	}
+	acc, err := u.AccountStorage.GetAccountById(uid)
+	if err != nil {
+		return OidcResult{}, err
+	}
+	if acc.TotpEnabled {
+		return OidcResult{}, u.startChallenge(acc.Id, r.Device)
+	}
+	tokens, err := u.startSession(acc.Id, r.Device)
+	if err != nil {
+		return OidcResult{}, err
+	}
+	return OidcResult{Tokens: tokens}, nil
+}
+
+func (u *UseCases) linkIdentity(uid uint, provider string, claims oidc.Claims) error {
+	err := u.IdentityStorage.CreateIdentity(identity.Identity{
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Uid:       uid,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	})
+	if err == identity.ErrAlreadyExist {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+// accountByIdentity returns the account linked to the identity, creating an
+// account without a password on the first sign in.
+func (u *UseCases) accountByIdentity(provider string, claims oidc.Claims) (uint, error) {
+	i, err := u.IdentityStorage.GetIdentity(provider, claims.Subject)
+	if err == nil {
+		return i.Uid, nil
+	}
+	if err != identity.ErrNotFound {
+		return 0, err
+	}
+
+	acc, err := u.createPasswordlessAccount(claims)
+	if err != nil {
+		return 0, err
+	}
+	if err := u.linkIdentity(acc.Id, provider, claims); err != nil {
+		// a concurrent callback for the same subject won the race
+		if err := u.AccountStorage.DeleteAccount(acc.Id, account.DeleteSnippets); err != nil {
+			return 0, err
+		}
+		if i, err := u.IdentityStorage.GetIdentity(provider, claims.Subject); err == nil {
+			return i.Uid, nil
+		}
+		return 0, err
+	}
+	if email, err := normalizeEmail(claims.Email); err == nil && claims.EmailVerified {
+		if err := u.AccountStorage.SetEmail(acc.Id, email); err != nil {
+			return 0, err
+		}
+		// leave it unverified when another account has verified it already
+		if err := u.AccountStorage.VerifyEmail(acc.Id, email); err != nil && err != account.ErrAlreadyExist {
+			return 0, err
+		}
+	}
+	return acc.Id, nil
+}
+
+func (u *UseCases) createPasswordlessAccount(claims oidc.Claims) (account.Account, error) {
+	base := loginCandidate(claims.PreferredUsername)
+	if base == "" {
+		base = loginCandidate(strings.SplitN(claims.Email, "@", 2)[0])
+	}
+	if _, err := canonicalizeLogin(base + "0000"); err != nil {
+		base = "user"
+	}
+	for attempt := 0; attempt < maxGeneratedLoginAttempts; attempt++ {
+		login := base
+		if attempt > 0 || len([]rune(login)) < minLoginLength {
+			n, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return account.Account{}, err
+			}
+			login = fmt.Sprintf("%s%04d", base, n)
+		}
+		canonicalLogin, err := canonicalizeLogin(login)
+		if err != nil {
+			return account.Account{}, err
+		}
+		acc, err := u.AccountStorage.CreateAccount(account.Credentials{
+			Login:          login,
+			CanonicalLogin: canonicalLogin,
+		})
+		if err == account.ErrAlreadyExist {
+			continue
+		}
+		return acc, err
+	}
+	return account.Account{}, account.ErrAlreadyExist
+}
+
+// loginCandidate keeps the letters and digits of a provider user name, so
+// that it passes validateLogin once a numeric suffix is added.
+func loginCandidate(s string) string {
+	var runes []rune
+	for _, r := range s {
+		if unicode.IsLetter(r) || (unicode.IsDigit(r) && len(runes) > 0) {
+			runes = append(runes, r)
+		}
+	}
+	if len(runes) > maxLoginLength-4 {
+		runes = runes[:maxLoginLength-4]
+	}
+	return string(runes)
+}
+
+func (u *UseCases) GetIdentities(aid uint) ([]LinkedIdentity, error) {
+	ii, err := u.IdentityStorage.GetIdentitiesByUser(aid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]LinkedIdentity, len(ii))
+	for n, i := range ii {
+		res[n] = LinkedIdentity{
+			Provider:  i.Provider,
+			Email:     i.Email,
+			CreatedAt: i.CreatedAt,
+		}
+	}
+	return res, nil
+}
+
+func (u *UseCases) UnlinkIdentity(aid uint, provider string) error {
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return err
+	}
+	ii, err := u.IdentityStorage.GetIdentitiesByUser(aid)
+	if err != nil {
+		return err
+	}
+	if acc.Password == "" && len(ii) <= 1 {
+		return ErrLastCredential
+	}
+	if err := u.IdentityStorage.DeleteIdentity(aid, provider); err != nil {
+		if err == identity.ErrNotFound {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/session"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDeviceLength = 255
+
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+type Session struct {
+	Id         string
+	Device     string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// truncateDevice cuts the client's description of itself down to what the
+// storage takes, which counts characters, and drops invalid UTF-8 first.
+func truncateDevice(device string) string {
+	device = strings.ToValidUTF8(device, "")
+	if utf8.RuneCountInString(device) <= maxDeviceLength {
+		return device
+	}
+	return string([]rune(device)[:maxDeviceLength])
+}
+
+func (u *UseCases) startSession(uid uint, device string) (Tokens, error) {
+	if err := u.SessionStorage.DeleteExpiredSessions(); err != nil {
+		return Tokens{}, err
+	}
+	sid, err := randomString(16)
+	if err != nil {
+		return Tokens{}, err
+	}
+	refreshToken, err := randomString(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	access, err := u.Auth.IssueToken(uid, sid)
+	if err != nil {
+		return Tokens{}, err
+	}
+	device = truncateDevice(device)
+	now := time.Now()
+	s := session.Session{
+		Id:               sid,
+		Uid:              uid,
+		Device:           device,
+		RefreshTokenHash: hashToken(refreshToken),
+		AccessTokenId:    access.Jti,
+		AccessExpiresAt:  access.ExpiresAt,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(u.RefreshTokenLifetime),
+	}
+	if err := u.SessionStorage.CreateSession(s); err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:  access.Value,
+		RefreshToken: refreshToken,
+		ExpiresAt:    access.ExpiresAt,
+	}, nil
+}
+
+func (u *UseCases) RefreshTokens(refreshToken string) (Tokens, error) {
+	oldHash := hashToken(refreshToken)
+	s, err := u.SessionStorage.GetSessionByRefreshToken(oldHash)
+	if err != nil {
+		if err == session.ErrNotFound {
+			return Tokens{}, ErrInvalidRefreshToken
+		}
+		return Tokens{}, err
+	}
+	now := time.Now()
+	if s.ExpiresAt.Before(now) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+	newRefreshToken, err := randomString(32)
+	if err != nil {
+		return Tokens{}, err
+	}
+	access, err := u.Auth.IssueToken(s.Uid, s.Id)
+	if err != nil {
+		return Tokens{}, err
+	}
+	previousAccessTokenId, previousAccessExpiresAt := s.AccessTokenId, s.AccessExpiresAt
+	s.RefreshTokenHash = hashToken(newRefreshToken)
+	s.AccessTokenId = access.Jti
+	s.AccessExpiresAt = access.ExpiresAt
+	s.LastUsedAt = now
+	s.ExpiresAt = now.Add(u.RefreshTokenLifetime)
+	if err := u.SessionStorage.RotateSession(oldHash, s); err != nil {
+		if err == session.ErrNotFound {
+			return Tokens{}, ErrInvalidRefreshToken
+		}
+		return Tokens{}, err
+	}
+	if err := u.SessionStorage.RevokeAccessToken(previousAccessTokenId, previousAccessExpiresAt); err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:  access.Value,
+		RefreshToken: newRefreshToken,
+		ExpiresAt:    access.ExpiresAt,
+	}, nil
+}
+
+func (u *UseCases) Logout(tokenString string) error {
+	claims, err := u.Auth.ParseToken(tokenString)
+	if err != nil {
+		return err
+	}
+	if err := u.SessionStorage.RevokeAccessToken(claims.StandardClaims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
+	}
+	if claims.Sid == "" {
+		return nil
+	}
+	if err := u.SessionStorage.DeleteSession(claims.Sid); err != nil && err != session.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (u *UseCases) GetSessions(aid uint) ([]Session, error) {
+	if err := u.SessionStorage.DeleteExpiredSessions(); err != nil {
+		return nil, err
+	}
+	ss, err := u.SessionStorage.GetSessionsByUser(aid)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(ss))
+	for i, s := range ss {
+		sessions[i] = Session{
+			Id:         s.Id,
+			Device:     s.Device,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+		}
+	}
+	return sessions, nil
+}
+
+func (u *UseCases) RevokeSession(aid uint, sid string) error {
+	s, err := u.SessionStorage.GetSessionById(sid)
+	if err != nil {
+		if err == session.ErrNotFound {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if s.Uid != aid {
+		return ErrSessionNotFound
+	}
+	if err := u.SessionStorage.RevokeAccessToken(s.AccessTokenId, s.AccessExpiresAt); err != nil {
+		return err
+	}
+	if err := u.SessionStorage.DeleteSession(s.Id); err != nil {
+		if err == session.ErrNotFound {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *UseCases) revokeAllSessions(uid uint) error {
+	ss, err := u.SessionStorage.GetSessionsByUser(uid)
+	if err != nil {
+		return err
+	}
+	return u.revokeSessions(ss)
+}
+
+func (u *UseCases) revokeSessions(ss []session.Session) error {
+	for _, s := range ss {
+		if err := u.SessionStorage.RevokeAccessToken(s.AccessTokenId, s.AccessExpiresAt); err != nil {
+			return err
+		}
+		if err := u.SessionStorage.DeleteSession(s.Id); err != nil && err != session.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package account
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/service/token"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_truncateDevice(t *testing.T) {
+	for _, device := range []string{
+		strings.Repeat("ж", maxDeviceLength+1),
+		strings.Repeat("a", maxDeviceLength-1) + "жж",
+		"Mozilla\xff/5.0",
+	} {
+		got := truncateDevice(device)
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxDeviceLength {
+			t.Errorf("truncateDevice(%q) MUST return at most %d valid runes, but %q given", device, maxDeviceLength, got)
+		}
+	}
+	if got := truncateDevice("Firefox"); got != "Firefox" {
+		t.Errorf("truncateDevice MUST keep short devices, but %q given", got)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	u := newUseCases(t, nil)
+	aid := createAccount(t, u)
+	old := login(t, u, testPassword)
+
+	rotated, err := u.RefreshTokens(old.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RefreshToken == old.RefreshToken || rotated.AccessToken == old.AccessToken {
+		t.Error("RefreshTokens MUST issue new tokens")
+	}
+	if _, err := u.Authenticate(old.AccessToken); err != token.ErrTokenRevoked {
+		t.Errorf("the previous access token MUST be revoked, but %v given", err)
+	}
+	if id, err := u.Authenticate(rotated.AccessToken); err != nil || id.Id != aid {
+		t.Errorf("the new access token MUST authenticate %d, but %v, %v given", aid, id, err)
+	}
+	if _, err := u.RefreshTokens(old.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("a reused refresh token MUST be rejected, but %v given", err)
+	}
+	if _, err := u.RefreshTokens("made up"); err != ErrInvalidRefreshToken {
+		t.Errorf("an unknown refresh token MUST be rejected, but %v given", err)
+	}
+	if ss, err := u.GetSessions(aid); err != nil || len(ss) != 1 {
+		t.Errorf("rotation MUST keep the one session, but %v, %v given", ss, err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	u := newUseCases(t, nil)
+	aid := createAccount(t, u)
+	revoked, kept := login(t, u, testPassword), login(t, u, testPassword)
+	if ss, err := u.GetSessions(aid); err != nil || len(ss) != 2 {
+		t.Fatalf("two sessions MUST be listed, but %v, %v given", ss, err)
+	}
+	claims, err := u.Auth.ParseToken(revoked.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := claims.Sid
+
+	if err := u.RevokeSession(aid+1, sid); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession of someone else's session MUST return %v, but %v given", ErrSessionNotFound, err)
+	}
+	if err := u.RevokeSession(aid, sid); err != nil {
+		t.Fatal(err)
+	}
+	assertSignedOut(t, u, revoked)
+	if _, err := u.Authenticate(kept.AccessToken); err != nil {
+		t.Errorf("the other session MUST be kept, but %v given", err)
+	}
+	if _, err := u.RefreshTokens(kept.RefreshToken); err != nil {
+		t.Errorf("the other session MUST be kept, but %v given", err)
+	}
+	if err := u.RevokeSession(aid, sid); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession twice MUST return %v, but %v given", ErrSessionNotFound, err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	u := newUseCases(t, nil)
+	createAccount(t, u)
+	tokens := login(t, u, testPassword)
+	if err := u.Logout(tokens.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	assertSignedOut(t, u, tokens)
+}
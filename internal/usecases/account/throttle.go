@@ -0,0 +1,118 @@
+package account
+
+import (
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/loginattempt"
+	"strings"
+	"time"
+)
+
+const (
+	loginAttemptKeyPrefix = "login:"
+	ipAttemptKeyPrefix    = "ip:"
+)
+
+// ThrottlePolicy lets FreeAttempts failures in a row through, then locks the
+// key for BaseLockout, doubling with every further failure up to MaxLockout.
+// Failures older than Window are forgotten.
+type ThrottlePolicy struct {
+	FreeAttempts int
+	BaseLockout  time.Duration
+	MaxLockout   time.Duration
+	Window       time.Duration
+}
+
+func (p ThrottlePolicy) lockout(failures int) time.Duration {
+	if p.BaseLockout <= 0 || failures <= p.FreeAttempts {
+		return 0
+	}
+	d := p.BaseLockout
+	for i := p.FreeAttempts + 1; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// LoginThrottling counts failed sign ins per login and per IP. A key is
+// checked before the password is and counted once it turned out wrong, so
+// attempts running in parallel all get through until the first of them has
+// been counted: a burst is only limited by how fast the password hashing is.
+type LoginThrottling struct {
+	Storage  loginattempt.Interface
+	PerLogin ThrottlePolicy
+	PerIp    ThrottlePolicy
+}
+
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry after %v", e.RetryAfter)
+}
+
+func (t *LoginThrottling) keys(login, ip string) []string {
+	keys := []string{loginAttemptKeyPrefix + login}
+	if ip != "" {
+		keys = append(keys, ipAttemptKeyPrefix+ip)
+	}
+	return keys
+}
+
+func (t *LoginThrottling) policy(key string) ThrottlePolicy {
+	if strings.HasPrefix(key, ipAttemptKeyPrefix) {
+		return t.PerIp
+	}
+	return t.PerLogin
+}
+
+func (t *LoginThrottling) check(login, ip string) error {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range t.keys(login, ip) {
+		a, err := t.Storage.GetAttempts(key)
+		if err != nil {
+			return err
+		}
+		if d := a.LockedUntil.Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedOutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (t *LoginThrottling) fail(login, ip string) error {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, key := range t.keys(login, ip) {
+		p := t.policy(key)
+		failures, err := t.Storage.RecordFailure(key, p.Window)
+		if err != nil {
+			return err
+		}
+		if d := p.lockout(failures); d > 0 {
+			if err := t.Storage.Lock(key, now.Add(d)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *LoginThrottling) succeed(login string) error {
+	if t == nil {
+		return nil
+	}
+	return t.Storage.Reset(loginAttemptKeyPrefix + login)
+}
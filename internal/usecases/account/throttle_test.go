@@ -0,0 +1,111 @@
+package account
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/loginattemptrepo"
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicy_lockout(t *testing.T) {
+	p := ThrottlePolicy{FreeAttempts: 3, BaseLockout: time.Second, MaxLockout: 10 * time.Second}
+	for failures, want := range map[int]time.Duration{
+		0:   0,
+		3:   0,
+		4:   time.Second,
+		5:   2 * time.Second,
+		6:   4 * time.Second,
+		7:   8 * time.Second,
+		8:   10 * time.Second,
+		100: 10 * time.Second,
+	} {
+		if got := p.lockout(failures); got != want {
+			t.Errorf("lockout(%d) MUST be %v, but %v given", failures, want, got)
+		}
+	}
+	if got := (ThrottlePolicy{FreeAttempts: 3}).lockout(10); got != 0 {
+		t.Errorf("lockout without a base lockout MUST be 0, but %v given", got)
+	}
+}
+
+func newThrottling() *LoginThrottling {
+	return &LoginThrottling{
+		Storage:  loginattemptrepo.NewMemory(),
+		PerLogin: ThrottlePolicy{FreeAttempts: 2, BaseLockout: time.Hour, MaxLockout: time.Hour, Window: time.Hour},
+		PerIp:    ThrottlePolicy{FreeAttempts: 4, BaseLockout: time.Hour, MaxLockout: time.Hour, Window: time.Hour},
+	}
+}
+
+func failTimes(t *testing.T, l *LoginThrottling, login, ip string, n int) {
+	for i := 0; i < n; i++ {
+		if err := l.fail(login, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertLockedOut(t *testing.T, l *LoginThrottling, login, ip string, locked bool) {
+	t.Helper()
+	err := l.check(login, ip)
+	if e, ok := err.(*LockedOutError); locked && (!ok || e.RetryAfter <= 0) {
+		t.Errorf("check(%q, %q) MUST lock out, but %v given", login, ip, err)
+	}
+	if !locked && err != nil {
+		t.Errorf("check(%q, %q) MUST let through, but %v given", login, ip, err)
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	l := newThrottling()
+	failTimes(t, l, "froggy", "192.0.2.1", 2)
+	assertLockedOut(t, l, "froggy", "192.0.2.1", false)
+	failTimes(t, l, "froggy", "192.0.2.1", 1)
+	// the login is locked from everywhere, the address for other logins not
+	// yet
+	assertLockedOut(t, l, "froggy", "192.0.2.1", true)
+	assertLockedOut(t, l, "froggy", "198.51.100.1", true)
+	assertLockedOut(t, l, "toadie", "192.0.2.1", false)
+
+	failTimes(t, l, "toadie", "192.0.2.1", 2)
+	assertLockedOut(t, l, "newtie", "192.0.2.1", true)
+	assertLockedOut(t, l, "newtie", "198.51.100.1", false)
+
+	if err := l.succeed("froggy"); err != nil {
+		t.Fatal(err)
+	}
+	assertLockedOut(t, l, "froggy", "198.51.100.1", false)
+	assertLockedOut(t, l, "froggy", "192.0.2.1", true)
+}
+
+func TestLoginThrottling_window(t *testing.T) {
+	l := newThrottling()
+	l.PerLogin.Window = 20 * time.Millisecond
+	failTimes(t, l, "froggy", "", 2)
+	time.Sleep(30 * time.Millisecond)
+	failTimes(t, l, "froggy", "", 1)
+	assertLockedOut(t, l, "froggy", "", false)
+	failTimes(t, l, "froggy", "", 2)
+	assertLockedOut(t, l, "froggy", "", true)
+}
+
+func TestLoginToAccount_throttling(t *testing.T) {
+	u := newUseCases(t, nil)
+	u.LoginThrottling = newThrottling()
+	createAccount(t, u)
+	// an unknown login counts like a wrong password, so neither tells which
+	// logins exist
+	for _, c := range []struct{ login, password, ip string }{
+		{testLogin, "wrong password", "192.0.2.1"},
+		{"nobodyhere", testPassword, "192.0.2.2"},
+	} {
+		for i := 0; i < 3; i++ {
+			if _, err := u.LoginToAccount(c.login, c.password, "", c.ip); err != ErrInvalidCredentials {
+				t.Fatalf("LoginToAccount(%q) MUST return %v, but %v given", c.login, ErrInvalidCredentials, err)
+			}
+		}
+		if _, err := u.LoginToAccount(c.login, testPassword, "", "198.51.100.1"); err == nil {
+			t.Errorf("LoginToAccount(%q) MUST be locked out, but succeeded", c.login)
+		} else if _, ok := err.(*LockedOutError); !ok {
+			t.Errorf("LoginToAccount(%q) MUST be locked out, but %v given", c.login, err)
+		}
+	}
+}
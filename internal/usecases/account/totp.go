@@ -0,0 +1,304 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	account "github.com/mp-hl-2021/code-swamp/internal/domain/account"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/challenge"
+	"github.com/mp-hl-2021/code-swamp/internal/service/secretbox"
+	"github.com/mp-hl-2021/code-swamp/internal/service/totp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTotpUnavailable    = errors.New("two-factor authentication is not configured")
+	ErrTotpAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTotpNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTotpCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge   = errors.New("invalid or expired sign in challenge")
+)
+
+const (
+	recoveryCodeCount    = 10
+	maxChallengeFailures = 5
+	totpSkew             = 1
+)
+
+type TwoFactorSettings struct {
+	Issuer            string
+	ChallengeLifetime time.Duration
+	Secrets           *secretbox.Box
+}
+
+type TotpEnrollment struct {
+	Secret          string
+	ProvisioningUri string
+}
+
+// ChallengeRequiredError is returned by LoginToAccount when the password is
+// right but the account needs a second factor to complete the sign in.
+type ChallengeRequiredError struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+func (e *ChallengeRequiredError) Error() string {
+	return "second factor is required"
+}
+
+func (u *UseCases) startChallenge(uid uint, device string) error {
+	if err := u.ChallengeStorage.DeleteExpiredChallenges(); err != nil {
+		return err
+	}
+	plain, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	device = truncateDevice(device)
+	c := challenge.Challenge{
+		Hash:      hashToken(plain),
+		Uid:       uid,
+		Device:    device,
+		ExpiresAt: time.Now().Add(u.TwoFactor.ChallengeLifetime),
+	}
+	if err := u.ChallengeStorage.CreateChallenge(c); err != nil {
+		return err
+	}
+	return &ChallengeRequiredError{Challenge: plain, ExpiresAt: c.ExpiresAt}
+}
+
+// CompleteSignin counts wrong codes as failed sign ins of the login, so that
+// the throttling covers the second factor as well.
+func (u *UseCases) CompleteSignin(challengeToken, code, ip string) (Tokens, error) {
+	hash := hashToken(challengeToken)
+	c, err := u.ChallengeStorage.GetChallenge(hash)
+	if err != nil {
+		if err == challenge.ErrNotFound {
+			return Tokens{}, ErrInvalidChallenge
+		}
+		return Tokens{}, err
+	}
+	acc, err := u.AccountStorage.GetAccountById(c.Uid)
+	if err != nil {
+		if err == account.ErrNotFound {
+			return Tokens{}, ErrInvalidChallenge
+		}
+		return Tokens{}, err
+	}
+	login := acc.Credentials.CanonicalLogin
+	if err := u.LoginThrottling.check(login, ip); err != nil {
+		return Tokens{}, err
+	}
+	ok, err := u.checkSecondFactor(acc, code)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if !ok {
+		if err := u.LoginThrottling.fail(login, ip); err != nil {
+			return Tokens{}, err
+		}
+		failures, err := u.ChallengeStorage.RecordFailure(hash)
+		if err != nil && err != challenge.ErrNotFound {
+			return Tokens{}, err
+		}
+		if failures >= maxChallengeFailures {
+			if err := u.ChallengeStorage.DeleteChallenge(hash); err != nil && err != challenge.ErrNotFound {
+				return Tokens{}, err
+			}
+		}
+		return Tokens{}, ErrInvalidTotpCode
+	}
+	if err := u.ChallengeStorage.DeleteChallenge(hash); err != nil {
+		if err == challenge.ErrNotFound {
+			return Tokens{}, ErrInvalidChallenge
+		}
+		return Tokens{}, err
+	}
+	if err := u.LoginThrottling.succeed(login); err != nil {
+		return Tokens{}, err
+	}
+	return u.startSession(acc.Id, c.Device)
+}
+
+func isTotpCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *UseCases) checkSecondFactor(acc account.Account, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if isTotpCode(code) {
+		return u.checkTotp(acc, code)
+	}
+	err := u.AccountStorage.UseRecoveryCode(acc.Id, hashToken(normalizeRecoveryCode(code)))
+	if err == account.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkTotp accepts every code at most once, so that an observed code can
+// not be replayed within its validity window.
+func (u *UseCases) checkTotp(acc account.Account, code string) (bool, error) {
+	if u.TwoFactor.Secrets == nil {
+		return false, ErrTotpUnavailable
+	}
+	if acc.TotpSecret == "" {
+		return false, ErrTotpNotEnrolled
+	}
+	secret, err := u.TwoFactor.Secrets.Open(acc.TotpSecret)
+	if err != nil {
+		return false, err
+	}
+	step, ok, err := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := u.AccountStorage.UseTotpStep(acc.Id, step); err != nil {
+		if err == account.ErrTotpStepUsed {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (u *UseCases) EnrollTotp(aid uint) (TotpEnrollment, error) {
+	if u.TwoFactor.Secrets == nil {
+		return TotpEnrollment{}, ErrTotpUnavailable
+	}
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return TotpEnrollment{}, err
+	}
+	if acc.TotpEnabled {
+		return TotpEnrollment{}, ErrTotpAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TotpEnrollment{}, err
+	}
+	sealed, err := u.TwoFactor.Secrets.Seal([]byte(secret))
+	if err != nil {
+		return TotpEnrollment{}, err
+	}
+	if err := u.AccountStorage.SetTotp(aid, sealed, false); err != nil {
+		return TotpEnrollment{}, err
+	}
+	return TotpEnrollment{
+		Secret:          secret,
+		ProvisioningUri: totp.ProvisioningUri(u.TwoFactor.Issuer, acc.Login, secret),
+	}, nil
+}
+
+// verifySecondFactor counts wrong codes as failed sign ins of the login too,
+// so that a stolen access token is no way around the throttling either.
+func (u *UseCases) verifySecondFactor(acc account.Account, ip string, check func() (bool, error)) error {
+	login := acc.Credentials.CanonicalLogin
+	if err := u.LoginThrottling.check(login, ip); err != nil {
+		return err
+	}
+	ok, err := check()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := u.LoginThrottling.fail(login, ip); err != nil {
+			return err
+		}
+		return ErrInvalidTotpCode
+	}
+	return u.LoginThrottling.succeed(login)
+}
+
+func (u *UseCases) ConfirmTotp(aid uint, code, ip string) ([]string, error) {
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return nil, err
+	}
+	if acc.TotpEnabled {
+		return nil, ErrTotpAlreadyEnabled
+	}
+	err = u.verifySecondFactor(acc, ip, func() (bool, error) {
+		return u.checkTotp(acc, strings.TrimSpace(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+	codes, err := u.resetRecoveryCodes(aid)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.AccountStorage.SetTotp(aid, acc.TotpSecret, true); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (u *UseCases) RegenerateRecoveryCodes(aid uint, code, ip string) ([]string, error) {
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return nil, err
+	}
+	if !acc.TotpEnabled {
+		return nil, ErrTotpNotEnrolled
+	}
+	err = u.verifySecondFactor(acc, ip, func() (bool, error) {
+		return u.checkTotp(acc, strings.TrimSpace(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u.resetRecoveryCodes(aid)
+}
+
+// DisableTotp takes a second factor rather than the password, accounts
+// created through an identity provider have none.
+func (u *UseCases) DisableTotp(aid uint, code, ip string) error {
+	acc, err := u.AccountStorage.GetAccountById(aid)
+	if err != nil {
+		return err
+	}
+	err = u.verifySecondFactor(acc, ip, func() (bool, error) {
+		return u.checkSecondFactor(acc, code)
+	})
+	if err != nil {
+		return err
+	}
+	if err := u.AccountStorage.SetTotp(aid, "", false); err != nil {
+		return err
+	}
+	return u.AccountStorage.SetRecoveryCodes(aid, nil)
+}
+
+func (u *UseCases) resetRecoveryCodes(aid uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := base32.StdEncoding.EncodeToString(b)
+		codes[i] = c[0:4] + "-" + c[4:8] + "-" + c[8:12] + "-" + c[12:16]
+		hashes[i] = hashToken(c)
+	}
+	if err := u.AccountStorage.SetRecoveryCodes(aid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -4,10 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintoptions"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/star"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"github.com/mp-hl-2021/code-swamp/internal/service/formatter"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
 	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
-	"io/ioutil"
-	"os"
-	"os/exec"
+	webhookuc "github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
 	"strings"
 	"time"
 )
@@ -17,82 +23,106 @@ var (
 )
 
 type CheckCodeRequest struct {
-	Sid  uint
-	Code string
-	Lang string
+	Sid     uint
+	Code    string
+	Lang    string
+	Options linter.Options
 }
 
 type Interface interface {
-	GetMySnippetIds(a account.Account) ([]uint, error)
-	CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration) (uint, error)
+	GetMySnippetIds(a account.Account, p Page) ([]uint, error)
+	CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error)
+	GetTeamSnippetIds(a account.Account, tid uint) ([]uint, error)
+	CreateTeamSnippet(a account.Account, tid uint, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error)
+	CheckSnippet(a *account.Account, tid *uint, lang string, opts linter.Options) error
 	GetSnippetById(uint) (codesnippet.CodeSnippet, error)
-	CheckCode(sid uint, code string, lang string) error
+	DeleteSnippet(a account.Account, sid uint) error
+	AddComment(a account.Account, sid uint, parentId *uint, startLine, endLine int, body string) (comment.Comment, error)
+	GetComments(viewer *account.Account, sid uint) ([]comment.Comment, error)
+	SetCommentHidden(a account.Account, sid, cid uint, hidden bool) error
+	DeleteComment(a account.Account, sid, cid uint) error
+	StarSnippet(a account.Account, sid uint) error
+	UnstarSnippet(a account.Account, sid uint) error
+	GetStarredSnippetIds(a account.Account, p Page) ([]uint, error)
+	GetStarCount(sid uint) (uint, error)
+	WatchLintStatus(sid uint) (<-chan LintStatus, func(), error)
+	Lint(a *account.Account, code string, lang string, opts linter.Options) ([]linter.Report, error)
+	GetDefaultLintOptions(a *account.Account) (linter.Options, error)
+	SetDefaultLintOptions(a account.Account, opts linter.Options) error
+	CheckCode(sid uint, code string, lang string, opts linter.Options) error
+	Relint(a account.Account, sid uint) error
+	RelintStale(a account.Account) (int, error)
+	GetCanonicalCode(sid uint) (string, error)
+	FormatCode(lang string, code string) (string, error)
+	GetFormattedCode(sid uint) (string, error)
 }
 
 type UseCases struct {
 	CodeSnippetStorage codesnippet.Interface
+	TeamStorage        team.Interface
+	CommentStorage     comment.Interface
+	StarStorage        star.Interface
+	Webhooks           webhookuc.Interface
+	LintEvents         lintevent.Interface
+	Linters            *linter.Registry
+	Formatters         *formatter.Registry
+	LintOptionsStorage lintoptions.Interface
+	LintTimeout        time.Duration
+	// LintRequestTimeout bounds Lint and FormatCode, which a request waits
+	// for.
+	LintRequestTimeout time.Duration
 	CodeCheckChannel   chan<- CheckCodeRequest
+	// Admins are the ids of the accounts allowed to re-lint every snippet.
+	Admins         []uint
+	RelintInterval time.Duration
+
+	relinting int32
 }
 
-func runLinter(code string) (string, error) {
-	file, err := ioutil.TempFile("", "tmp*.go")
+func (u *UseCases) CheckCode(sid uint, code string, lang string, opts linter.Options) error {
+	l, msg, err := u.lint(sid, code, lang, opts)
 	if err != nil {
-		return "", errors.New("failed to create temporary file: " + err.Error())
+		return err
 	}
-	defer os.Remove(file.Name())
-	_, err = file.Write([]byte(code))
-	if err != nil {
-		return "", errors.New("failed to write to temporary file: " + err.Error())
+	if err := u.CodeSnippetStorage.SetLint(sid, l, msg); err != nil {
+		return err
 	}
-	output, err := exec.Command("./go/bin/dupl", file.Name()).Output()
+	u.publishLintEvent(sid, lintevent.Checked)
+	s, err := u.CodeSnippetStorage.GetCodeSnippetById(sid)
 	if err != nil {
-		return "", errors.New("failed to run dupl on file: " + err.Error())
+		return err
 	}
-	return string(output), nil
+	u.publish(webhook.SnippetChecked, sid, s)
+	return nil
 }
 
-func (u *UseCases) CheckCode(sid uint, code string, lang string) error {
-	var msg string
-	if strings.ToLower(lang) != "go" {
-		msg = ""
-	} else {
-		r, err := runLinter(code)
-		if err != nil {
-			msg = err.Error()
-		} else {
-			msg = r
-		}
+func (u *UseCases) GetMySnippetIds(a account.Account, p Page) ([]uint, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
 	}
-	return u.CodeSnippetStorage.SetCodeLinterMessage(sid, msg)
-}
-
-func (u *UseCases) GetMySnippetIds(a account.Account) ([]uint, error) {
-	if err := u.CodeSnippetStorage.DeleteExpiredSnippets(); err != nil {
+	if err := u.deleteExpiredSnippets(); err != nil {
 		return []uint{}, err
 	}
 	fmt.Printf("GetMySnippetIds: %d\n", a.Id)
-	return u.CodeSnippetStorage.GetMyCodeSnippetIds(a.Id)
+	ids, err := u.CodeSnippetStorage.GetMyCodeSnippetIds(a.Id, p.limit(), p.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		return []uint{}, nil
+	}
+	return ids, nil
 }
 
-func (u *UseCases) CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration) (uint, error) {
-	shortenedCode := code
-	if len(code) > 10 {
-		shortenedCode = code[:10] + "..."
-	}
-	fmt.Printf("CreateSnippet: %s\n", shortenedCode)
-	if lang != "" {
-		if err := validateLanguage(lang); err != nil {
-			return 0, err
-		}
+func (u *UseCases) CreateSnippet(a *account.Account, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error) {
+	s, err := newSnippet(code, lang, lifetime)
+	if err != nil {
+		return 0, err
 	}
-	s := codesnippet.CodeSnippet{
-		Code:      code,
-		Lang:      lang,
-		IsChecked: false,
-		Lifetime:  lifetime,
+	if s.LintOptions, err = u.resolveLintOptions(a, opts); err != nil {
+		return 0, err
 	}
 	var sid uint
-	var err error
 	if a == nil {
 		sid, err = u.CodeSnippetStorage.CreateCodeSnippet(s)
 		if err != nil {
@@ -103,22 +133,140 @@ func (u *UseCases) CreateSnippet(a *account.Account, code string, lang string, l
 		if err != nil {
 			return 0, err
 		}
+		s.Uid, s.HasUser = a.Id, true
+		u.publish(webhook.SnippetCreated, sid, s)
+	}
+	u.checkLater(sid, code, lang, s.LintOptions)
+	return sid, nil
+}
+
+func newSnippet(code string, lang string, lifetime time.Duration) (codesnippet.CodeSnippet, error) {
+	shortenedCode := code
+	if len(code) > 10 {
+		shortenedCode = code[:10] + "..."
 	}
+	fmt.Printf("CreateSnippet: %s\n", shortenedCode)
+	if lang != "" {
+		if err := validateLanguage(lang); err != nil {
+			return codesnippet.CodeSnippet{}, err
+		}
+	}
+	return codesnippet.CodeSnippet{
+		Code:     code,
+		Lang:     lang,
+		Lint:     codesnippet.Lint{Status: codesnippet.LintPending},
+		Lifetime: lifetime,
+	}, nil
+}
+
+func (u *UseCases) checkLater(sid uint, code string, lang string, opts linter.Options) {
+	u.publishLintEvent(sid, lintevent.Queued)
 	go func() {
-		u.CodeCheckChannel <- CheckCodeRequest{sid, code, lang}
+		u.CodeCheckChannel <- CheckCodeRequest{sid, code, lang, opts}
 	}()
+}
+
+// teamRole hides teams from those outside of them behind team.ErrNotFound.
+func (u *UseCases) teamRole(a account.Account, tid uint) (team.Role, error) {
+	ms, err := u.TeamStorage.GetMembership(tid, a.Id)
+	if err == team.ErrMemberNotFound {
+		return "", team.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return ms.Role, nil
+}
+
+func (u *UseCases) GetTeamSnippetIds(a account.Account, tid uint) ([]uint, error) {
+	if _, err := u.teamRole(a, tid); err != nil {
+		return nil, err
+	}
+	if err := u.deleteExpiredSnippets(); err != nil {
+		return []uint{}, err
+	}
+	fmt.Printf("GetTeamSnippetIds: %d\n", tid)
+	return u.CodeSnippetStorage.GetTeamCodeSnippetIds(tid)
+}
+
+func (u *UseCases) CreateTeamSnippet(a account.Account, tid uint, code string, lang string, lifetime time.Duration, opts linter.Options) (uint, error) {
+	if _, err := u.teamRole(a, tid); err != nil {
+		return 0, err
+	}
+	s, err := newSnippet(code, lang, lifetime)
+	if err != nil {
+		return 0, err
+	}
+	if s.LintOptions, err = u.resolveLintOptions(&a, opts); err != nil {
+		return 0, err
+	}
+	sid, err := u.CodeSnippetStorage.CreateCodeSnippetWithTeam(s, a.Id, tid)
+	if err != nil {
+		return 0, err
+	}
+	s.Uid, s.HasUser = a.Id, true
+	s.Tid, s.HasTeam = tid, true
+	u.publish(webhook.SnippetCreated, sid, s)
+	u.checkLater(sid, code, lang, s.LintOptions)
 	return sid, nil
 }
 
+// CheckSnippet fails the way CreateSnippet, or CreateTeamSnippet when tid is
+// set, would on the same request, without storing anything.
+func (u *UseCases) CheckSnippet(a *account.Account, tid *uint, lang string, opts linter.Options) error {
+	if tid != nil {
+		if a == nil {
+			return team.ErrNotFound
+		}
+		if _, err := u.teamRole(*a, *tid); err != nil {
+			return err
+		}
+	}
+	if lang != "" {
+		if err := validateLanguage(lang); err != nil {
+			return err
+		}
+	}
+	_, err := u.resolveLintOptions(a, opts)
+	return err
+}
+
 func (u *UseCases) GetSnippetById(id uint) (codesnippet.CodeSnippet, error) {
-	if err := u.CodeSnippetStorage.DeleteExpiredSnippets(); err != nil {
+	if err := u.deleteExpiredSnippets(); err != nil {
 		return codesnippet.CodeSnippet{}, err
 	}
 	fmt.Printf("GetSnippetById: %d\n", id)
 	return u.CodeSnippetStorage.GetCodeSnippetById(id)
 }
 
-var SupportedLanguages = []string{"Python", "JavaScript", "Java", "Kotlin", "C#", "C", "C++", "PHP", "Swift", "Go", "Rust", "PETOOH"}
+func (u *UseCases) DeleteSnippet(a account.Account, sid uint) error {
+	fmt.Printf("DeleteSnippet: %d\n", sid)
+	// maintainers manage every snippet of their team, members only their own
+	s, err := u.CodeSnippetStorage.GetCodeSnippetById(sid)
+	if err != nil {
+		return err
+	}
+	if s.HasTeam && (!s.HasUser || s.Uid != a.Id) {
+		role, err := u.teamRole(a, s.Tid)
+		if err == nil && role.AtLeast(team.Maintainer) {
+			if err := u.CodeSnippetStorage.DeleteTeamCodeSnippet(sid, s.Tid); err != nil {
+				return err
+			}
+			u.publish(webhook.SnippetDeleted, sid, s)
+			return nil
+		}
+		if err != nil && err != team.ErrNotFound {
+			return err
+		}
+	}
+	if err := u.CodeSnippetStorage.DeleteCodeSnippet(sid, a.Id); err != nil {
+		return err
+	}
+	u.publish(webhook.SnippetDeleted, sid, s)
+	return nil
+}
+
+var SupportedLanguages = []string{"Python", "JavaScript", "Java", "Kotlin", "C#", "C", "C++", "PHP", "Swift", "Go", "Rust", "PETOOH", "JSON", "YAML", "XML", "TOML"}
 
 func validateLanguage(lang string) error {
 	for _, l := range SupportedLanguages {
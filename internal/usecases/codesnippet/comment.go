@@ -0,0 +1,153 @@
+package codesnippet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/comment"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidComment   = errors.New("comment should be between 1 and 4096 characters")
+	ErrInvalidLineRange = errors.New("line range is outside of the snippet")
+	ErrNotModerator     = errors.New("only the snippet owner may moderate comments")
+)
+
+const maxCommentLength = 4096
+
+func lineCount(code string) int {
+	return strings.Count(strings.TrimSuffix(code, "\n"), "\n") + 1
+}
+
+// isModerator tells whether a may moderate the comments of s: the author of
+// the snippet, or a maintainer of its team.
+func (u *UseCases) isModerator(a account.Account, s codesnippet.CodeSnippet) (bool, error) {
+	if s.HasUser && s.Uid == a.Id {
+		return true, nil
+	}
+	if !s.HasTeam {
+		return false, nil
+	}
+	role, err := u.teamRole(a, s.Tid)
+	if err == team.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role.AtLeast(team.Maintainer), nil
+}
+
+// AddComment anchors a comment to the lines startLine through endLine of the
+// snippet, counted from one. A reply takes the anchor of its parent.
+func (u *UseCases) AddComment(a account.Account, sid uint, parentId *uint, startLine, endLine int, body string) (comment.Comment, error) {
+	if strings.TrimSpace(body) == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		return comment.Comment{}, ErrInvalidComment
+	}
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		return comment.Comment{}, err
+	}
+	c := comment.Comment{
+		Sid:       sid,
+		Uid:       a.Id,
+		StartLine: startLine,
+		EndLine:   endLine,
+		Body:      body,
+	}
+	if parentId != nil {
+		parent, err := u.CommentStorage.GetCommentById(*parentId)
+		if err != nil {
+			return comment.Comment{}, err
+		}
+		if parent.Sid != sid {
+			return comment.Comment{}, comment.ErrNotFound
+		}
+		c.ParentId, c.HasParent = parent.Id, true
+		c.StartLine, c.EndLine = parent.StartLine, parent.EndLine
+	} else if startLine < 1 || endLine < startLine || endLine > lineCount(s.Code) {
+		return comment.Comment{}, ErrInvalidLineRange
+	}
+	fmt.Printf("AddComment: %d\n", sid)
+	return u.CommentStorage.CreateComment(c)
+}
+
+// GetComments returns the comments of the snippet in the order they were
+// made. Hidden comments keep their place in the thread, but only their
+// author and the moderators see the body.
+func (u *UseCases) GetComments(viewer *account.Account, sid uint) ([]comment.Comment, error) {
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := u.CommentStorage.GetCommentsBySnippet(sid)
+	if err != nil {
+		return nil, err
+	}
+	moderator := false
+	if viewer != nil {
+		moderator, err = u.isModerator(*viewer, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, c := range cc {
+		if c.Hidden && !moderator && (viewer == nil || viewer.Id != c.Uid) {
+			cc[i].Body = ""
+		}
+	}
+	return cc, nil
+}
+
+func (u *UseCases) snippetComment(sid, cid uint) (codesnippet.CodeSnippet, comment.Comment, error) {
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		return codesnippet.CodeSnippet{}, comment.Comment{}, err
+	}
+	c, err := u.CommentStorage.GetCommentById(cid)
+	if err != nil {
+		return codesnippet.CodeSnippet{}, comment.Comment{}, err
+	}
+	if c.Sid != sid {
+		return codesnippet.CodeSnippet{}, comment.Comment{}, comment.ErrNotFound
+	}
+	return s, c, nil
+}
+
+func (u *UseCases) SetCommentHidden(a account.Account, sid, cid uint, hidden bool) error {
+	s, _, err := u.snippetComment(sid, cid)
+	if err != nil {
+		return err
+	}
+	moderator, err := u.isModerator(a, s)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return ErrNotModerator
+	}
+	return u.CommentStorage.SetCommentHidden(cid, hidden)
+}
+
+// DeleteComment lets the author and the moderators remove a comment along
+// with its replies.
+func (u *UseCases) DeleteComment(a account.Account, sid, cid uint) error {
+	s, c, err := u.snippetComment(sid, cid)
+	if err != nil {
+		return err
+	}
+	if c.Uid != a.Id {
+		moderator, err := u.isModerator(a, s)
+		if err != nil {
+			return err
+		}
+		if !moderator {
+			return ErrNotModerator
+		}
+	}
+	return u.CommentStorage.DeleteComment(cid)
+}
@@ -0,0 +1,52 @@
+package codesnippet
+
+import (
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	webhookuc "github.com/mp-hl-2021/code-swamp/internal/usecases/webhook"
+)
+
+// publish hands the event to the webhooks of the snippet owner. Anonymous
+// snippets have nobody to notify. A failure to queue the event does not fail
+// the change that caused it.
+func (u *UseCases) publish(e webhook.Event, sid uint, s codesnippet.CodeSnippet) {
+	if u.Webhooks == nil || !s.HasUser {
+		return
+	}
+	err := u.Webhooks.Publish(webhookuc.SnippetEvent{
+		Event:      e,
+		Sid:        sid,
+		Uid:        s.Uid,
+		Tid:        s.Tid,
+		HasTeam:    s.HasTeam,
+		Lang:       s.Lang,
+		LintStatus: string(s.Lint.Status),
+		Message:    s.Message,
+	})
+	if err != nil {
+		fmt.Printf("Error publishing %s for snippet %d: %s\n", e, sid, err)
+	}
+}
+
+func (u *UseCases) deleteExpiredSnippets() error {
+	expired, err := u.CodeSnippetStorage.DeleteExpiredSnippets()
+	if err != nil {
+		return err
+	}
+	for _, e := range expired {
+		u.publish(webhook.SnippetExpired, e.Sid, codesnippet.CodeSnippet{
+			Uid:     e.Uid,
+			HasUser: e.HasUser,
+			Tid:     e.Tid,
+			HasTeam: e.HasTeam,
+		})
+	}
+	return nil
+}
+
+// ExpireSnippets removes the expired snippets right away rather than on the
+// next read, so that snippet.expired is sent on time.
+func (u *UseCases) ExpireSnippets() error {
+	return u.deleteExpiredSnippets()
+}
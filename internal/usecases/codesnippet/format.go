@@ -0,0 +1,29 @@
+package codesnippet
+
+import (
+	"context"
+	"github.com/mp-hl-2021/code-swamp/internal/service/formatter"
+)
+
+// FormatCode formats the code with the formatter for the language. It fails
+// with a *formatter.Error when the formatter cannot, nothing is stored.
+func (u *UseCases) FormatCode(lang string, code string) (string, error) {
+	if err := validateLanguage(lang); err != nil {
+		return "", err
+	}
+	if u.Formatters == nil {
+		return "", formatter.ErrUnsupportedLanguage
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), u.lintRequestTimeout())
+	defer cancel()
+	return u.Formatters.Format(ctx, lang, code)
+}
+
+// GetFormattedCode formats the snippet as it is stored.
+func (u *UseCases) GetFormattedCode(sid uint) (string, error) {
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		return "", err
+	}
+	return u.FormatCode(s.Lang, s.Code)
+}
@@ -0,0 +1,202 @@
+package codesnippet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCodeTooLong = errors.New("code is too long to lint")
+)
+
+const (
+	maxLintCodeLength         = 64 * 1024
+	defaultLintTimeout        = 10 * time.Second
+	defaultLintRequestTimeout = 5 * time.Second
+)
+
+func (u *UseCases) lintTimeout() time.Duration {
+	if u.LintTimeout == 0 {
+		return defaultLintTimeout
+	}
+	return u.LintTimeout
+}
+
+func (u *UseCases) lintRequestTimeout() time.Duration {
+	if u.LintRequestTimeout == 0 {
+		return defaultLintRequestTimeout
+	}
+	return u.LintRequestTimeout
+}
+
+func (u *UseCases) validateLintOptions(opts linter.Options) error {
+	if len(opts) == 0 {
+		return nil
+	}
+	if u.Linters == nil {
+		return linter.ErrInvalidOption
+	}
+	return u.Linters.Validate(opts)
+}
+
+// resolveLintOptions lays the options given with a request over the account
+// default, so that the result can be stored and linting repeated later.
+func (u *UseCases) resolveLintOptions(a *account.Account, opts linter.Options) (linter.Options, error) {
+	if err := u.validateLintOptions(opts); err != nil {
+		return nil, err
+	}
+	defaults, err := u.GetDefaultLintOptions(a)
+	if err != nil {
+		return nil, err
+	}
+	return defaults.Merge(opts), nil
+}
+
+func (u *UseCases) GetDefaultLintOptions(a *account.Account) (linter.Options, error) {
+	if a == nil || u.LintOptionsStorage == nil {
+		return linter.Options{}, nil
+	}
+	opts, err := u.LintOptionsStorage.GetDefaultLintOptions(a.Id)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		return linter.Options{}, nil
+	}
+	return opts, nil
+}
+
+func (u *UseCases) SetDefaultLintOptions(a account.Account, opts linter.Options) error {
+	if err := u.validateLintOptions(opts); err != nil {
+		return err
+	}
+	return u.LintOptionsStorage.SetDefaultLintOptions(a.Id, opts)
+}
+
+// Lint runs the linters configured for the language and returns what they
+// found. Nothing is stored.
+func (u *UseCases) Lint(a *account.Account, code string, lang string, opts linter.Options) ([]linter.Report, error) {
+	if err := validateLanguage(lang); err != nil {
+		return nil, err
+	}
+	if len(code) > maxLintCodeLength {
+		return nil, ErrCodeTooLong
+	}
+	opts, err := u.resolveLintOptions(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	return u.runLinters(code, lang, opts, u.lintRequestTimeout()), nil
+}
+
+func (u *UseCases) runLinters(code string, lang string, opts linter.Options, timeout time.Duration) []linter.Report {
+	if u.Linters == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return u.Linters.Lint(ctx, lang, code, opts)
+}
+
+// GetCanonicalCode pretty prints the snippet, in languages a linter knows
+// the canonical form of.
+func (u *UseCases) GetCanonicalCode(sid uint) (string, error) {
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		return "", err
+	}
+	if u.Linters == nil {
+		return "", linter.ErrNoCanonicalForm
+	}
+	return u.Linters.Canonical(s.Lang, s.Code)
+}
+
+// currentLinters names the linters code in the language is linted with now
+// and their versions, the way lint stores them.
+func (u *UseCases) currentLinters(lang string) ([]linter.Linter, string, string) {
+	if u.Linters == nil {
+		return nil, "", ""
+	}
+	linters := u.Linters.For(lang)
+	names := make([]string, len(linters))
+	versions := make([]string, len(linters))
+	for i, l := range linters {
+		names[i], versions[i] = l.Name(), l.Version()
+	}
+	return linters, strings.Join(names, ", "), strings.Join(versions, ", ")
+}
+
+// lint runs the linters for the language, telling that it started first, and
+// returns what to store with the snippet.
+func (u *UseCases) lint(sid uint, code string, lang string, opts linter.Options) (codesnippet.Lint, string, error) {
+	now := time.Now()
+	l := codesnippet.Lint{StartedAt: now, FinishedAt: now}
+	if u.Linters == nil {
+		l.Status = codesnippet.LintSkipped
+		l.Error = "linting is disabled"
+		return l, "", nil
+	}
+	var linters []linter.Linter
+	linters, l.Linter, l.LinterVersion = u.currentLinters(lang)
+	if len(linters) == 0 {
+		l.Status = codesnippet.LintUnsupported
+		return l, "", nil
+	}
+	if len(code) > maxLintCodeLength {
+		l.Status = codesnippet.LintSkipped
+		l.Error = ErrCodeTooLong.Error()
+		return l, "", nil
+	}
+	l.Status = codesnippet.LintRunning
+	l.FinishedAt = time.Time{}
+	if err := u.CodeSnippetStorage.SetLint(sid, l, ""); err != nil {
+		return codesnippet.Lint{}, "", err
+	}
+	u.publishLintEvent(sid, lintevent.Running)
+
+	reports := u.runLinters(code, lang, opts, u.lintTimeout())
+	l.FinishedAt = time.Now()
+	l.Status, l.Error = lintOutcome(reports)
+	return l, lintMessage(reports), nil
+}
+
+// lintOutcome fails the lint if any linter failed, findings of the others
+// notwithstanding.
+func lintOutcome(reports []linter.Report) (codesnippet.LintStatus, string) {
+	var errs []string
+	found := false
+	for _, r := range reports {
+		if r.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: %v", r.Linter, r.Status, r.Err))
+		}
+		if len(r.Findings) > 0 {
+			found = true
+		}
+	}
+	switch {
+	case len(errs) > 0:
+		return codesnippet.LintFailed, strings.Join(errs, "; ")
+	case found:
+		return codesnippet.LintFindings, ""
+	default:
+		return codesnippet.LintPassed, ""
+	}
+}
+
+// lintMessage lists the findings a line each, to be stored with the snippet.
+func lintMessage(reports []linter.Report) string {
+	var b strings.Builder
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "%s: %d-%d: %s\n", r.Linter, f.Line, f.EndLine, f.Message)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,283 @@
+package codesnippet
+
+import (
+	"context"
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/linteventrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/service/linter"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLinter finds a clone in code mentioning "clone" and fails on "crash".
+type fakeLinter struct{}
+
+func (fakeLinter) Name() string              { return "fake" }
+func (fakeLinter) Version() string           { return "1.0" }
+func (fakeLinter) Supports(lang string) bool { return strings.EqualFold(lang, "Go") }
+func (fakeLinter) Options() []linter.Option {
+	return []linter.Option{{Name: "threshold", Min: 1, Max: 100}}
+}
+func (fakeLinter) Lint(ctx context.Context, code string, opts map[string]string) ([]linter.Finding, error) {
+	if strings.Contains(code, "crash") {
+		return nil, &linter.RunError{Status: linter.StatusExited, Err: errors.New("exit status 2")}
+	}
+	if strings.Contains(code, "clone") {
+		return []linter.Finding{{Line: 1, EndLine: 2, Message: "duplicate of lines 3-4"}}, nil
+	}
+	return nil, nil
+}
+
+func check(t *testing.T, u *UseCases, code string, lang string) codesnippet.CodeSnippet {
+	sid, err := u.CreateSnippet(nil, code, lang, 3600e9, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Lint.Status != codesnippet.LintPending || s.Lint.Status.Done() {
+		t.Errorf("new snippet MUST be pending, but %s given", s.Lint.Status)
+	}
+	if err := u.CheckCode(sid, code, lang, nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err = u.GetSnippetById(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCheckCode(t *testing.T) {
+	u := &UseCases{
+		CodeSnippetStorage: codesnippetrepo.NewMemory(),
+		Linters:            linter.NewRegistry(fakeLinter{}),
+		CodeCheckChannel:   make(chan CheckCodeRequest, 10),
+	}
+	for code, want := range map[string]codesnippet.LintStatus{
+		"package main":                 codesnippet.LintPassed,
+		"clone":                        codesnippet.LintFindings,
+		"crash":                        codesnippet.LintFailed,
+		strings.Repeat("x", 64*1024+1): codesnippet.LintSkipped,
+	} {
+		s := check(t, u, code, "Go")
+		if s.Lint.Status != want {
+			t.Errorf("lint MUST end %s, but %s given", want, s.Lint.Status)
+		}
+		if want == codesnippet.LintSkipped {
+			continue
+		}
+		if s.Lint.Linter != "fake" || s.Lint.LinterVersion != "1.0" || s.Lint.StartedAt.IsZero() || s.Lint.FinishedAt.Before(s.Lint.StartedAt) {
+			t.Errorf("lint MUST record the linter and when it ran, but %+v given", s.Lint)
+		}
+	}
+
+	s := check(t, u, "crash", "Go")
+	if s.Lint.Error != "fake exited: exit status 2" || s.Message != "" {
+		t.Errorf("failed lint MUST keep the error apart from findings, but %+v and %q given", s.Lint, s.Message)
+	}
+	s = check(t, u, "clone", "Go")
+	if s.Lint.Error != "" || !strings.Contains(s.Message, "duplicate of lines 3-4") {
+		t.Errorf("findings MUST be listed in the message, but %+v and %q given", s.Lint, s.Message)
+	}
+	if s := check(t, u, "clone", "python"); s.Lint.Status != codesnippet.LintUnsupported || s.Lint.Linter != "" {
+		t.Errorf("lint of unsupported language MUST end %s, but %+v given", codesnippet.LintUnsupported, s.Lint)
+	}
+
+	u.Linters = nil
+	if s := check(t, u, "clone", "Go"); s.Lint.Status != codesnippet.LintSkipped {
+		t.Errorf("lint without linters MUST end %s, but %s given", codesnippet.LintSkipped, s.Lint.Status)
+	}
+}
+
+func TestRelint(t *testing.T) {
+	ch := make(chan CheckCodeRequest, 10)
+	u := &UseCases{
+		CodeSnippetStorage: codesnippetrepo.NewMemory(),
+		Linters:            linter.NewRegistry(fakeLinter{}),
+		CodeCheckChannel:   ch,
+		Admins:             []uint{1},
+		RelintInterval:     time.Millisecond,
+	}
+	owner, stranger := account.Account{Id: 1}, account.Account{Id: 2}
+	opts := linter.Options{"fake": {"threshold": "20"}}
+	sid, err := u.CreateSnippet(&owner, "clone", "go", time.Hour, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	if err := u.CheckCode(sid, "clone", "go", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Relint(stranger, sid); err != ErrNotSnippetOwner {
+		t.Errorf("Relint by a stranger MUST return %v, but %v given", ErrNotSnippetOwner, err)
+	}
+	if err := u.Relint(owner, sid); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := u.GetSnippetById(sid); s.Lint.Status != codesnippet.LintPending {
+		t.Errorf("re-linted snippet MUST be pending, but %s given", s.Lint.Status)
+	}
+	if c := <-ch; c.Sid != sid || c.Code != "clone" {
+		t.Errorf("re-linted snippet MUST be queued, but %+v given", c)
+	}
+	if err := u.CheckCode(sid, "clone", "go", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := u.RelintStale(stranger); err != ErrNotAdmin {
+		t.Errorf("RelintStale by a stranger MUST return %v, but %v given", ErrNotAdmin, err)
+	}
+	if n, err := u.RelintStale(owner); err != nil || n != 0 {
+		t.Errorf("snippet linted by the current linters MUST NOT be stale, but %d, %v given", n, err)
+	}
+	u.Linters = linter.NewRegistry(upgradedLinter{})
+	for atomic.LoadInt32(&u.relinting) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	n, err := u.RelintStale(owner)
+	if err != nil || n != 1 {
+		t.Fatalf("snippet linted by another version MUST be stale, but %d, %v given", n, err)
+	}
+	if _, err := u.RelintStale(owner); err != ErrRelintInProgress {
+		t.Errorf("second RelintStale MUST return %v, but %v given", ErrRelintInProgress, err)
+	}
+	c := <-ch
+	if c.Sid != sid || c.Options["fake"]["threshold"] != "20" {
+		t.Errorf("stale snippet MUST be queued with its options, but %+v given", c)
+	}
+}
+
+func TestRelintStuck(t *testing.T) {
+	ch := make(chan CheckCodeRequest, 10)
+	u := &UseCases{
+		CodeSnippetStorage: codesnippetrepo.NewMemory(),
+		Linters:            linter.NewRegistry(fakeLinter{}),
+		LintTimeout:        time.Minute,
+		CodeCheckChannel:   ch,
+		Admins:             []uint{1},
+		RelintInterval:     time.Millisecond,
+	}
+	owner := account.Account{Id: 1}
+	sid, err := u.CreateSnippet(&owner, "x", "go", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+	running := codesnippet.Lint{Status: codesnippet.LintRunning, StartedAt: time.Now()}
+	if err := u.CodeSnippetStorage.SetLint(sid, running, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Relint(owner, sid); err != ErrLintInProgress {
+		t.Errorf("Relint of a running snippet MUST return %v, but %v given", ErrLintInProgress, err)
+	}
+	if n, err := u.RelintStale(owner); err != nil || n != 0 {
+		t.Errorf("snippet running within the timeout MUST NOT be stale, but %d, %v given", n, err)
+	}
+	for atomic.LoadInt32(&u.relinting) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	running.StartedAt = time.Now().Add(-2 * time.Minute)
+	if err := u.CodeSnippetStorage.SetLint(sid, running, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := u.RelintStale(owner); err != nil || n != 1 {
+		t.Errorf("snippet running past the timeout MUST be stale, but %d, %v given", n, err)
+	}
+	if c := <-ch; c.Sid != sid {
+		t.Errorf("stuck snippet MUST be queued, but %+v given", c)
+	}
+	for atomic.LoadInt32(&u.relinting) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := u.Relint(owner, sid); err != ErrLintInProgress {
+		t.Errorf("Relint of a snippet queued again MUST return %v, but %v given", ErrLintInProgress, err)
+	}
+}
+
+// TestRelintNeverStarted covers a snippet the queue lost before a worker
+// took it, after a restart say.
+func TestRelintNeverStarted(t *testing.T) {
+	ch := make(chan CheckCodeRequest, 10)
+	u := &UseCases{
+		CodeSnippetStorage: codesnippetrepo.NewMemory(),
+		Linters:            linter.NewRegistry(fakeLinter{}),
+		LintTimeout:        50 * time.Millisecond,
+		CodeCheckChannel:   ch,
+		Admins:             []uint{1},
+		RelintInterval:     time.Millisecond,
+	}
+	owner := account.Account{Id: 1}
+	sid, err := u.CreateSnippet(&owner, "x", "go", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lost
+	<-ch
+	if err := u.Relint(owner, sid); err != ErrLintInProgress {
+		t.Errorf("Relint of a snippet pending within the timeout MUST return %v, but %v given", ErrLintInProgress, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := u.Relint(owner, sid); err != nil {
+		t.Fatalf("Relint of a snippet pending past the timeout MUST queue it, but %v given", err)
+	}
+	if c := <-ch; c.Sid != sid {
+		t.Errorf("pending snippet MUST be queued, but %+v given", c)
+	}
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Lint.Status != codesnippet.LintPending || !s.Lint.StartedAt.IsZero() {
+		t.Errorf("queued snippet MUST be pending and not started, but %+v given", s.Lint)
+	}
+	if n, err := u.RelintStale(owner); err != nil || n != 1 {
+		t.Errorf("snippet pending past the timeout MUST be stale, but %d, %v given", n, err)
+	}
+	if c := <-ch; c.Sid != sid {
+		t.Errorf("stale snippet MUST be queued, but %+v given", c)
+	}
+	for atomic.LoadInt32(&u.relinting) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type upgradedLinter struct {
+	fakeLinter
+}
+
+func (upgradedLinter) Version() string { return "2.0" }
+
+func TestWatchLintStatus(t *testing.T) {
+	u := &UseCases{
+		CodeSnippetStorage: codesnippetrepo.NewMemory(),
+		LintEvents:         linteventrepo.NewMemory(),
+		CodeCheckChannel:   make(chan CheckCodeRequest, 1),
+	}
+	sid, err := u.CreateSnippet(nil, "x", "go", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.CodeSnippetStorage.SetLint(sid, codesnippet.Lint{Status: codesnippet.LintRunning}, ""); err != nil {
+		t.Fatal(err)
+	}
+	statuses, cancel, err := u.WatchLintStatus(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if ls := <-statuses; ls.Status != lintevent.Running {
+		t.Errorf("running snippet MUST start with %s, but %s given", lintevent.Running, ls.Status)
+	}
+}
@@ -0,0 +1,131 @@
+package codesnippet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotSnippetOwner  = errors.New("only the snippet owner may re-lint it")
+	ErrNotAdmin         = errors.New("only admins may do that")
+	ErrRelintInProgress = errors.New("stale snippets are being re-linted already")
+	ErrLintInProgress   = errors.New("snippet is being linted")
+)
+
+const defaultRelintInterval = time.Second
+
+func (u *UseCases) relintInterval() time.Duration {
+	if u.RelintInterval == 0 {
+		return defaultRelintInterval
+	}
+	return u.RelintInterval
+}
+
+func (u *UseCases) isAdmin(a account.Account) bool {
+	for _, id := range u.Admins {
+		if id == a.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// Relint lints the snippet again with its stored options. Maintainers may
+// re-lint every snippet of their team, as they may delete it.
+func (u *UseCases) Relint(a account.Account, sid uint) error {
+	s, err := u.CodeSnippetStorage.GetCodeSnippetById(sid)
+	if err != nil {
+		return err
+	}
+	if !s.HasUser || s.Uid != a.Id {
+		if !s.HasTeam {
+			return ErrNotSnippetOwner
+		}
+		role, err := u.teamRole(a, s.Tid)
+		if err == team.ErrNotFound || (err == nil && !role.AtLeast(team.Maintainer)) {
+			return ErrNotSnippetOwner
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !s.Lint.Status.Done() && !u.lintStuck(s) {
+		return ErrLintInProgress
+	}
+	return u.requeue(sid, s)
+}
+
+// lintStuck tells a lint that should have ended by now, its worker or the
+// queue it was in is gone. A lint that never started counts from when the
+// snippet was created, as it does for GetStaleLintSnippetIds.
+func (u *UseCases) lintStuck(s codesnippet.CodeSnippet) bool {
+	started := s.Lint.StartedAt
+	if started.IsZero() {
+		started = s.CreatedAt
+	}
+	return started.Before(time.Now().Add(-u.lintTimeout()))
+}
+
+// queued is the lint of a snippet queued again, it starts once a worker
+// takes it.
+func queued() codesnippet.Lint {
+	return codesnippet.Lint{Status: codesnippet.LintPending}
+}
+
+func (u *UseCases) requeue(sid uint, s codesnippet.CodeSnippet) error {
+	if err := u.CodeSnippetStorage.SetLint(sid, queued(), ""); err != nil {
+		return err
+	}
+	u.checkLater(sid, s.Code, s.Lang, s.LintOptions)
+	return nil
+}
+
+// RelintStale queues every live snippet linted with other linters or linter
+// versions than the configured ones, or stuck linting for longer than the
+// lint timeout, one per RelintInterval, and reports how many there are. It
+// returns before they are queued.
+func (u *UseCases) RelintStale(a account.Account) (int, error) {
+	if !u.isAdmin(a) {
+		return 0, ErrNotAdmin
+	}
+	if !atomic.CompareAndSwapInt32(&u.relinting, 0, 1) {
+		return 0, ErrRelintInProgress
+	}
+	var ids []uint
+	for _, lang := range append([]string{""}, SupportedLanguages...) {
+		_, linters, versions := u.currentLinters(lang)
+		stale, err := u.CodeSnippetStorage.GetStaleLintSnippetIds(lang, linters, versions, time.Now().Add(-u.lintTimeout()))
+		if err != nil {
+			atomic.StoreInt32(&u.relinting, 0)
+			return 0, err
+		}
+		ids = append(ids, stale...)
+	}
+	go func() {
+		defer atomic.StoreInt32(&u.relinting, 0)
+		tick := time.NewTicker(u.relintInterval())
+		defer tick.Stop()
+		for _, sid := range ids {
+			<-tick.C
+			s, err := u.CodeSnippetStorage.GetCodeSnippetById(sid)
+			// expired or deleted in the meantime
+			if err != nil {
+				continue
+			}
+			if err := u.CodeSnippetStorage.SetLint(sid, queued(), ""); err != nil {
+				fmt.Printf("Error re-linting snippet %d: %s\n", sid, err)
+				continue
+			}
+			u.publishLintEvent(sid, lintevent.Queued)
+			// wait for the queue rather than pile goroutines up behind it
+			u.CodeCheckChannel <- CheckCodeRequest{sid, s.Code, s.Lang, s.LintOptions}
+		}
+	}()
+	return len(ids), nil
+}
@@ -0,0 +1,73 @@
+package codesnippet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+)
+
+var (
+	ErrInvalidPage = errors.New("page limit and offset should not be negative")
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Page selects Limit ids starting at Offset. A zero Limit selects
+// DefaultPageLimit ids.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+func (p Page) validate() error {
+	if p.Limit < 0 || p.Offset < 0 || p.Limit > MaxPageLimit {
+		return ErrInvalidPage
+	}
+	return nil
+}
+
+func (p Page) limit() int {
+	if p.Limit == 0 {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+func (u *UseCases) StarSnippet(a account.Account, sid uint) error {
+	if _, err := u.GetSnippetById(sid); err != nil {
+		return err
+	}
+	fmt.Printf("StarSnippet: %d\n", sid)
+	_, err := u.StarStorage.Star(a.Id, sid)
+	return err
+}
+
+func (u *UseCases) UnstarSnippet(a account.Account, sid uint) error {
+	fmt.Printf("UnstarSnippet: %d\n", sid)
+	_, err := u.StarStorage.Unstar(a.Id, sid)
+	return err
+}
+
+func (u *UseCases) GetStarredSnippetIds(a account.Account, p Page) ([]uint, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := u.deleteExpiredSnippets(); err != nil {
+		return nil, err
+	}
+	ids, err := u.StarStorage.GetStarredSnippetIds(a.Id, p.limit(), p.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		return []uint{}, nil
+	}
+	return ids, nil
+}
+
+func (u *UseCases) GetStarCount(sid uint) (uint, error) {
+	return u.StarStorage.GetStarCount(sid)
+}
@@ -0,0 +1,93 @@
+package codesnippet
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/starrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/usecases/account"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPages(t *testing.T) {
+	snippets := codesnippetrepo.NewMemory()
+	u := &UseCases{
+		CodeSnippetStorage: snippets,
+		StarStorage:        starrepo.NewMemory(snippets),
+		CodeCheckChannel:   make(chan CheckCodeRequest, 30),
+	}
+	a := account.Account{Id: 1}
+	for i := 0; i < 25; i++ {
+		sid, err := u.CreateSnippet(&a, "x", "go", time.Hour, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.StarSnippet(a, sid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ids, err := u.GetMySnippetIds(a, Page{}); err != nil || len(ids) != DefaultPageLimit || ids[0] != 0 {
+		t.Errorf("zero limit MUST select the first %d snippets, but %v (%v) given", DefaultPageLimit, ids, err)
+	}
+	if ids, err := u.GetMySnippetIds(a, Page{Limit: 2, Offset: 3}); err != nil || !reflect.DeepEqual(ids, []uint{3, 4}) {
+		t.Errorf("page MUST select snippets 3 and 4, but %v (%v) given", ids, err)
+	}
+	if ids, err := u.GetMySnippetIds(a, Page{Offset: 30}); err != nil || ids == nil || len(ids) != 0 {
+		t.Errorf("page past the end MUST be empty, but %v (%v) given", ids, err)
+	}
+	if ids, err := u.GetStarredSnippetIds(a, Page{}); err != nil || len(ids) != DefaultPageLimit {
+		t.Errorf("zero limit MUST select %d stars, but %v (%v) given", DefaultPageLimit, ids, err)
+	}
+	if ids, err := u.GetStarredSnippetIds(a, Page{Limit: 3, Offset: 23}); err != nil || len(ids) != 2 {
+		t.Errorf("last page MUST select the remaining 2 stars, but %v (%v) given", ids, err)
+	}
+	if _, err := u.GetStarredSnippetIds(a, Page{Limit: MaxPageLimit + 1}); err != ErrInvalidPage {
+		t.Errorf("limit over %d MUST return %v, but %v given", MaxPageLimit, ErrInvalidPage, err)
+	}
+}
+
+func TestStarsOfRemovedSnippets(t *testing.T) {
+	snippets := codesnippetrepo.NewMemory()
+	u := &UseCases{
+		CodeSnippetStorage: snippets,
+		StarStorage:        starrepo.NewMemory(snippets),
+		CodeCheckChannel:   make(chan CheckCodeRequest, 10),
+	}
+	owner, fan := account.Account{Id: 1}, account.Account{Id: 2}
+	deleted, err := u.CreateSnippet(&owner, "x", "go", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := snippets.CreateCodeSnippetWithUser(codesnippet.CodeSnippet{Code: "y", Lang: "go", Lifetime: 50 * time.Millisecond}, owner.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sid := range []uint{deleted, expired} {
+		for _, a := range []account.Account{owner, fan} {
+			if err := u.StarSnippet(a, sid); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := u.DeleteSnippet(owner, deleted); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := snippets.DeleteExpiredSnippets(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sid := range []uint{deleted, expired} {
+		if c, err := u.StarStorage.GetStarCount(sid); err != nil || c != 0 {
+			t.Errorf("star count of removed snippet %d MUST be 0, but %d (%v) given", sid, c, err)
+		}
+		if ok, err := u.StarStorage.IsStarred(fan.Id, sid); err != nil || ok {
+			t.Errorf("star of removed snippet %d MUST be dropped, but %v (%v) given", sid, ok, err)
+		}
+	}
+	if ids, err := u.GetStarredSnippetIds(fan, Page{}); err != nil || len(ids) != 0 {
+		t.Errorf("no removed snippet MUST stay starred, but %v (%v) given", ids, err)
+	}
+}
@@ -0,0 +1,93 @@
+package codesnippet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/codesnippet"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/lintevent"
+	"sync"
+)
+
+var (
+	ErrLiveStatusUnavailable = errors.New("live lint status is unavailable")
+)
+
+// LintStatus is a step of linting a snippet. The one with the Checked
+// status is the last and carries the result.
+type LintStatus struct {
+	Status  lintevent.Status
+	Lint    codesnippet.Lint
+	Message string
+}
+
+func (u *UseCases) publishLintEvent(sid uint, status lintevent.Status) {
+	if u.LintEvents == nil {
+		return
+	}
+	if err := u.LintEvents.Publish(lintevent.Event{Sid: sid, Status: status}); err != nil {
+		fmt.Printf("Error publishing lint status %s for snippet %d: %s\n", status, sid, err)
+	}
+}
+
+// WatchLintStatus streams the lint status of a snippet until it is checked
+// or cancel is called. It starts with the stored status, so a snippet that is
+// already checked yields its result right away.
+func (u *UseCases) WatchLintStatus(sid uint) (<-chan LintStatus, func(), error) {
+	if u.LintEvents == nil {
+		return nil, nil, ErrLiveStatusUnavailable
+	}
+	// subscribe first, so that a check finishing in between is not missed
+	events, unsubscribe := u.LintEvents.Subscribe(sid)
+	s, err := u.GetSnippetById(sid)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, err
+	}
+	statuses := make(chan LintStatus)
+	stop := make(chan struct{})
+	once := &sync.Once{}
+	cancel := func() {
+		once.Do(func() {
+			unsubscribe()
+			close(stop)
+		})
+	}
+	send := func(ls LintStatus) bool {
+		select {
+		case statuses <- ls:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	go func() {
+		defer close(statuses)
+		if s.Lint.Status.Done() {
+			send(LintStatus{Status: lintevent.Checked, Lint: s.Lint, Message: s.Message})
+			return
+		}
+		// a client reconnecting mid check gets where it is
+		first := lintevent.Queued
+		if s.Lint.Status == codesnippet.LintRunning {
+			first = lintevent.Running
+		}
+		if !send(LintStatus{Status: first}) {
+			return
+		}
+		for e := range events {
+			if e.Status != lintevent.Checked {
+				if !send(LintStatus{Status: e.Status}) {
+					return
+				}
+				continue
+			}
+			s, err := u.CodeSnippetStorage.GetCodeSnippetById(sid)
+			if err != nil {
+				return
+			}
+			send(LintStatus{Status: lintevent.Checked, Lint: s.Lint, Message: s.Message})
+			return
+		}
+	}()
+	return statuses, cancel, nil
+}
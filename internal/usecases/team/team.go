@@ -0,0 +1,308 @@
+package team
+
+import (
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidTeamName = errors.New("team name is invalid")
+	ErrInvalidRole     = errors.New("role is invalid")
+	ErrForbidden       = errors.New("role does not allow this")
+	ErrLastOwner       = errors.New("team needs at least one owner")
+)
+
+const (
+	minTeamNameLength = 3
+	maxTeamNameLength = 64
+
+	defaultInvitationLifetime = 7 * 24 * time.Hour
+)
+
+type Team struct {
+	Id        uint
+	Name      string
+	Role      team.Role
+	CreatedAt time.Time
+}
+
+type Member struct {
+	Uid      uint
+	Role     team.Role
+	JoinedAt time.Time
+}
+
+type Invitation struct {
+	Id        uint
+	Tid       uint
+	TeamName  string
+	Uid       uint
+	Role      team.Role
+	InvitedBy uint
+	ExpiresAt time.Time
+}
+
+type Interface interface {
+	CreateTeam(uid uint, name string) (Team, error)
+	GetMyTeams(uid uint) ([]Team, error)
+	GetTeam(uid, tid uint) (Team, []Member, error)
+	DeleteTeam(uid, tid uint) error
+
+	InviteMember(uid, tid, invitee uint, role team.Role) (Invitation, error)
+	GetMyInvitations(uid uint) ([]Invitation, error)
+	AcceptInvitation(uid, id uint) (Team, error)
+	DeclineInvitation(uid, id uint) error
+
+	SetMemberRole(uid, tid, member uint, role team.Role) error
+	RemoveMember(uid, tid, member uint) error
+}
+
+type UseCases struct {
+	TeamStorage        team.Interface
+	InvitationLifetime time.Duration
+}
+
+func (u *UseCases) invitationLifetime() time.Duration {
+	if u.InvitationLifetime == 0 {
+		return defaultInvitationLifetime
+	}
+	return u.InvitationLifetime
+}
+
+// membership hides teams from those outside of them, so a non member gets
+// ErrNotFound rather than ErrForbidden.
+func (u *UseCases) membership(uid, tid uint, atLeast team.Role) (team.Membership, error) {
+	ms, err := u.TeamStorage.GetMembership(tid, uid)
+	if err == team.ErrMemberNotFound {
+		return team.Membership{}, team.ErrNotFound
+	}
+	if err != nil {
+		return team.Membership{}, err
+	}
+	if !ms.Role.AtLeast(atLeast) {
+		return team.Membership{}, ErrForbidden
+	}
+	return ms, nil
+}
+
+func (u *UseCases) CreateTeam(uid uint, name string) (Team, error) {
+	if err := validateTeamName(name); err != nil {
+		return Team{}, err
+	}
+	t, err := u.TeamStorage.CreateTeam(name, uid)
+	if err != nil {
+		return Team{}, err
+	}
+	return Team{Id: t.Id, Name: t.Name, Role: team.Owner, CreatedAt: t.CreatedAt}, nil
+}
+
+func (u *UseCases) GetMyTeams(uid uint) ([]Team, error) {
+	tt, mm, err := u.TeamStorage.GetTeamsByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]Team, len(tt))
+	for i, t := range tt {
+		teams[i] = Team{Id: t.Id, Name: t.Name, Role: mm[i].Role, CreatedAt: t.CreatedAt}
+	}
+	return teams, nil
+}
+
+func (u *UseCases) GetTeam(uid, tid uint) (Team, []Member, error) {
+	ms, err := u.membership(uid, tid, team.Member)
+	if err != nil {
+		return Team{}, nil, err
+	}
+	t, err := u.TeamStorage.GetTeamById(tid)
+	if err != nil {
+		return Team{}, nil, err
+	}
+	mm, err := u.TeamStorage.GetMembers(tid)
+	if err != nil {
+		return Team{}, nil, err
+	}
+	members := make([]Member, len(mm))
+	for i, m := range mm {
+		members[i] = Member{Uid: m.Uid, Role: m.Role, JoinedAt: m.JoinedAt}
+	}
+	return Team{Id: t.Id, Name: t.Name, Role: ms.Role, CreatedAt: t.CreatedAt}, members, nil
+}
+
+func (u *UseCases) DeleteTeam(uid, tid uint) error {
+	if _, err := u.membership(uid, tid, team.Owner); err != nil {
+		return err
+	}
+	return u.TeamStorage.DeleteTeam(tid)
+}
+
+// InviteMember needs a maintainer, who may not hand out a role above their
+// own.
+func (u *UseCases) InviteMember(uid, tid, invitee uint, role team.Role) (Invitation, error) {
+	if !role.IsValid() {
+		return Invitation{}, ErrInvalidRole
+	}
+	ms, err := u.membership(uid, tid, team.Maintainer)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if !ms.Role.AtLeast(role) {
+		return Invitation{}, ErrForbidden
+	}
+	t, err := u.TeamStorage.GetTeamById(tid)
+	if err != nil {
+		return Invitation{}, err
+	}
+	now := time.Now()
+	i, err := u.TeamStorage.CreateInvitation(team.Invitation{
+		Tid:       tid,
+		Uid:       invitee,
+		Role:      role,
+		InvitedBy: uid,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.invitationLifetime()),
+	})
+	if err != nil {
+		return Invitation{}, err
+	}
+	return toInvitation(i, t.Name), nil
+}
+
+func toInvitation(i team.Invitation, teamName string) Invitation {
+	return Invitation{
+		Id:        i.Id,
+		Tid:       i.Tid,
+		TeamName:  teamName,
+		Uid:       i.Uid,
+		Role:      i.Role,
+		InvitedBy: i.InvitedBy,
+		ExpiresAt: i.ExpiresAt,
+	}
+}
+
+func (u *UseCases) GetMyInvitations(uid uint) ([]Invitation, error) {
+	ii, err := u.TeamStorage.GetInvitationsByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitations := make([]Invitation, 0, len(ii))
+	for _, i := range ii {
+		if now.After(i.ExpiresAt) {
+			continue
+		}
+		t, err := u.TeamStorage.GetTeamById(i.Tid)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, toInvitation(i, t.Name))
+	}
+	return invitations, nil
+}
+
+func (u *UseCases) AcceptInvitation(uid, id uint) (Team, error) {
+	i, err := u.TeamStorage.GetInvitationById(id)
+	if err != nil {
+		return Team{}, err
+	}
+	if i.Uid != uid {
+		return Team{}, team.ErrInvitationNotFound
+	}
+	if time.Now().After(i.ExpiresAt) {
+		if err := u.TeamStorage.DeleteInvitation(id); err != nil && err != team.ErrInvitationNotFound {
+			return Team{}, err
+		}
+		return Team{}, team.ErrInvitationNotFound
+	}
+	ms, err := u.TeamStorage.AcceptInvitation(id)
+	if err != nil {
+		return Team{}, err
+	}
+	t, err := u.TeamStorage.GetTeamById(ms.Tid)
+	if err != nil {
+		return Team{}, err
+	}
+	return Team{Id: t.Id, Name: t.Name, Role: ms.Role, CreatedAt: t.CreatedAt}, nil
+}
+
+// DeclineInvitation lets the invitee decline and a maintainer of the team
+// withdraw the invitation.
+func (u *UseCases) DeclineInvitation(uid, id uint) error {
+	i, err := u.TeamStorage.GetInvitationById(id)
+	if err != nil {
+		return err
+	}
+	if i.Uid != uid {
+		if _, err := u.membership(uid, i.Tid, team.Maintainer); err != nil {
+			return team.ErrInvitationNotFound
+		}
+	}
+	return u.TeamStorage.DeleteInvitation(id)
+}
+
+// SetMemberRole needs a maintainer, who may neither change the role of a
+// member ranked above them nor grant a role above their own.
+func (u *UseCases) SetMemberRole(uid, tid, member uint, role team.Role) error {
+	if !role.IsValid() {
+		return ErrInvalidRole
+	}
+	ms, err := u.membership(uid, tid, team.Maintainer)
+	if err != nil {
+		return err
+	}
+	target, err := u.TeamStorage.GetMembership(tid, member)
+	if err != nil {
+		return err
+	}
+	if !ms.Role.AtLeast(target.Role) || !ms.Role.AtLeast(role) {
+		return ErrForbidden
+	}
+	return ownerError(u.TeamStorage.SetMemberRole(tid, member, role))
+}
+
+// RemoveMember lets any member leave, and a maintainer remove members not
+// ranked above them.
+func (u *UseCases) RemoveMember(uid, tid, member uint) error {
+	if uid == member {
+		if _, err := u.membership(uid, tid, team.Member); err != nil {
+			return err
+		}
+	} else {
+		ms, err := u.membership(uid, tid, team.Maintainer)
+		if err != nil {
+			return err
+		}
+		target, err := u.TeamStorage.GetMembership(tid, member)
+		if err != nil {
+			return err
+		}
+		if !ms.Role.AtLeast(target.Role) {
+			return ErrForbidden
+		}
+	}
+	return ownerError(u.TeamStorage.DeleteMember(tid, member))
+}
+
+// ownerError translates the storage refusing to leave a team without an
+// owner, which it checks along with the write.
+func ownerError(err error) error {
+	if err == team.ErrLastOwner {
+		return ErrLastOwner
+	}
+	return err
+}
+
+func validateTeamName(name string) error {
+	chars := 0
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return ErrInvalidTeamName
+		}
+		chars++
+	}
+	if chars < minTeamNameLength || chars > maxTeamNameLength {
+		return ErrInvalidTeamName
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package team
+
+import (
+	"github.com/mp-hl-2021/code-swamp/internal/domain/team"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/codesnippetrepo"
+	"github.com/mp-hl-2021/code-swamp/internal/interface/memory/teamrepo"
+	"testing"
+)
+
+const (
+	owner uint = iota + 1
+	maintainer
+	member
+	stranger
+)
+
+func newTeam(t *testing.T) (*UseCases, uint) {
+	u := &UseCases{TeamStorage: teamrepo.NewMemory(codesnippetrepo.NewMemory())}
+	tt, err := u.CreateTeam(owner, "toads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for uid, role := range map[uint]team.Role{maintainer: team.Maintainer, member: team.Member} {
+		i, err := u.InviteMember(owner, tt.Id, uid, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.AcceptInvitation(uid, i.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return u, tt.Id
+}
+
+func TestInviteMember(t *testing.T) {
+	u, tid := newTeam(t)
+	if _, err := u.InviteMember(member, tid, stranger, team.Member); err != ErrForbidden {
+		t.Errorf("member invited: %v", err)
+	}
+	if _, err := u.InviteMember(maintainer, tid, stranger, team.Owner); err != ErrForbidden {
+		t.Errorf("maintainer invited an owner: %v", err)
+	}
+	if _, err := u.InviteMember(stranger, tid, stranger, team.Member); err != team.ErrNotFound {
+		t.Errorf("stranger invited: %v", err)
+	}
+	if _, err := u.InviteMember(owner, tid, member, team.Member); err != team.ErrMemberAlreadyExist {
+		t.Errorf("member invited twice: %v", err)
+	}
+	i, err := u.InviteMember(maintainer, tid, stranger, team.Maintainer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.AcceptInvitation(member, i.Id); err != team.ErrInvitationNotFound {
+		t.Errorf("invitation accepted by someone else: %v", err)
+	}
+}
+
+func TestSetMemberRole(t *testing.T) {
+	u, tid := newTeam(t)
+	if err := u.SetMemberRole(maintainer, tid, member, team.Owner); err != ErrForbidden {
+		t.Errorf("maintainer granted owner: %v", err)
+	}
+	if err := u.SetMemberRole(maintainer, tid, owner, team.Member); err != ErrForbidden {
+		t.Errorf("maintainer demoted owner: %v", err)
+	}
+	if err := u.SetMemberRole(owner, tid, owner, team.Member); err != ErrLastOwner {
+		t.Errorf("last owner demoted: %v", err)
+	}
+	if err := u.SetMemberRole(owner, tid, member, team.Owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetMemberRole(owner, tid, owner, team.Member); err != nil {
+		t.Errorf("owner not demoted with another owner: %v", err)
+	}
+}
+
+func TestDeleteMembershipsByUser(t *testing.T) {
+	u, tid := newTeam(t)
+	storage := u.TeamStorage.(*teamrepo.Memory)
+	if err := storage.DeleteMembershipsByUser(owner); err != team.ErrLastOwner {
+		t.Errorf("last owner removed with the account: %v", err)
+	}
+	if err := u.SetMemberRole(owner, tid, maintainer, team.Owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DeleteMembershipsByUser(owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetMembership(tid, owner); err != team.ErrMemberNotFound {
+		t.Errorf("membership kept: %v", err)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	u, tid := newTeam(t)
+	if err := u.RemoveMember(member, tid, maintainer); err != ErrForbidden {
+		t.Errorf("member removed maintainer: %v", err)
+	}
+	if err := u.RemoveMember(owner, tid, owner); err != ErrLastOwner {
+		t.Errorf("last owner left: %v", err)
+	}
+	if err := u.RemoveMember(member, tid, member); err != nil {
+		t.Errorf("member did not leave: %v", err)
+	}
+	if err := u.RemoveMember(maintainer, tid, owner); err != ErrForbidden {
+		t.Errorf("maintainer removed owner: %v", err)
+	}
+	if err := u.DeleteTeam(maintainer, tid); err != ErrForbidden {
+		t.Errorf("maintainer deleted team: %v", err)
+	}
+	if err := u.DeleteTeam(owner, tid); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := u.GetTeam(owner, tid); err != team.ErrNotFound {
+		t.Errorf("team not deleted: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"github.com/mp-hl-2021/code-swamp/internal/service/webhooksig"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxErrorLength    = 255
+	maxResponseLength = 64 * 1024
+)
+
+var privateNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+func isPrivateIp(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, cidr := range privateNetworks {
+		_, n, _ := net.ParseCIDR(cidr)
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateHost catches literal addresses and localhost early, the dialer
+// checks the resolved addresses of every other name.
+func isPrivateHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && isPrivateIp(ip)
+}
+
+// NewClient returns the client deliveries are sent with when UseCases has
+// none. Unless private networks are allowed it refuses to connect to
+// private addresses, wherever a name resolves to, and does not follow
+// redirects.
+func NewClient(allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIp(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: defaultClientTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: defaultClientTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       time.Minute,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (u *UseCases) client() *http.Client {
+	if u.Client == nil {
+		u.Client = NewClient(u.AllowPrivateNetworks)
+	}
+	return u.Client
+}
+
+// lease keeps a claimed delivery from being claimed again while it is being
+// sent.
+func (u *UseCases) lease() time.Duration {
+	timeout := u.client().Timeout
+	if timeout == 0 {
+		timeout = defaultClientTimeout
+	}
+	return 2 * timeout
+}
+
+func (u *UseCases) DeliverDue() (int, error) {
+	dd, err := u.WebhookStorage.ClaimDueDeliveries(time.Now(), defaultBatchSize, u.lease())
+	if err != nil {
+		return 0, err
+	}
+	// one delivery failing to be stored must not hold up the rest of the
+	// batch, it is claimed again once its lease runs out
+	for _, d := range dd {
+		if err := u.deliver(d); err != nil {
+			fmt.Printf("Error delivering webhook delivery %d: %s\n", d.Id, err)
+		}
+	}
+	return len(dd), nil
+}
+
+func (u *UseCases) deliver(d webhook.Delivery) error {
+	w, err := u.WebhookStorage.GetWebhookById(d.WebhookId)
+	if err == webhook.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.StatusCode, err = u.send(w, d)
+	d.Attempts++
+	d.UpdatedAt = time.Now()
+	switch {
+	case err == nil:
+		d.State = webhook.Succeeded
+		d.Error = ""
+	case d.Attempts >= u.maxAttempts():
+		d.State = webhook.Failed
+		d.Error = truncate(err.Error(), maxErrorLength)
+	default:
+		d.NextAttemptAt = d.UpdatedAt.Add(u.backoff(d.Attempts))
+		d.Error = truncate(err.Error(), maxErrorLength)
+	}
+	err = u.WebhookStorage.UpdateDelivery(d)
+	// the webhook and its deliveries may have been deleted in the meantime
+	if err == webhook.ErrDeliveryNotFound {
+		return nil
+	}
+	return err
+}
+
+func (u *UseCases) send(w webhook.Webhook, d webhook.Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), u.lease())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "code-swamp-webhooks")
+	req.Header.Set(webhooksig.EventHeader, string(d.Event))
+	req.Header.Set(webhooksig.DeliveryHeader, strconv.FormatUint(uint64(d.Id), 10))
+	req.Header.Set(webhooksig.SignatureHeader, webhooksig.Sign(w.Secret, d.Payload))
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain a little so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponseLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// truncate cuts the error down to what the storage takes, which counts
+// characters, and drops invalid UTF-8 first: the status line comes from the
+// receiver.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mp-hl-2021/code-swamp/internal/domain/webhook"
+	"github.com/mp-hl-2021/code-swamp/internal/service/webhooksig"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidUrl      = errors.New("webhook url should be an absolute http or https url")
+	ErrInvalidEvent    = errors.New("unknown webhook event")
+	ErrTooManyWebhooks = errors.New("too many webhooks")
+	ErrPrivateAddress  = errors.New("webhook address is not public")
+)
+
+const (
+	maxUrlLength         = 2048
+	maxWebhooksPerUser   = 10
+	maxDeliveriesListed  = 100
+	defaultMaxAttempts   = 8
+	defaultBaseBackoff   = 30 * time.Second
+	defaultMaxBackoff    = time.Hour
+	defaultClientTimeout = 10 * time.Second
+	defaultBatchSize     = 20
+)
+
+type Webhook struct {
+	Id        uint
+	Url       string
+	Events    []webhook.Event
+	CreatedAt time.Time
+}
+
+// SnippetEvent is something that happened to a snippet. It is delivered to
+// the webhooks of the snippet owner.
+type SnippetEvent struct {
+	Event      webhook.Event
+	Sid        uint
+	Uid        uint
+	Tid        uint
+	HasTeam    bool
+	Lang       string
+	LintStatus string
+	Message    string
+}
+
+type Interface interface {
+	// CreateWebhook returns the signing secret, which is shown only once.
+	CreateWebhook(uid uint, rawUrl string, events []webhook.Event) (Webhook, string, error)
+	GetWebhooks(uid uint) ([]Webhook, error)
+	DeleteWebhook(uid, id uint) error
+	GetDeliveries(uid, id uint, limit int) ([]webhook.Delivery, error)
+
+	Publish(e SnippetEvent) error
+	// DeliverDue sends the deliveries that are due and reports how many
+	// were attempted.
+	DeliverDue() (int, error)
+}
+
+type UseCases struct {
+	WebhookStorage webhook.Interface
+	// Client sends the deliveries. Without it a client that refuses private
+	// addresses is used.
+	Client *http.Client
+	// AllowPrivateNetworks lets webhooks point at loopback and private
+	// addresses, for local receivers.
+	AllowPrivateNetworks bool
+	MaxAttempts          int
+	BaseBackoff          time.Duration
+	MaxBackoff           time.Duration
+}
+
+func (u *UseCases) maxAttempts() int {
+	if u.MaxAttempts == 0 {
+		return defaultMaxAttempts
+	}
+	return u.MaxAttempts
+}
+
+// backoff doubles the wait after every failed attempt up to MaxBackoff.
+func (u *UseCases) backoff(attempts int) time.Duration {
+	base, max := u.BaseBackoff, u.MaxBackoff
+	if base == 0 {
+		base = defaultBaseBackoff
+	}
+	if max == 0 {
+		max = defaultMaxBackoff
+	}
+	d := base
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func toWebhook(w webhook.Webhook) Webhook {
+	return Webhook{Id: w.Id, Url: w.Url, Events: w.Events, CreatedAt: w.CreatedAt}
+}
+
+func (u *UseCases) validateUrl(rawUrl string) error {
+	if len(rawUrl) > maxUrlLength {
+		return ErrInvalidUrl
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrInvalidUrl
+	}
+	if !u.AllowPrivateNetworks && isPrivateHost(parsed.Hostname()) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// validateEvents subscribes to every event when none are given.
+func validateEvents(events []webhook.Event) ([]webhook.Event, error) {
+	if len(events) == 0 {
+		return webhook.Events, nil
+	}
+	seen := make(map[webhook.Event]bool, len(events))
+	var valid []webhook.Event
+	for _, e := range events {
+		known := false
+		for _, k := range webhook.Events {
+			if e == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, ErrInvalidEvent
+		}
+		if !seen[e] {
+			seen[e] = true
+			valid = append(valid, e)
+		}
+	}
+	return valid, nil
+}
+
+func (u *UseCases) CreateWebhook(uid uint, rawUrl string, events []webhook.Event) (Webhook, string, error) {
+	if err := u.validateUrl(rawUrl); err != nil {
+		return Webhook{}, "", err
+	}
+	events, err := validateEvents(events)
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	existing, err := u.WebhookStorage.GetWebhooksByUser(uid)
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		return Webhook{}, "", ErrTooManyWebhooks
+	}
+	secret, err := webhooksig.NewSecret()
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	w, err := u.WebhookStorage.CreateWebhook(webhook.Webhook{
+		Uid:       uid,
+		Url:       rawUrl,
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	return toWebhook(w), secret, nil
+}
+
+func (u *UseCases) GetWebhooks(uid uint) ([]Webhook, error) {
+	ww, err := u.WebhookStorage.GetWebhooksByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]Webhook, len(ww))
+	for i, w := range ww {
+		webhooks[i] = toWebhook(w)
+	}
+	return webhooks, nil
+}
+
+func (u *UseCases) DeleteWebhook(uid, id uint) error {
+	return u.WebhookStorage.DeleteWebhook(uid, id)
+}
+
+func (u *UseCases) GetDeliveries(uid, id uint, limit int) ([]webhook.Delivery, error) {
+	w, err := u.WebhookStorage.GetWebhookById(id)
+	if err != nil {
+		return nil, err
+	}
+	if w.Uid != uid {
+		return nil, webhook.ErrNotFound
+	}
+	if limit <= 0 || limit > maxDeliveriesListed {
+		limit = maxDeliveriesListed
+	}
+	return u.WebhookStorage.GetDeliveriesByWebhook(id, limit)
+}
+
+type payload struct {
+	Event      webhook.Event `json:"event"`
+	SnippetId  uint          `json:"snippet_id"`
+	TeamId     *uint         `json:"team_id,omitempty"`
+	Lang       string        `json:"lang,omitempty"`
+	LintStatus string        `json:"lint_status,omitempty"`
+	Message    *string       `json:"message,omitempty"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// Publish queues a delivery for every webhook of the owner subscribed to the
+// event. The payload is fixed here, so retries send and sign the same bytes.
+func (u *UseCases) Publish(e SnippetEvent) error {
+	ww, err := u.WebhookStorage.GetWebhooksByUser(e.Uid)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	p := payload{
+		Event:      e.Event,
+		SnippetId:  e.Sid,
+		Lang:       e.Lang,
+		OccurredAt: now.UTC(),
+	}
+	if e.HasTeam {
+		tid := e.Tid
+		p.TeamId = &tid
+	}
+	if e.Event == webhook.SnippetChecked {
+		message := e.Message
+		p.Message = &message
+		p.LintStatus = e.LintStatus
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	for _, w := range ww {
+		if !w.Subscribes(e.Event) {
+			continue
+		}
+		_, err := u.WebhookStorage.CreateDelivery(webhook.Delivery{
+			WebhookId:     w.Id,
+			Event:         e.Event,
+			Payload:       body,
+			State:         webhook.Pending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		// the webhook may have been deleted in the meantime
+		if err != nil && err != webhook.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}